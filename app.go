@@ -1,3 +1,5 @@
+//go:build windows
+
 package main
 
 import (
@@ -5,8 +7,8 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -15,38 +17,19 @@ import (
 	"golang.org/x/sys/windows/registry"
 )
 
-// Service 表示一个后台服务
-type Service struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	ExePath    string    `json:"exePath"`
-	Args       string    `json:"args"`
-	WorkingDir string    `json:"workingDir"`
-	Status     string    `json:"status"` // "running", "stopped", "error"
-	PID        int       `json:"pid"`
-	AutoStart  bool      `json:"autoStart"`
-	CreatedAt  time.Time `json:"createdAt"`
-	UpdatedAt  time.Time `json:"updatedAt"`
-}
-
-// ServiceConfig 用于创建新服务的配置
-type ServiceConfig struct {
-	Name       string `json:"name"`
-	ExePath    string `json:"exePath"`
-	Args       string `json:"args"`
-	WorkingDir string `json:"workingDir"`
-}
-
 type App struct {
 	ctx                context.Context
 	serviceManager     *WindowsServiceManager
 	environmentManager *EnvironmentManager
+	tailMutex          sync.Mutex
+	logTails           map[string]context.CancelFunc
 }
 
 func NewApp() *App {
 	return &App{
 		serviceManager:     NewWindowsServiceManager(),
 		environmentManager: NewEnvironmentManager(),
+		logTails:           make(map[string]context.CancelFunc),
 	}
 }
 
@@ -54,7 +37,13 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 	a.serviceManager.SetContext(ctx)
-	a.serviceManager.loadServices()
+
+	// 以SCM为准恢复服务列表
+	go func() {
+		if _, err := a.serviceManager.ReconcileServices(); err != nil {
+			log.Printf("同步服务列表失败: %v", err)
+		}
+	}()
 }
 
 // GetServices 获取所有服务列表
@@ -66,11 +55,26 @@ func (a *App) GetServices() []*Service {
 	return services
 }
 
+// RefreshServices 立即从SCM重新读取服务配置并返回服务列表
+func (a *App) RefreshServices() ([]*Service, error) {
+	return a.serviceManager.RefreshServices()
+}
+
 // CreateService 创建新的服务
 func (a *App) CreateService(config ServiceConfig) (*Service, error) {
 	return a.serviceManager.CreateService(config)
 }
 
+// GetServiceEnvironment 获取服务的环境变量配置
+func (a *App) GetServiceEnvironment(serviceID string) ([]string, error) {
+	return a.serviceManager.GetServiceEnvironment(serviceID)
+}
+
+// SetServiceEnvironment 设置服务的环境变量配置，服务下次启动时生效
+func (a *App) SetServiceEnvironment(serviceID string, environment []string) error {
+	return a.serviceManager.SetServiceEnvironment(serviceID, environment)
+}
+
 // StartService 启动服务
 func (a *App) StartService(serviceID string) error {
 	return a.serviceManager.StartService(serviceID)
@@ -81,6 +85,46 @@ func (a *App) StopService(serviceID string) error {
 	return a.serviceManager.StopService(serviceID)
 }
 
+// RestartService 重启服务，delayMs 为停止后到再次启动前的等待时间（毫秒）
+func (a *App) RestartService(serviceID string, delayMs int) error {
+	return a.serviceManager.RestartService(serviceID, delayMs)
+}
+
+// ReconcileServices 以SCM为准重新同步服务列表
+func (a *App) ReconcileServices() (*ReconcileReport, error) {
+	return a.serviceManager.ReconcileServices()
+}
+
+// ImportNssmServices 导入以 NSSM 运行的服务，dryRun 为 true 时只返回转换报告
+func (a *App) ImportNssmServices(serviceNames []string, dryRun bool) (*NssmImportReport, error) {
+	return a.serviceManager.ImportNssmServices(serviceNames, dryRun)
+}
+
+// ListExternalServices 列出可纳管的外部服务，pattern 支持 * 和 ? 通配符
+func (a *App) ListExternalServices(pattern string) ([]ExternalServiceInfo, error) {
+	return a.serviceManager.ListExternalServices(pattern)
+}
+
+// AdoptExternalServices 纳管匹配模式的外部服务
+func (a *App) AdoptExternalServices(pattern string, readOnly bool) ([]*Service, error) {
+	return a.serviceManager.AdoptExternalServices(pattern, readOnly)
+}
+
+// SetExternalServiceReadOnly 设置外部服务是否只读
+func (a *App) SetExternalServiceReadOnly(serviceID string, readOnly bool) error {
+	return a.serviceManager.SetExternalServiceReadOnly(serviceID, readOnly)
+}
+
+// SetServiceAccount 修改服务的运行账户
+func (a *App) SetServiceAccount(serviceID, account, password string) error {
+	return a.serviceManager.SetServiceAccount(serviceID, account, password)
+}
+
+// UpdateService 修改服务配置，restartIfRunning 为 true 时重启正在运行的服务使配置立即生效
+func (a *App) UpdateService(serviceID string, config ServiceConfig, restartIfRunning bool) (*Service, error) {
+	return a.serviceManager.UpdateService(serviceID, config, restartIfRunning)
+}
+
 // DeleteService 删除服务
 func (a *App) DeleteService(serviceID string) error {
 	return a.serviceManager.DeleteService(serviceID)
@@ -223,14 +267,34 @@ func (a *App) HideWindow() {
 	runtime.WindowHide(a.ctx)
 }
 
-// SetServiceAutoStart 设置服务开机自启动
-func (a *App) SetServiceAutoStart(serviceID string, enabled bool) error {
-	return a.serviceManager.SetServiceAutoStart(serviceID, enabled)
+// SetServiceStartType 设置服务启动类型：automatic、delayed、manual、disabled
+func (a *App) SetServiceStartType(serviceID string, startType ServiceStartType) error {
+	return a.serviceManager.SetServiceStartType(serviceID, startType)
+}
+
+// GetServiceStartType 获取服务当前的启动类型
+func (a *App) GetServiceStartType(serviceID string) (ServiceStartType, error) {
+	return a.serviceManager.GetServiceStartType(serviceID)
+}
+
+// StartServiceWithDependencies 先启动服务依赖的服务，再启动该服务
+func (a *App) StartServiceWithDependencies(serviceID string) error {
+	return a.serviceManager.StartServiceWithDependencies(serviceID)
 }
 
-// GetServiceAutoStart 获取服务开机自启动状态
-func (a *App) GetServiceAutoStart(serviceID string) bool {
-	return a.serviceManager.GetServiceAutoStart(serviceID)
+// StopServiceWithDependents 先停止依赖该服务的服务，再停止该服务
+func (a *App) StopServiceWithDependents(serviceID string) error {
+	return a.serviceManager.StopServiceWithDependents(serviceID)
+}
+
+// SetServiceRecovery 设置服务的SCM故障恢复配置
+func (a *App) SetServiceRecovery(serviceID string, recovery RecoveryConfig) error {
+	return a.serviceManager.SetServiceRecovery(serviceID, recovery)
+}
+
+// GetServiceRecovery 获取服务当前的SCM故障恢复配置
+func (a *App) GetServiceRecovery(serviceID string) (*RecoveryConfig, error) {
+	return a.serviceManager.GetServiceRecovery(serviceID)
 }
 
 // AddSystemEnvironmentVariable 添加系统环境变量
@@ -260,59 +324,175 @@ func (a *App) DiagnoseEnvironmentAccess() (map[string]interface{}, error) {
 
 // GetServiceLogs 获取服务日志内容（最新日志）
 func (a *App) GetServiceLogs(serviceID string) (string, error) {
-	logDir := filepath.Join(os.Getenv("ProgramData"), "windows_service_logs")
+	return a.GetServiceLogStream(serviceID, LogStreamStdout)
+}
 
-	log.Printf("GetServiceLogs: 查找服务 %s 的日志文件", serviceID)
+// GetServiceLogStream 获取服务指定日志流的最新内容：stdout、stderr 或按时间合并的 merged，
+// merged 需要服务启用 TimestampLogs
+func (a *App) GetServiceLogStream(serviceID, stream string) (string, error) {
+	log.Printf("GetServiceLogStream: 读取服务 %s 的 %s 日志", serviceID, stream)
 
-	files, err := filepath.Glob(filepath.Join(logDir, fmt.Sprintf("%s_*.log", serviceID)))
+	timestamped, err := a.serviceManager.serviceTimestampLogs(serviceID)
 	if err != nil {
-		log.Printf("GetServiceLogs: 查找日志文件失败: %v", err)
-		return "", fmt.Errorf("查找日志文件失败: %v", err)
+		return "", err
 	}
 
-	if len(files) == 0 {
-		log.Printf("GetServiceLogs: 未找到日志文件")
-		return "", fmt.Errorf("未找到日志文件")
+	content, err := readLatestServiceLog(serviceID, stream, timestamped)
+	if err != nil {
+		log.Printf("GetServiceLogStream: 读取日志失败: %v", err)
+		return "", err
 	}
 
-	latestFile := files[len(files)-1]
-	log.Printf("GetServiceLogs: 找到 %d 个日志文件，使用最新的: %s", len(files), latestFile)
+	log.Printf("GetServiceLogStream: 成功读取日志，大小: %d 字节", len(content))
+	return content, nil
+}
 
-	content, err := os.ReadFile(latestFile)
+// ListServiceLogFiles 列出服务的所有历史日志文件，按从新到旧排序
+func (a *App) ListServiceLogFiles(serviceID string) ([]LogFileInfo, error) {
+	return listServiceLogFiles(osLogFileSystem{}, serviceLogDir(), serviceID)
+}
+
+// TailServiceLogs 订阅服务最新日志的增量内容，新增的行通过 service-log-lines 事件推送。
+// fromOffset 为起始偏移量，小于0表示只推送订阅之后的新内容。返回订阅ID，用于取消订阅。
+func (a *App) TailServiceLogs(serviceID string, fromOffset int64) (string, error) {
+	subscriptionID := fmt.Sprintf("%s_%d", serviceID, time.Now().UnixNano())
+
+	resolve := func() (string, error) {
+		return latestServiceLogFile(serviceID, LogStreamStdout)
+	}
+	tailer := NewLogTailer(resolve, fromOffset, defaultTailInterval, func(batch LogTailBatch) {
+		runtime.EventsEmit(a.ctx, "service-log-lines", map[string]interface{}{
+			"subscriptionId": subscriptionID,
+			"serviceId":      serviceID,
+			"file":           batch.File,
+			"lines":          batch.Lines,
+			"offset":         batch.Offset,
+			"reset":          batch.Reset,
+		})
+	})
+
+	ctx, cancel := context.WithCancel(a.ctx)
+
+	a.tailMutex.Lock()
+	a.logTails[subscriptionID] = cancel
+	a.tailMutex.Unlock()
+
+	go func() {
+		defer a.StopTailServiceLogs(subscriptionID)
+
+		if err := tailer.Run(ctx); err != nil {
+			log.Printf("TailServiceLogs: 跟踪日志失败: %v", err)
+		}
+	}()
+
+	log.Printf("TailServiceLogs: 开始跟踪服务 %s 的日志，订阅ID: %s", serviceID, subscriptionID)
+	return subscriptionID, nil
+}
+
+// StopTailServiceLogs 取消日志订阅
+func (a *App) StopTailServiceLogs(subscriptionID string) error {
+	a.tailMutex.Lock()
+	defer a.tailMutex.Unlock()
+
+	cancel, exists := a.logTails[subscriptionID]
+	if !exists {
+		return fmt.Errorf("日志订阅不存在: %s", subscriptionID)
+	}
+
+	cancel()
+	delete(a.logTails, subscriptionID)
+	return nil
+}
+
+// ReadServiceLogLines 按行分页读取服务的日志文件，fileName 为空时读取最新的标准输出日志
+func (a *App) ReadServiceLogLines(serviceID, fileName string, startLine, lineCount int) (*LogPage, error) {
+	file, err := resolveServiceLogFile(serviceID, fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := openLogFile(file)
 	if err != nil {
-		log.Printf("GetServiceLogs: 读取日志文件失败: %v", err)
-		return "", fmt.Errorf("读取日志文件失败: %v", err)
+		return nil, err
 	}
+	defer reader.Close()
 
-	log.Printf("GetServiceLogs: 成功读取日志文件，大小: %d 字节", len(content))
-	return string(content), nil
+	page, err := ReadLogLines(reader, startLine, lineCount)
+	if err != nil {
+		return nil, err
+	}
+	page.File = file.Name
+	return page, nil
 }
 
-// GetServiceLogsPath 获取服务日志文件路径（最新日志）
-func (a *App) GetServiceLogsPath(serviceID string) (string, error) {
-	logDir := filepath.Join(os.Getenv("ProgramData"), "windows_service_logs")
+// ReadServiceLogBytes 按字节范围读取服务的日志文件，已压缩的文件按解压后的偏移量读取
+func (a *App) ReadServiceLogBytes(serviceID, fileName string, offset, length int64) (*LogChunk, error) {
+	file, err := resolveServiceLogFile(serviceID, fileName)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Printf("GetServiceLogsPath: 查找服务 %s 的日志文件", serviceID)
+	reader, err := openLogFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	chunk, err := ReadLogBytes(reader, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	chunk.File = file.Name
+	return chunk, nil
+}
+
+// SearchServiceLogs 在服务的日志文件中搜索，返回匹配的行号及前后文
+func (a *App) SearchServiceLogs(serviceID, fileName string, query LogSearchQuery) (*LogSearchResult, error) {
+	file, err := resolveServiceLogFile(serviceID, fileName)
+	if err != nil {
+		return nil, err
+	}
 
-	files, err := filepath.Glob(filepath.Join(logDir, fmt.Sprintf("%s_*.log", serviceID)))
+	reader, err := openLogFile(file)
 	if err != nil {
-		log.Printf("GetServiceLogsPath: 查找日志文件失败: %v", err)
-		return "", fmt.Errorf("查找日志文件失败: %v", err)
+		return nil, err
 	}
+	defer reader.Close()
 
-	if len(files) == 0 {
-		log.Printf("GetServiceLogsPath: 未找到日志文件")
+	result, err := SearchLog(reader, query)
+	if err != nil {
+		return nil, err
+	}
+	result.File = file.Name
+	return result, nil
+}
+
+// GetServiceWrapperLog 获取服务包装器日志的末尾部分，用于排查服务无法启动等问题
+func (a *App) GetServiceWrapperLog(serviceID string) (string, error) {
+	content, err := readLogTail(wrapperLogPath(serviceID), latestLogTailBytes)
+	if err != nil {
+		return "", fmt.Errorf("包装器日志不存在")
+	}
+	return content, nil
+}
+
+// GetServiceLogsPath 获取服务日志文件路径（最新日志）
+func (a *App) GetServiceLogsPath(serviceID string) (string, error) {
+	log.Printf("GetServiceLogsPath: 查找服务 %s 的日志文件", serviceID)
+
+	latestFile, err := latestServiceLogFile(serviceID, LogStreamStdout)
+	if err != nil {
+		log.Printf("GetServiceLogsPath: %v", err)
 		return "", fmt.Errorf("日志文件不存在")
 	}
 
-	latestFile := files[len(files)-1]
-	log.Printf("GetServiceLogsPath: 找到 %d 个日志文件，返回最新的: %s", len(files), latestFile)
+	log.Printf("GetServiceLogsPath: 返回最新的日志文件: %s", latestFile)
 	return latestFile, nil
 }
 
 // OpenLogsDirectory 打开日志目录
 func (a *App) OpenLogsDirectory(serviceID string) error {
-	logDir := filepath.Join(os.Getenv("ProgramData"), "windows_service_logs")
+	logDir := serviceLogDir()
 
 	log.Printf("OpenLogsDirectory: 打开日志目录: %s", logDir)
 
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strings"
+)
+
+// SplitCommandLine 按 CommandLineToArgvW 的规则将参数字符串拆分为参数列表：
+//   - 引号外的空格和制表符分隔参数
+//   - 2n 个反斜杠后跟引号，输出 n 个反斜杠并切换引号状态
+//   - 2n+1 个反斜杠后跟引号，输出 n 个反斜杠和一个字面引号
+//   - 引号内的两个连续引号输出一个字面引号并结束引号状态
+//   - 其余位置的反斜杠按字面输出
+func SplitCommandLine(cmdline string) []string {
+	args := []string{}
+
+	var arg strings.Builder
+	inArg := false
+	inQuote := false
+	slashes := 0
+
+	for i := 0; i < len(cmdline); i++ {
+		c := cmdline[i]
+
+		switch {
+		case c == '\\':
+			slashes++
+			inArg = true
+			continue
+		case c == '"':
+			arg.WriteString(strings.Repeat(`\`, slashes/2))
+			if slashes%2 == 1 {
+				arg.WriteByte('"')
+			} else if inQuote && i+1 < len(cmdline) && cmdline[i+1] == '"' {
+				arg.WriteByte('"')
+				inQuote = false
+				i++
+			} else {
+				inQuote = !inQuote
+			}
+			slashes = 0
+			inArg = true
+			continue
+		case (c == ' ' || c == '\t') && !inQuote:
+			if inArg {
+				arg.WriteString(strings.Repeat(`\`, slashes))
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			slashes = 0
+			continue
+		}
+
+		arg.WriteString(strings.Repeat(`\`, slashes))
+		slashes = 0
+		arg.WriteByte(c)
+		inArg = true
+	}
+
+	if inArg {
+		arg.WriteString(strings.Repeat(`\`, slashes))
+		args = append(args, arg.String())
+	}
+
+	return args
+}
+
+// JoinCommandLine 将参数列表转义并拼接为命令行字符串，是 SplitCommandLine 的逆操作
+func JoinCommandLine(args []string) string {
+	escaped := make([]string, len(args))
+	for i, arg := range args {
+		escaped[i] = escapeCommandLineArg(arg)
+	}
+	return strings.Join(escaped, " ")
+}
+
+// escapeCommandLineArg 按 CommandLineToArgvW 的规则转义单个参数，规则与 windows.EscapeArg 相同：
+// 空参数写为 ""，含空白的参数加引号，引号前（以及结尾引号前）的反斜杠加倍
+func escapeCommandLineArg(arg string) string {
+	if arg == "" {
+		return `""`
+	}
+
+	needsBackslash := strings.ContainsAny(arg, `"\`)
+	hasSpace := strings.ContainsAny(arg, " \t")
+	if !needsBackslash && !hasSpace {
+		return arg
+	}
+	if !needsBackslash {
+		return `"` + arg + `"`
+	}
+
+	var b strings.Builder
+	if hasSpace {
+		b.WriteByte('"')
+	}
+	slashes := 0
+	for i := 0; i < len(arg); i++ {
+		c := arg[i]
+		switch c {
+		case '\\':
+			slashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(c)
+	}
+	if hasSpace {
+		b.WriteString(strings.Repeat(`\`, slashes))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// argv 返回目标程序的参数列表，ArgList 优先于 Args
+func (config ServiceConfig) argv() []string {
+	if len(config.ArgList) > 0 {
+		return config.ArgList
+	}
+	return SplitCommandLine(config.Args)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommandLine(t *testing.T) {
+	tests := []struct {
+		cmdline string
+		want    []string
+	}{
+		{``, []string{}},
+		{`   `, []string{}},
+		{`a b	c`, []string{"a", "b", "c"}},
+		{`  a   b  `, []string{"a", "b"}},
+		{`"a b" c`, []string{"a b", "c"}},
+		{`a"b c"d`, []string{"ab cd"}},
+		{`""`, []string{""}},
+		{`a "" b`, []string{"a", "", "b"}},
+		{`"unterminated arg`, []string{"unterminated arg"}},
+		// 不在引号前的反斜杠按字面输出
+		{`C:\dir\file.txt`, []string{`C:\dir\file.txt`}},
+		{`a\\b`, []string{`a\\b`}},
+		{`trailing\`, []string{`trailing\`}},
+		{`trailing\\ x`, []string{`trailing\\`, "x"}},
+		// 2n 个反斜杠后跟引号：n 个反斜杠，引号切换状态
+		{`"a\\" b`, []string{`a\`, "b"}},
+		{`"a\\\\"`, []string{`a\\`}},
+		// 2n+1 个反斜杠后跟引号：n 个反斜杠和一个字面引号
+		{`a\"b`, []string{`a"b`}},
+		{`a\\\"b`, []string{`a\"b`}},
+		{`"\"quoted\""`, []string{`"quoted"`}},
+		// 引号内的两个连续引号输出一个字面引号
+		{`"a""b"`, []string{`a"b`}},
+		{`"a""b c"`, []string{`a"b`, "c"}},
+	}
+
+	for _, tt := range tests {
+		if got := SplitCommandLine(tt.cmdline); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitCommandLine(%s) = %q, 期望 %q", tt.cmdline, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeCommandLineArg(t *testing.T) {
+	// 期望值与 windows.EscapeArg 的输出相同
+	tests := []struct {
+		arg, want string
+	}{
+		{``, `""`},
+		{`plain`, `plain`},
+		{`a b`, `"a b"`},
+		{"tab\there", "\"tab\there\""},
+		{`C:\Program Files\app`, `"C:\Program Files\app"`},
+		{`C:\dir\`, `C:\dir\`},
+		{`C:\my dir\`, `"C:\my dir\\"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\"b`, `a\\\"b`},
+		{`a\\"b`, `a\\\\\"b`},
+		{`"`, `\"`},
+		{`\\server\share`, `\\server\share`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeCommandLineArg(tt.arg); got != tt.want {
+			t.Errorf("escapeCommandLineArg(%s) = %s, 期望 %s", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestJoinCommandLineRoundTrip(t *testing.T) {
+	tests := [][]string{
+		{},
+		{"a", "b", "c"},
+		{""},
+		{"", ""},
+		{"a", "", "b"},
+		{"with space", "with\ttab"},
+		{`C:\Program Files\app\`},
+		{`trailing\\`, `in "quotes"`},
+		{`\`, `\\`, `\\\`, `\"`, `\\"`},
+		{`"`, `""`, `"a"`, `a"b"c`},
+		{`--config=C:\a b\c.toml`, "--name=中文 参数"},
+		{`\\server\share\dir with space\`},
+	}
+
+	for _, args := range tests {
+		cmdline := JoinCommandLine(args)
+		if got := SplitCommandLine(cmdline); !reflect.DeepEqual(got, args) {
+			t.Errorf("SplitCommandLine(JoinCommandLine(%q)) = %q, 命令行 %s", args, got, cmdline)
+		}
+	}
+}
+
+func TestServiceConfigArgv(t *testing.T) {
+	config := ServiceConfig{Args: `--a "b c"`, ArgList: []string{"x", ""}}
+	if got := config.argv(); !reflect.DeepEqual(got, []string{"x", ""}) {
+		t.Fatalf("ArgList 应优先于 Args: %q", got)
+	}
+
+	config.ArgList = nil
+	if got := config.argv(); !reflect.DeepEqual(got, []string{"--a", "b c"}) {
+		t.Fatalf("argv = %q", got)
+	}
+}
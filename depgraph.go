@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// serviceGroupPrefix SCM依赖列表中服务组名称的前缀（SC_GROUP_IDENTIFIER）
+const serviceGroupPrefix = "+"
+
+// isServiceGroupDependency 判断依赖项是否为服务组
+func isServiceGroupDependency(name string) bool {
+	return strings.HasPrefix(name, serviceGroupPrefix)
+}
+
+// DependencyGraph 服务依赖关系图。服务名不区分大小写，与SCM一致；
+// 服务组依赖（以 + 开头）只作为记录，不参与排序和环检测。
+type DependencyGraph struct {
+	names map[string]string   // 小写名称 → 原始名称
+	deps  map[string][]string // 小写名称 → 依赖的服务（小写）
+}
+
+// NewDependencyGraph 创建空的依赖关系图
+func NewDependencyGraph() *DependencyGraph {
+	return &DependencyGraph{
+		names: make(map[string]string),
+		deps:  make(map[string][]string),
+	}
+}
+
+// addName 记录服务名并返回其小写键
+func (g *DependencyGraph) addName(name string) string {
+	key := strings.ToLower(name)
+	if _, exists := g.names[key]; !exists {
+		g.names[key] = name
+	}
+	return key
+}
+
+// SetDependencies 设置服务的依赖，替换之前的设置
+func (g *DependencyGraph) SetDependencies(name string, dependencies []string) {
+	key := strings.ToLower(name)
+	g.names[key] = name
+
+	deps := make([]string, 0, len(dependencies))
+	for _, dep := range dependencies {
+		if dep == "" || isServiceGroupDependency(dep) {
+			continue
+		}
+		deps = append(deps, g.addName(dep))
+	}
+	g.deps[key] = deps
+}
+
+// Contains 判断图中是否包含该服务
+func (g *DependencyGraph) Contains(name string) bool {
+	_, exists := g.names[strings.ToLower(name)]
+	return exists
+}
+
+// Dependencies 返回服务直接依赖的服务
+func (g *DependencyGraph) Dependencies(name string) []string {
+	return g.displayNames(g.deps[strings.ToLower(name)])
+}
+
+// Dependents 返回直接依赖该服务的服务，按名称排序
+func (g *DependencyGraph) Dependents(name string) []string {
+	target := strings.ToLower(name)
+
+	var dependents []string
+	for key, deps := range g.deps {
+		for _, dep := range deps {
+			if dep == target {
+				dependents = append(dependents, key)
+				break
+			}
+		}
+	}
+	sort.Strings(dependents)
+	return g.displayNames(dependents)
+}
+
+func (g *DependencyGraph) displayNames(keys []string) []string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		names = append(names, g.names[key])
+	}
+	return names
+}
+
+// sortedKeys 返回所有服务的小写键，按名称排序，保证遍历结果稳定
+func (g *DependencyGraph) sortedKeys() []string {
+	keys := make([]string, 0, len(g.names))
+	for key := range g.names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FindCycle 查找依赖环，返回环上的服务（首尾相同），不存在环时返回 nil
+func (g *DependencyGraph) FindCycle() []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(g.names))
+	var stack []string
+	var cycle []string
+
+	var visit func(key string) bool
+	visit = func(key string) bool {
+		state[key] = visiting
+		stack = append(stack, key)
+
+		for _, dep := range g.deps[key] {
+			switch state[dep] {
+			case visiting:
+				for i, k := range stack {
+					if k == dep {
+						cycle = append(append([]string{}, stack[i:]...), dep)
+						return true
+					}
+				}
+			case unvisited:
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+
+		stack = stack[:len(stack)-1]
+		state[key] = visited
+		return false
+	}
+
+	for _, key := range g.sortedKeys() {
+		if state[key] == unvisited && visit(key) {
+			return g.displayNames(cycle)
+		}
+	}
+	return nil
+}
+
+// cycleError 生成依赖环错误
+func cycleError(cycle []string) error {
+	return fmt.Errorf("服务依赖存在循环: %s", strings.Join(cycle, " -> "))
+}
+
+// StartOrder 返回启动服务时的顺序：被依赖的服务在前，目标服务在最后
+func (g *DependencyGraph) StartOrder(name string) ([]string, error) {
+	if cycle := g.FindCycle(); cycle != nil {
+		return nil, cycleError(cycle)
+	}
+
+	var order []string
+	seen := make(map[string]bool)
+
+	var visit func(key string)
+	visit = func(key string) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		for _, dep := range g.deps[key] {
+			visit(dep)
+		}
+		order = append(order, key)
+	}
+	visit(g.addName(name))
+
+	return g.displayNames(order), nil
+}
+
+// StopOrder 返回停止服务时的顺序：依赖目标服务的服务在前，目标服务在最后
+func (g *DependencyGraph) StopOrder(name string) ([]string, error) {
+	if cycle := g.FindCycle(); cycle != nil {
+		return nil, cycleError(cycle)
+	}
+
+	dependents := make(map[string][]string)
+	for _, key := range g.sortedKeys() {
+		for _, dep := range g.deps[key] {
+			dependents[dep] = append(dependents[dep], key)
+		}
+	}
+
+	var order []string
+	seen := make(map[string]bool)
+
+	var visit func(key string)
+	visit = func(key string) {
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		for _, dependent := range dependents[key] {
+			visit(dependent)
+		}
+		order = append(order, key)
+	}
+	visit(g.addName(name))
+
+	return g.displayNames(order), nil
+}
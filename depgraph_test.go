@@ -0,0 +1,94 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newTestGraph 按 服务 → 依赖 的映射创建依赖关系图，按服务名顺序添加，保证显示名称稳定
+func newTestGraph(dependencies map[string][]string) *DependencyGraph {
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	graph := NewDependencyGraph()
+	for _, name := range names {
+		graph.SetDependencies(name, dependencies[name])
+	}
+	return graph
+}
+
+func TestDependencyGraphFindCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		deps  map[string][]string
+		cycle []string
+	}{
+		{"empty", nil, nil},
+		{"chain", map[string][]string{"a": {"b"}, "b": {"c"}}, nil},
+		{"diamond", map[string][]string{"a": {"b", "c"}, "b": {"d"}, "c": {"d"}}, nil},
+		{"self", map[string][]string{"a": {"a"}}, []string{"a", "a"}},
+		{"two", map[string][]string{"a": {"b"}, "b": {"a"}}, []string{"a", "b", "a"}},
+		{"three", map[string][]string{"x": {"a"}, "a": {"b"}, "b": {"c"}, "c": {"a"}}, []string{"a", "b", "c", "a"}},
+		// 服务名不区分大小写，显示第一次出现的写法
+		{"case", map[string][]string{"A": {"b"}, "B": {"a"}}, []string{"A", "B", "A"}},
+		// 服务组不参与环检测
+		{"group", map[string][]string{"a": {"+Group"}, "+Group": {"a"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newTestGraph(tt.deps).FindCycle(); !reflect.DeepEqual(got, tt.cycle) {
+				t.Fatalf("FindCycle = %q, 期望 %q", got, tt.cycle)
+			}
+		})
+	}
+}
+
+func TestDependencyGraphOrder(t *testing.T) {
+	graph := newTestGraph(map[string][]string{
+		"Web":    {"api", "+NetworkProvider", ""},
+		"api":    {"DB", "cache"},
+		"cache":  {"db"},
+		"worker": {"db"},
+	})
+
+	start, err := graph.StartOrder("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"DB", "cache", "api", "Web"}; !reflect.DeepEqual(start, want) {
+		t.Fatalf("StartOrder = %q, 期望 %q", start, want)
+	}
+
+	stop, err := graph.StopOrder("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Web", "api", "cache", "worker", "DB"}; !reflect.DeepEqual(stop, want) {
+		t.Fatalf("StopOrder = %q, 期望 %q", stop, want)
+	}
+
+	if got := graph.Dependents("DB"); !reflect.DeepEqual(got, []string{"api", "cache", "worker"}) {
+		t.Fatalf("Dependents = %q", got)
+	}
+	if got := graph.Dependencies("web"); !reflect.DeepEqual(got, []string{"api"}) {
+		t.Fatalf("Dependencies = %q", got)
+	}
+
+	// 不在图中的服务只包含自身
+	if order, _ := graph.StartOrder("alone"); !reflect.DeepEqual(order, []string{"alone"}) {
+		t.Fatalf("StartOrder = %q", order)
+	}
+
+	graph.SetDependencies("db", []string{"web"})
+	if _, err := graph.StartOrder("web"); err == nil {
+		t.Fatalf("存在依赖环时 StartOrder 应返回错误")
+	}
+	if _, err := graph.StopOrder("web"); err == nil {
+		t.Fatalf("存在依赖环时 StopOrder 应返回错误")
+	}
+}
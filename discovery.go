@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// serviceWrapperFlag 以服务包装器模式运行时的命令行参数
+const serviceWrapperFlag = "--service-wrapper"
+
+// ReconcileReport 与SCM同步服务列表的结果
+type ReconcileReport struct {
+	Added    []string `json:"added"`    // 在SCM中发现、新加入列表的服务
+	Updated  []string `json:"updated"`  // 已在列表中、按SCM刷新配置的服务
+	Orphaned []string `json:"orphaned"` // 列表中存在但SCM中已不存在（或不再由包装器运行）的服务
+}
+
+// parseWrapperImagePath 检查服务的 ImagePath 是否为内置包装器模式，返回包装器参数中的服务名
+func parseWrapperImagePath(imagePath string) (string, bool) {
+	args := SplitCommandLine(imagePath)
+	if len(args) < 3 || args[1] != serviceWrapperFlag {
+		return "", false
+	}
+	return args[2], true
+}
+
+// isWrapperImagePath 判断 ImagePath 是否以包装器模式运行指定的服务
+func isWrapperImagePath(imagePath, serviceName string) bool {
+	wrapped, ok := parseWrapperImagePath(imagePath)
+	return ok && strings.EqualFold(wrapped, serviceName)
+}
+
+// isServiceNotExist 判断打开服务失败是否因为服务不存在
+func isServiceNotExist(err error) bool {
+	return errors.Is(err, ErrServiceDoesNotExist)
+}
+
+// ReconcileServices 以SCM为准同步服务列表：枚举以包装器模式运行的服务，
+// 从SCM配置和 Parameters 注册表重建服务记录，并重建已纳管的外部服务；
+// 列表中已不存在的服务标记为孤立。
+func (wsm *WindowsServiceManager) ReconcileServices() (*ReconcileReport, error) {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	report := &ReconcileReport{
+		Added:    []string{},
+		Updated:  []string{},
+		Orphaned: []string{},
+	}
+
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		names, err := scm.ListServices()
+		if err != nil {
+			return fmt.Errorf("枚举服务失败: %v", err)
+		}
+
+		found := make(map[string]bool)
+		for _, name := range names {
+			service, ok := wsm.discoverWrapperService(scm, name)
+			if !ok {
+				continue
+			}
+			found[service.ID] = true
+
+			if existing, exists := wsm.services[service.ID]; exists {
+				service.CreatedAt = existing.CreatedAt
+				*existing = *service
+				report.Updated = append(report.Updated, service.ID)
+			} else {
+				wsm.services[service.ID] = service
+				report.Added = append(report.Added, service.ID)
+			}
+		}
+
+		if err := wsm.reconcileExternalServices(scm, found, report); err != nil {
+			log.Printf("同步外部服务失败: %v", err)
+		}
+
+		for id, service := range wsm.services {
+			if !found[id] {
+				service.Orphaned = true
+				service.Status = "error"
+				service.PID = 0
+				report.Orphaned = append(report.Orphaned, id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(report.Added)
+	sort.Strings(report.Updated)
+	sort.Strings(report.Orphaned)
+
+	wsm.emitServicesUpdated()
+	return report, nil
+}
+
+// discoverWrapperService 检查SCM中的服务是否以包装器模式运行，是则重建其服务记录
+func (wsm *WindowsServiceManager) discoverWrapperService(scm ServiceControlBackend, name string) (*Service, bool) {
+	windowsService, err := scm.OpenService(name)
+	if err != nil {
+		return nil, false
+	}
+	defer windowsService.Close()
+
+	scmConfig, err := windowsService.Config()
+	if err != nil {
+		return nil, false
+	}
+
+	if !isWrapperImagePath(scmConfig.BinaryPathName, name) {
+		return nil, false
+	}
+
+	config, err := wsm.loadServiceConfig(name)
+	if err != nil {
+		log.Printf("读取服务 %s 的配置失败: %v", name, err)
+		return nil, false
+	}
+
+	config.Name = scmConfig.DisplayName
+	config.Description = scmConfig.Description
+	if config.Description == serviceDescription(ServiceConfig{Name: config.Name}) {
+		config.Description = ""
+	}
+	config.StartType = serviceStartTypeFromConfig(scmConfig)
+	config.Account = scmConfig.ServiceStartName
+	config.Dependencies = append([]string{}, scmConfig.Dependencies...)
+	if recovery, err := queryServiceRecovery(windowsService); err == nil {
+		config.Recovery = recovery
+	}
+
+	service := &Service{
+		ID:        name,
+		Kind:      ServiceKindWrapper,
+		CreatedAt: wsm.serviceCreatedAt(name),
+	}
+	service.applyConfig(*config)
+	service.Status, service.PID = wsm.getServiceRealTimeStatus(scm, name)
+
+	return service, true
+}
+
+// serviceCreatedAt 读取服务的创建时间，未记录时使用服务参数的最后修改时间
+func (wsm *WindowsServiceManager) serviceCreatedAt(serviceName string) time.Time {
+	store, err := wsm.parameters.Open(serviceName, false)
+	if err != nil {
+		return time.Time{}
+	}
+	defer store.Close()
+
+	return parameterCreatedAtValue(store)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// reopenTestManager 创建共享同一 FakeSCM 和参数存储的新服务管理器，模拟程序重新启动
+func reopenTestManager(scm *FakeSCM, parameters *MemoryParameterProvider) *WindowsServiceManager {
+	externalServices := func(writable bool) (ServiceParameterStore, error) {
+		return parameters.Open("ExternalServices", writable)
+	}
+	return NewWindowsServiceManagerWithBackend(scm.Connect, parameters, externalServices)
+}
+
+func TestParseWrapperImagePath(t *testing.T) {
+	tests := []struct {
+		imagePath string
+		name      string
+		ok        bool
+	}{
+		{`"C:\Program Files\Services\Services.exe" --service-wrapper WSM_App_1`, "WSM_App_1", true},
+		{`C:\Services.exe --service-wrapper "WSM App"`, "WSM App", true},
+		{`C:\Services.exe --service-wrapper`, "", false},
+		{`C:\Services.exe --other WSM_App_1`, "", false},
+		{`C:\Windows\System32\spoolsv.exe`, "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		name, ok := parseWrapperImagePath(tt.imagePath)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("parseWrapperImagePath(%q) = %q, %v", tt.imagePath, name, ok)
+		}
+	}
+
+	if !isWrapperImagePath(`C:\Services.exe --service-wrapper wsm_app_1`, "WSM_App_1") {
+		t.Errorf("服务名比较应不区分大小写")
+	}
+	if isWrapperImagePath(`C:\Services.exe --service-wrapper WSM_Other_1`, "WSM_App_1") {
+		t.Errorf("包装其他服务的 ImagePath 不应匹配")
+	}
+}
+
+func TestReconcileServices(t *testing.T) {
+	wsm, scm, parameters := newTestManager(t)
+
+	web, err := wsm.CreateService(testServiceConfig(t, "Web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	worker, err := wsm.CreateService(testServiceConfig(t, "Worker"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spooler, err := scm.CreateService("Spooler", SCMServiceConfig{BinaryPathName: `C:\Windows\System32\spoolsv.exe`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spooler.Close()
+	if _, err := wsm.AdoptExternalServices("Spooler", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// 重新启动后从SCM和参数存储重建服务列表
+	rebuilt := reopenTestManager(scm, parameters)
+	report, err := rebuilt.ReconcileServices()
+	if err != nil {
+		t.Fatalf("ReconcileServices: %v", err)
+	}
+	added := []string{web.ID, worker.ID, "Spooler"}
+	sort.Strings(added)
+	if !reflect.DeepEqual(report.Added, added) || len(report.Updated) != 0 || len(report.Orphaned) != 0 {
+		t.Fatalf("重建结果 = %+v", report)
+	}
+	if service := rebuilt.services[web.ID]; service.Kind != ServiceKindWrapper || service.Name != "Web" || service.ExePath != web.ExePath {
+		t.Fatalf("重建的包装器服务 = %+v", service)
+	}
+	if service := rebuilt.services["Spooler"]; !service.isExternal() || !service.ReadOnly || service.ExePath != `C:\Windows\System32\spoolsv.exe` {
+		t.Fatalf("重建的外部服务 = %+v", service)
+	}
+
+	// SCM中的配置被外部修改，Worker 不再由包装器运行
+	handle, err := scm.OpenService(web.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle.UpdateConfig(SCMServiceConfig{DisplayName: "Web Renamed"})
+	handle.Close()
+	handle, err = scm.OpenService(worker.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle.UpdateConfig(SCMServiceConfig{BinaryPathName: `C:\other\worker.exe`})
+	handle.Close()
+
+	original := rebuilt.services[web.ID]
+	report, err = rebuilt.ReconcileServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	updated := []string{web.ID, "Spooler"}
+	sort.Strings(updated)
+	if !reflect.DeepEqual(report.Updated, updated) || !reflect.DeepEqual(report.Orphaned, []string{worker.ID}) || len(report.Added) != 0 {
+		t.Fatalf("同步结果 = %+v", report)
+	}
+	if rebuilt.services[web.ID] != original || original.Name != "Web Renamed" {
+		t.Fatalf("更新应保留原服务记录并刷新配置: %+v", rebuilt.services[web.ID])
+	}
+	if service := rebuilt.services[worker.ID]; !service.Orphaned || service.Status != "error" {
+		t.Fatalf("不再由包装器运行的服务应标记为孤立: %+v", service)
+	}
+
+	// 刷新SCM配置时不应清除孤立标记
+	if _, err := rebuilt.RefreshServices(); err != nil {
+		t.Fatal(err)
+	}
+	if !rebuilt.services[worker.ID].Orphaned {
+		t.Fatalf("ImagePath 不是包装器时刷新后仍应为孤立")
+	}
+	if rebuilt.services[web.ID].Orphaned || rebuilt.services["Spooler"].Orphaned {
+		t.Fatalf("正常服务不应标记为孤立")
+	}
+
+	// 服务被删除后标记为孤立
+	if err := rebuilt.DeleteService("Spooler"); err != nil {
+		t.Fatal(err)
+	}
+	handle, err = scm.OpenService(web.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handle.Delete()
+	handle.Close()
+	report, err = rebuilt.ReconcileServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphaned := []string{web.ID, worker.ID}
+	sort.Strings(orphaned)
+	if !reflect.DeepEqual(report.Orphaned, orphaned) || len(report.Updated) != 0 {
+		t.Fatalf("删除后的同步结果 = %+v", report)
+	}
+	if _, exists := rebuilt.services["Spooler"]; exists {
+		t.Fatalf("取消纳管的外部服务不应被重建")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DotenvVar .env 文件中的一个变量
+type DotenvVar struct {
+	Name  string
+	Value string
+}
+
+// LoadDotenvFile 读取并解析 .env 文件
+func LoadDotenvFile(path string, lookup func(string) (string, bool)) ([]DotenvVar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开环境变量文件失败: %v", err)
+	}
+	defer file.Close()
+
+	vars, err := ParseDotenv(file, lookup)
+	if err != nil {
+		return nil, fmt.Errorf("解析环境变量文件 %s 失败: %v", path, err)
+	}
+	return vars, nil
+}
+
+// ParseDotenv 解析 .env 格式的内容，支持：
+//   - 空行和以 # 开头的注释行，以及未加引号的值后的 " #" 行尾注释
+//   - 可选的 "export " 前缀
+//   - 单引号值按字面处理；双引号值支持 \n、\r、\t、\"、\\、\$ 转义
+//   - 引号内的值可以跨越多行
+//   - 未加引号和双引号的值中的 $VAR、${VAR}、${VAR:-默认值} 插值，
+//     先查找文件中已定义的变量，再通过 lookup 查找
+func ParseDotenv(r io.Reader, lookup func(string) (string, bool)) ([]DotenvVar, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	parser := &dotenvParser{
+		input:  strings.ReplaceAll(strings.TrimPrefix(string(data), "\uFEFF"), "\r\n", "\n"),
+		line:   1,
+		lookup: lookup,
+		seen:   make(map[string]string),
+	}
+	return parser.parse()
+}
+
+// dotenvParser .env 内容解析器
+type dotenvParser struct {
+	input  string
+	pos    int
+	line   int
+	lookup func(string) (string, bool)
+	vars   []DotenvVar
+	seen   map[string]string
+}
+
+func (p *dotenvParser) parse() ([]DotenvVar, error) {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return p.vars, nil
+		}
+
+		if p.peek() == '#' {
+			p.skipLine()
+			continue
+		}
+
+		line := p.line
+		name := p.readName()
+		if name == "export" && (p.peek() == ' ' || p.peek() == '\t') {
+			p.skipSpaces()
+			name = p.readName()
+		}
+		if name == "" {
+			return nil, fmt.Errorf("第%d行: 无效的变量名", line)
+		}
+
+		p.skipSpaces()
+		if p.eof() || p.peek() != '=' {
+			return nil, fmt.Errorf("第%d行: 变量 %s 缺少 =", line, name)
+		}
+		p.pos++
+		p.skipSpaces()
+
+		value, err := p.readValue()
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", line, err)
+		}
+
+		p.vars = append(p.vars, DotenvVar{Name: name, Value: value})
+		p.seen[name] = value
+	}
+}
+
+// readValue 读取等号后的值
+func (p *dotenvParser) readValue() (string, error) {
+	if p.eof() {
+		return "", nil
+	}
+
+	switch quote := p.peek(); quote {
+	case '\'', '"':
+		p.pos++
+		var b strings.Builder
+		for {
+			if p.eof() {
+				return "", fmt.Errorf("引号未闭合")
+			}
+			c := p.next()
+			if c == quote {
+				break
+			}
+			if quote == '"' && c == '\\' && !p.eof() {
+				switch e := p.next(); e {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\':
+					b.WriteByte(e)
+				case '$':
+					// 使用 NUL 标记转义的 $，插值时输出字面 $（环境变量值中不会出现 NUL）
+					b.WriteByte(0)
+				default:
+					b.WriteByte('\\')
+					b.WriteByte(e)
+				}
+				continue
+			}
+			b.WriteByte(c)
+		}
+
+		p.skipSpaces()
+		if !p.eof() && p.peek() != '\n' && p.peek() != '#' {
+			return "", fmt.Errorf("引号后存在多余内容")
+		}
+		p.skipLine()
+
+		if quote == '\'' {
+			return b.String(), nil
+		}
+		return p.interpolate(b.String()), nil
+	default:
+		start := p.pos
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+		value := p.input[start:p.pos]
+		if idx := strings.Index(value, " #"); idx >= 0 {
+			value = value[:idx]
+		}
+		if idx := strings.Index(value, "\t#"); idx >= 0 {
+			value = value[:idx]
+		}
+		return p.interpolate(strings.TrimSpace(value)), nil
+	}
+}
+
+// interpolate 展开 $VAR、${VAR} 和 ${VAR:-默认值}
+func (p *dotenvParser) interpolate(value string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c == 0 {
+			b.WriteByte('$')
+			continue
+		}
+		if c != '$' || i+1 >= len(value) {
+			b.WriteByte(c)
+			continue
+		}
+
+		if value[i+1] == '{' {
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			expr := value[i+2 : i+2+end]
+			name, fallback, hasFallback := strings.Cut(expr, ":-")
+			if resolved, ok := p.resolve(name); ok && (resolved != "" || !hasFallback) {
+				b.WriteString(resolved)
+			} else if hasFallback {
+				b.WriteString(fallback)
+			}
+			i += end + 2
+			continue
+		}
+
+		j := i + 1
+		for j < len(value) && isDotenvNameChar(value[j], j == i+1) {
+			j++
+		}
+		if j == i+1 {
+			b.WriteByte(c)
+			continue
+		}
+		resolved, _ := p.resolve(value[i+1 : j])
+		b.WriteString(resolved)
+		i = j - 1
+	}
+
+	return b.String()
+}
+
+// resolve 查找变量值，文件中已定义的变量优先
+func (p *dotenvParser) resolve(name string) (string, bool) {
+	if value, ok := p.seen[name]; ok {
+		return value, true
+	}
+	if p.lookup != nil {
+		return p.lookup(name)
+	}
+	return "", false
+}
+
+func (p *dotenvParser) readName() string {
+	start := p.pos
+	for !p.eof() && isDotenvNameChar(p.peek(), p.pos == start) {
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+func (p *dotenvParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r':
+			p.pos++
+		case '\n':
+			p.pos++
+			p.line++
+		default:
+			return
+		}
+	}
+}
+
+func (p *dotenvParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) skipLine() {
+	for !p.eof() && p.peek() != '\n' {
+		p.pos++
+	}
+}
+
+func (p *dotenvParser) eof() bool { return p.pos >= len(p.input) }
+
+func (p *dotenvParser) peek() byte { return p.input[p.pos] }
+
+func (p *dotenvParser) next() byte {
+	c := p.input[p.pos]
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+// isDotenvNameChar 判断字符是否可用于变量名，首字符不能是数字
+func isDotenvNameChar(c byte, first bool) bool {
+	switch {
+	case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		return true
+	case c >= '0' && c <= '9':
+		return !first
+	default:
+		return false
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	env := map[string]string{"HOME": "/home/svc", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []DotenvVar
+	}{
+		{"empty", "", nil},
+		{"basic", "A=1\nB = two \n", []DotenvVar{{"A", "1"}, {"B", "two"}}},
+		{"empty value", "A=\nB=\"\"\nC=''", []DotenvVar{{"A", ""}, {"B", ""}, {"C", ""}}},
+		{"crlf and bom", "\uFEFFA=1\r\nB=2\r\n", []DotenvVar{{"A", "1"}, {"B", "2"}}},
+		{"comments", "# comment\n  # indented\nA=1 # trailing\nB=2\t# tab\nC=a#b\n", []DotenvVar{{"A", "1"}, {"B", "2"}, {"C", "a#b"}}},
+		{"quoted comment", `A="x # y" # real comment`, []DotenvVar{{"A", "x # y"}}},
+		{"export", "export A=1\nexport\tB=2\nexport=3\n", []DotenvVar{{"A", "1"}, {"B", "2"}, {"export", "3"}}},
+		{"single quotes", `A='$HOME \n "x"'`, []DotenvVar{{"A", `$HOME \n "x"`}}},
+		{"double quote escapes", `A="l1\nl2\tt\r\"q\" \\ \$HOME \x"`, []DotenvVar{{"A", "l1\nl2\tt\r\"q\" \\ $HOME \\x"}}},
+		{"multiline", "A=\"line1\nline2\"\nB='x\ny'\n", []DotenvVar{{"A", "line1\nline2"}, {"B", "x\ny"}}},
+		{"interpolation", "A=$HOME/bin\nB=${HOME}_x\nC=\"$A:${B}\"\nD=$MISSING.\n", []DotenvVar{
+			{"A", "/home/svc/bin"}, {"B", "/home/svc_x"}, {"C", "/home/svc/bin:/home/svc_x"}, {"D", "."},
+		}},
+		{"defaults", "A=${MISSING:-fallback}\nB=${EMPTY:-used}\nC=${HOME:-unused}\nD=${EMPTY}", []DotenvVar{
+			{"A", "fallback"}, {"B", "used"}, {"C", "/home/svc"}, {"D", ""},
+		}},
+		{"file vars win", "HOME=/override\nA=$HOME", []DotenvVar{{"HOME", "/override"}, {"A", "/override"}}},
+		{"literal dollar", "A=cost $5 and $\nB=${unclosed\n", []DotenvVar{{"A", "cost $5 and $"}, {"B", "${unclosed"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDotenv(strings.NewReader(tt.input), lookup)
+			if err != nil {
+				t.Fatalf("ParseDotenv: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ParseDotenv = %q\n期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDotenvErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		line  string
+	}{
+		{"A=1\n1BAD=2", "第2行"},
+		{"A=1\n\nNOEQUALS\n", "第3行"},
+		{"=value", "第1行"},
+		{"A=\"unterminated\nB=2", "第1行"},
+		{"A='x' extra", "第1行"},
+		{"A=1\nB=\"multi\nline\" junk", "第2行"},
+		{"export A", "第1行"},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseDotenv(strings.NewReader(tt.input), nil)
+		if err == nil {
+			t.Errorf("ParseDotenv(%q) 应返回错误", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.line) {
+			t.Errorf("ParseDotenv(%q) 错误 %q 未包含 %s", tt.input, err, tt.line)
+		}
+	}
+}
+
+func TestLoadDotenvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	if err := os.WriteFile(path, []byte("A=1\nB=$A$A\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := LoadDotenvFile(path, nil)
+	if err != nil {
+		t.Fatalf("LoadDotenvFile: %v", err)
+	}
+	if want := []DotenvVar{{"A", "1"}, {"B", "11"}}; !reflect.DeepEqual(vars, want) {
+		t.Fatalf("LoadDotenvFile = %q", vars)
+	}
+
+	if _, err := LoadDotenvFile(filepath.Join(t.TempDir(), "missing.env"), nil); err == nil {
+		t.Fatalf("文件不存在时应返回错误")
+	}
+	os.WriteFile(path, []byte("BAD"), 0644)
+	if _, err := LoadDotenvFile(path, nil); err == nil || !strings.Contains(err.Error(), path) {
+		t.Fatalf("解析错误应包含文件路径: %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExitActionKind 目标程序退出后执行的动作类型
+type ExitActionKind string
+
+const (
+	ExitActionRestart  ExitActionKind = "restart"   // 重启目标程序（仍受重启次数和退避限制）
+	ExitActionIgnore   ExitActionKind = "ignore"    // 不重启，服务保持运行状态
+	ExitActionExit     ExitActionKind = "exit"      // 停止服务，非0退出码作为服务特定错误码上报
+	ExitActionExitCode ExitActionKind = "exit-code" // 停止服务，并上报指定的服务特定错误码
+)
+
+// exitActionDefaultKey 退出动作表中默认动作的键名
+const exitActionDefaultKey = "default"
+
+// ExitAction 单个退出动作
+type ExitAction struct {
+	Kind ExitActionKind
+	Code uint32 // 仅 ExitActionExitCode 使用
+}
+
+// String 返回退出动作的存储格式，如 "restart"、"exit-code:42"
+func (action ExitAction) String() string {
+	if action.Kind == ExitActionExitCode {
+		return fmt.Sprintf("%s:%d", action.Kind, action.Code)
+	}
+	return string(action.Kind)
+}
+
+// ExitActionTable 退出码到退出动作的映射表
+type ExitActionTable struct {
+	Default *ExitAction
+	Codes   map[int]ExitAction
+}
+
+// ParseExitAction 解析单个退出动作
+func ParseExitAction(text string) (ExitAction, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	kind, codeText, hasCode := strings.Cut(text, ":")
+	switch ExitActionKind(kind) {
+	case ExitActionRestart, ExitActionIgnore, ExitActionExit:
+		if hasCode {
+			return ExitAction{}, fmt.Errorf("退出动作 %s 不支持错误码", kind)
+		}
+		return ExitAction{Kind: ExitActionKind(kind)}, nil
+	case ExitActionExitCode:
+		if !hasCode {
+			return ExitAction{}, fmt.Errorf("退出动作 %s 缺少错误码", kind)
+		}
+		code, err := strconv.ParseUint(codeText, 10, 32)
+		if err != nil {
+			return ExitAction{}, fmt.Errorf("无效的服务错误码: %s", codeText)
+		}
+		return ExitAction{Kind: ExitActionExitCode, Code: uint32(code)}, nil
+	default:
+		return ExitAction{}, fmt.Errorf("不支持的退出动作: %s", kind)
+	}
+}
+
+// ParseExitActions 解析退出动作表，每项格式为 "<退出码>=<动作>" 或 "default=<动作>"
+func ParseExitActions(entries []string) (ExitActionTable, error) {
+	table := ExitActionTable{Codes: make(map[int]ExitAction)}
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return ExitActionTable{}, fmt.Errorf("无效的退出动作配置: %s", entry)
+		}
+
+		action, err := ParseExitAction(value)
+		if err != nil {
+			return ExitActionTable{}, err
+		}
+
+		key = strings.TrimSpace(key)
+		if strings.EqualFold(key, exitActionDefaultKey) {
+			table.Default = &action
+			continue
+		}
+
+		// 支持十进制、十六进制（如 0xC0000005）以及负数形式的退出码
+		code, err := strconv.ParseInt(key, 0, 64)
+		if err != nil || code < -1<<31 || code > 1<<32-1 {
+			return ExitActionTable{}, fmt.Errorf("无效的退出码: %s", key)
+		}
+		table.Codes[int(uint32(code))] = action
+	}
+
+	return table, nil
+}
+
+// Lookup 查找退出码对应的动作，未配置时返回 false
+func (table ExitActionTable) Lookup(exitCode int) (ExitAction, bool) {
+	if action, ok := table.Codes[exitCode]; ok {
+		return action, true
+	}
+	if table.Default != nil {
+		return *table.Default, true
+	}
+	return ExitAction{}, false
+}
+
+// Entries 将退出动作表转换为存储格式，按退出码排序
+func (table ExitActionTable) Entries() []string {
+	codes := make([]int, 0, len(table.Codes))
+	for code := range table.Codes {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	entries := make([]string, 0, len(codes)+1)
+	if table.Default != nil {
+		entries = append(entries, fmt.Sprintf("%s=%s", exitActionDefaultKey, table.Default))
+	}
+	for _, code := range codes {
+		entries = append(entries, fmt.Sprintf("%d=%s", code, table.Codes[code]))
+	}
+	return entries
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExitAction(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ExitAction
+	}{
+		{"restart", ExitAction{Kind: ExitActionRestart}},
+		{" Ignore ", ExitAction{Kind: ExitActionIgnore}},
+		{"EXIT", ExitAction{Kind: ExitActionExit}},
+		{"exit-code:42", ExitAction{Kind: ExitActionExitCode, Code: 42}},
+		{"exit-code:4294967295", ExitAction{Kind: ExitActionExitCode, Code: 4294967295}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseExitAction(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseExitAction(%q) = %+v, %v; 期望 %+v", tt.input, got, err, tt.want)
+			continue
+		}
+		if reparsed, err := ParseExitAction(got.String()); err != nil || reparsed != got {
+			t.Errorf("ParseExitAction(%q.String()) = %+v, %v", tt.input, reparsed, err)
+		}
+	}
+
+	for _, input := range []string{"", "stop", "restart:1", "exit-code", "exit-code:", "exit-code:-1", "exit-code:4294967296", "exit-code:abc"} {
+		if _, err := ParseExitAction(input); err == nil {
+			t.Errorf("ParseExitAction(%q) 应返回错误", input)
+		}
+	}
+}
+
+func TestParseExitActions(t *testing.T) {
+	table, err := ParseExitActions([]string{
+		"0=exit",
+		"",
+		" 1 = ignore ",
+		"0xC0000005=restart",
+		"-1=exit-code:7",
+		"2147483647=ignore",
+		"-2147483648=exit",
+		"Default=exit-code:99",
+	})
+	if err != nil {
+		t.Fatalf("ParseExitActions: %v", err)
+	}
+
+	wantCodes := map[int]ExitAction{
+		0:                    {Kind: ExitActionExit},
+		1:                    {Kind: ExitActionIgnore},
+		0xC0000005:           {Kind: ExitActionRestart},
+		0xFFFFFFFF:           {Kind: ExitActionExitCode, Code: 7},
+		2147483647:           {Kind: ExitActionIgnore},
+		int(uint32(1 << 31)): {Kind: ExitActionExit},
+	}
+	if !reflect.DeepEqual(table.Codes, wantCodes) {
+		t.Fatalf("Codes = %+v\n期望 %+v", table.Codes, wantCodes)
+	}
+	if table.Default == nil || *table.Default != (ExitAction{Kind: ExitActionExitCode, Code: 99}) {
+		t.Fatalf("Default = %+v", table.Default)
+	}
+
+	// 负数和十六进制写法表示同一个 32 位退出码
+	negative, err := ParseExitActions([]string{"-1073741819=restart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(negative.Codes, map[int]ExitAction{0xC0000005: {Kind: ExitActionRestart}}) {
+		t.Fatalf("负数退出码 = %+v", negative.Codes)
+	}
+
+	reparsed, err := ParseExitActions(table.Entries())
+	if err != nil || !reflect.DeepEqual(reparsed, table) {
+		t.Fatalf("Entries 往返 = %+v, %v", reparsed, err)
+	}
+}
+
+func TestParseExitActionsErrors(t *testing.T) {
+	tests := [][]string{
+		{"restart"},
+		{"=restart"},
+		{"abc=restart"},
+		{"1.5=restart"},
+		{"0x100000000=restart"},
+		{"-2147483649=restart"},
+		{"1=stop"},
+		{"default=exit-code"},
+		{"0=exit", "1=bogus"},
+	}
+
+	for _, entries := range tests {
+		if _, err := ParseExitActions(entries); err == nil {
+			t.Errorf("ParseExitActions(%q) 应返回错误", entries)
+		}
+	}
+}
+
+func TestExitActionTableLookup(t *testing.T) {
+	table, err := ParseExitActions([]string{"0=exit", "0xC0000005=restart", "-1=ignore"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		code       int
+		want       ExitAction
+		configured bool
+	}{
+		{0, ExitAction{Kind: ExitActionExit}, true},
+		{0xC0000005, ExitAction{Kind: ExitActionRestart}, true},
+		{int(uint32(0xFFFFFFFF)), ExitAction{Kind: ExitActionIgnore}, true},
+		{1, ExitAction{}, false},
+	}
+	for _, tt := range tests {
+		if got, ok := table.Lookup(tt.code); got != tt.want || ok != tt.configured {
+			t.Errorf("Lookup(%d) = %+v, %v", tt.code, got, ok)
+		}
+	}
+
+	table.Default = &ExitAction{Kind: ExitActionExitCode, Code: 5}
+	if got, ok := table.Lookup(1); !ok || got != *table.Default {
+		t.Errorf("未配置的退出码应使用默认动作: %+v, %v", got, ok)
+	}
+	if got, ok := table.Lookup(0xC0000005); !ok || got.Kind != ExitActionRestart {
+		t.Errorf("已配置的退出码优先于默认动作: %+v, %v", got, ok)
+	}
+
+	if _, ok := (ExitActionTable{}).Lookup(0); ok {
+		t.Errorf("空表不应匹配任何退出码")
+	}
+}
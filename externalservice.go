@@ -0,0 +1,351 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ServiceKind 服务类型
+type ServiceKind string
+
+const (
+	ServiceKindWrapper  ServiceKind = "wrapper"  // 由本程序创建、以内置包装器运行的服务
+	ServiceKindExternal ServiceKind = "external" // 已有的其他Windows服务，只监控和启停，不修改配置
+)
+
+// ExternalServiceInfo 可纳管的外部服务
+type ExternalServiceInfo struct {
+	Name        string           `json:"name"`
+	DisplayName string           `json:"displayName"`
+	StartType   ServiceStartType `json:"startType"`
+	Status      string           `json:"status"`
+	Managed     bool             `json:"managed"` // 已纳管
+}
+
+// isExternal 判断是否为外部服务
+func (service *Service) isExternal() bool {
+	return service.Kind == ServiceKindExternal
+}
+
+// checkConfigurable 外部服务不支持修改配置
+func (service *Service) checkConfigurable() error {
+	if service.isExternal() {
+		return fmt.Errorf("外部服务不支持修改配置: %s", service.ID)
+	}
+	return nil
+}
+
+// checkControllable 只读的外部服务不支持启动和停止
+func (service *Service) checkControllable() error {
+	if service.isExternal() && service.ReadOnly {
+		return fmt.Errorf("外部服务为只读: %s", service.ID)
+	}
+	return nil
+}
+
+// matchServicePattern 按通配符（* 和 ?）匹配服务名或显示名称，不区分大小写，空模式匹配所有服务
+func matchServicePattern(pattern, name, displayName string) bool {
+	if pattern == "" {
+		return true
+	}
+	pattern = strings.ToLower(pattern)
+	for _, candidate := range []string{name, displayName} {
+		if matched, err := path.Match(pattern, strings.ToLower(candidate)); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// loadExternalServiceNames 读取已纳管的外部服务，返回服务名到只读标志的映射
+func (wsm *WindowsServiceManager) loadExternalServiceNames() (map[string]bool, error) {
+	store, err := wsm.externalServices(false)
+	if errors.Is(err, errParametersNotExist) {
+		return map[string]bool{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开外部服务列表失败: %v", err)
+	}
+	defer store.Close()
+
+	names, err := store.Names()
+	if err != nil {
+		return nil, fmt.Errorf("读取外部服务列表失败: %v", err)
+	}
+
+	services := make(map[string]bool, len(names))
+	for _, name := range names {
+		value, _, err := store.Get(name)
+		services[name] = err == nil && value.isInteger() && value.Number != 0
+	}
+	return services, nil
+}
+
+// storeExternalService 记录纳管的外部服务
+func (wsm *WindowsServiceManager) storeExternalService(name string, readOnly bool) error {
+	store, err := wsm.externalServices(true)
+	if err != nil {
+		return fmt.Errorf("打开外部服务列表失败: %v", err)
+	}
+	defer store.Close()
+
+	value := DWordParameter(0)
+	if readOnly {
+		value = DWordParameter(1)
+	}
+	if err := store.Set(name, value); err != nil {
+		return fmt.Errorf("保存外部服务失败: %v", err)
+	}
+	return nil
+}
+
+// removeExternalService 删除纳管的外部服务记录
+func (wsm *WindowsServiceManager) removeExternalService(name string) error {
+	store, err := wsm.externalServices(true)
+	if err != nil {
+		return fmt.Errorf("打开外部服务列表失败: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.Delete(name); err != nil {
+		return fmt.Errorf("删除外部服务失败: %v", err)
+	}
+	return nil
+}
+
+// buildExternalService 从SCM读取外部服务的配置和状态。服务已不存在时返回标记为孤立的记录。
+func (wsm *WindowsServiceManager) buildExternalService(scm ServiceControlBackend, name string, readOnly bool) (*Service, error) {
+	service := &Service{
+		ID:        name,
+		Name:      name,
+		Kind:      ServiceKindExternal,
+		ReadOnly:  readOnly,
+		UpdatedAt: time.Now(),
+	}
+
+	windowsService, err := scm.OpenService(name)
+	if isServiceNotExist(err) {
+		service.Orphaned = true
+		service.Status = "error"
+		return service, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开服务失败: %v", err)
+	}
+	defer windowsService.Close()
+
+	config, err := windowsService.Config()
+	if err != nil {
+		return nil, fmt.Errorf("获取服务配置失败: %v", err)
+	}
+
+	service.Name = config.DisplayName
+	service.Description = config.Description
+	service.StartType = serviceStartTypeFromConfig(config)
+	service.Account = config.ServiceStartName
+	service.Dependencies = config.Dependencies
+	if args := SplitCommandLine(config.BinaryPathName); len(args) > 0 {
+		service.ExePath = args[0]
+		service.ArgList = args[1:]
+		service.Args = JoinCommandLine(args[1:])
+	}
+	if recovery, err := queryServiceRecovery(windowsService); err == nil {
+		service.Recovery = recovery
+	}
+	service.Status, service.PID = wsm.getServiceRealTimeStatus(scm, name)
+
+	return service, nil
+}
+
+// ListExternalServices 列出可纳管的外部服务，按通配符过滤服务名或显示名称。
+// 以内置包装器运行的服务不在列表中。
+func (wsm *WindowsServiceManager) ListExternalServices(pattern string) ([]ExternalServiceInfo, error) {
+	wsm.mutex.RLock()
+	defer wsm.mutex.RUnlock()
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("无效的服务名模式: %s", pattern)
+	}
+
+	var services []ExternalServiceInfo
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		var err error
+		services, err = wsm.listExternalServices(scm, pattern)
+		return err
+	})
+	return services, err
+}
+
+// listExternalServices 枚举SCM中匹配模式的非包装器服务
+func (wsm *WindowsServiceManager) listExternalServices(scm ServiceControlBackend, pattern string) ([]ExternalServiceInfo, error) {
+	names, err := scm.ListServices()
+	if err != nil {
+		return nil, fmt.Errorf("枚举服务失败: %v", err)
+	}
+	sort.Strings(names)
+
+	services := []ExternalServiceInfo{}
+	for _, name := range names {
+		windowsService, err := scm.OpenService(name)
+		if err != nil {
+			continue
+		}
+		config, err := windowsService.Config()
+		windowsService.Close()
+		if err != nil {
+			continue
+		}
+
+		if _, isWrapper := parseWrapperImagePath(config.BinaryPathName); isWrapper {
+			continue
+		}
+		if !matchServicePattern(pattern, name, config.DisplayName) {
+			continue
+		}
+
+		status, _ := wsm.getServiceRealTimeStatus(scm, name)
+		managed, exists := wsm.services[name]
+		services = append(services, ExternalServiceInfo{
+			Name:        name,
+			DisplayName: config.DisplayName,
+			StartType:   serviceStartTypeFromConfig(config),
+			Status:      status,
+			Managed:     exists && managed.isExternal(),
+		})
+	}
+	return services, nil
+}
+
+// AdoptExternalServices 纳管匹配模式的外部服务。readOnly 为 true 时只监控状态，
+// 否则允许启动和停止；外部服务的配置始终不会被修改。已纳管的服务只更新只读标志。
+func (wsm *WindowsServiceManager) AdoptExternalServices(pattern string, readOnly bool) ([]*Service, error) {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("无效的服务名模式: %s", pattern)
+	}
+
+	adopted := []*Service{}
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		candidates, err := wsm.listExternalServices(scm, pattern)
+		if err != nil {
+			return err
+		}
+		if len(candidates) == 0 {
+			return fmt.Errorf("没有匹配的服务: %s", pattern)
+		}
+
+		for _, candidate := range candidates {
+			if existing, exists := wsm.services[candidate.Name]; exists && !existing.isExternal() {
+				continue
+			}
+
+			service, err := wsm.buildExternalService(scm, candidate.Name, readOnly)
+			if err != nil {
+				return err
+			}
+			if err := wsm.storeExternalService(candidate.Name, readOnly); err != nil {
+				return err
+			}
+
+			if existing, exists := wsm.services[service.ID]; exists {
+				service.CreatedAt = existing.CreatedAt
+				*existing = *service
+				service = existing
+			} else {
+				service.CreatedAt = time.Now()
+				wsm.services[service.ID] = service
+			}
+			adopted = append(adopted, service)
+		}
+		return nil
+	})
+
+	if len(adopted) > 0 {
+		wsm.emitServicesUpdated()
+	}
+	return adopted, err
+}
+
+// SetExternalServiceReadOnly 设置外部服务是否只读
+func (wsm *WindowsServiceManager) SetExternalServiceReadOnly(serviceID string, readOnly bool) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists || !service.isExternal() {
+		return fmt.Errorf("外部服务不存在: %s", serviceID)
+	}
+
+	if err := wsm.storeExternalService(serviceID, readOnly); err != nil {
+		return err
+	}
+
+	service.ReadOnly = readOnly
+	service.UpdatedAt = time.Now()
+	wsm.emitServicesUpdated()
+
+	return nil
+}
+
+// releaseExternalServiceLocked 取消纳管外部服务，只删除记录，不影响服务本身
+func (wsm *WindowsServiceManager) releaseExternalServiceLocked(serviceID string) error {
+	if err := wsm.removeExternalService(serviceID); err != nil {
+		return err
+	}
+
+	delete(wsm.services, serviceID)
+	delete(wsm.refreshedAt, serviceID)
+	wsm.statusCache.Remove(serviceID)
+	wsm.emitServicesUpdated()
+
+	return nil
+}
+
+// reconcileExternalServices 按注册表中的纳管记录重建外部服务，found 记录已同步的服务
+func (wsm *WindowsServiceManager) reconcileExternalServices(scm ServiceControlBackend, found map[string]bool, report *ReconcileReport) error {
+	external, err := wsm.loadExternalServiceNames()
+	if err != nil {
+		return err
+	}
+
+	for name, readOnly := range external {
+		if found[name] {
+			continue
+		}
+
+		service, err := wsm.buildExternalService(scm, name, readOnly)
+		if err != nil {
+			// 暂时无法读取时保留原记录
+			found[name] = true
+			continue
+		}
+		found[service.ID] = true
+
+		existing, exists := wsm.services[service.ID]
+		switch {
+		case service.Orphaned:
+			if exists {
+				existing.Orphaned = true
+				existing.Status = "error"
+				existing.PID = 0
+			} else {
+				wsm.services[service.ID] = service
+			}
+			report.Orphaned = append(report.Orphaned, service.ID)
+		case exists:
+			service.CreatedAt = existing.CreatedAt
+			*existing = *service
+			report.Updated = append(report.Updated, service.ID)
+		default:
+			wsm.services[service.ID] = service
+			report.Added = append(report.Added, service.ID)
+		}
+	}
+	return nil
+}
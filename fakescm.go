@@ -0,0 +1,539 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fakeWaitHint 模拟服务在挂起状态下报告的等待提示
+const fakeWaitHint = 2 * time.Second
+
+// FakeServiceBehavior 模拟服务在启动和停止时的行为
+type FakeServiceBehavior struct {
+	StartPending time.Duration // 启动时停留在 StartPending 的时间
+	StopPending  time.Duration // 停止时停留在 StopPending 的时间
+	StartError   error         // StartService 直接返回的错误
+	FailOnStart  bool          // 启动过程中失败，回到 Stopped 并报告 ExitCode
+	ExitCode     uint32        // 启动失败或崩溃时报告的服务特定错误码
+	HangOnStart  bool          // 一直停留在 StartPending，模拟启动超时
+	HangOnStop   bool          // 一直停留在 StopPending，模拟停止超时
+}
+
+// FakeSCM 内存中的模拟服务控制管理器，按SCM的规则模拟服务状态转换，
+// 挂起状态按时钟推进，不依赖真实的Windows服务
+type FakeSCM struct {
+	mutex        sync.Mutex
+	services     map[string]*fakeService // 小写服务名 → 服务
+	now          func() time.Time
+	nextPID      uint32
+	logonRights  map[string]bool // 已授予"作为服务登录"权限的账户（小写）
+	eventSources map[string]bool // 已注册的事件源（小写）
+
+	// DefaultBehavior 新创建服务的默认行为
+	DefaultBehavior FakeServiceBehavior
+	// RegisterEventSourceError RegisterEventSource 返回的错误，用于模拟事件源注册失败
+	RegisterEventSourceError error
+}
+
+// fakeService 模拟的服务
+type fakeService struct {
+	name            string
+	config          SCMServiceConfig
+	recovery        RecoveryConfig
+	behavior        FakeServiceBehavior
+	state           ServiceState
+	target          ServiceState // 挂起状态结束后进入的状态
+	until           time.Time    // 挂起状态结束的时间
+	checkPoint      uint32
+	pid             uint32
+	win32ExitCode   uint32
+	serviceExitCode uint32
+	markedForDelete bool
+	handles         int
+}
+
+// NewFakeSCM 创建空的模拟服务控制管理器
+func NewFakeSCM() *FakeSCM {
+	return &FakeSCM{
+		services:     make(map[string]*fakeService),
+		now:          time.Now,
+		nextPID:      1000,
+		logonRights:  make(map[string]bool),
+		eventSources: make(map[string]bool),
+	}
+}
+
+// SetClock 设置模拟使用的时钟，便于控制挂起状态的推进
+func (f *FakeSCM) SetClock(now func() time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// Connect 返回模拟后端，可作为 NewWindowsServiceManagerWithBackend 的连接函数
+func (f *FakeSCM) Connect() (ServiceControlBackend, error) {
+	return f, nil
+}
+
+// SetBehavior 设置服务的模拟行为，在下一次启动或停止时生效
+func (f *FakeSCM) SetBehavior(name string, behavior FakeServiceBehavior) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	service, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	service.behavior = behavior
+	return nil
+}
+
+// Crash 模拟运行中的服务意外退出
+func (f *FakeSCM) Crash(name string, exitCode uint32) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	service, err := f.lookup(name)
+	if err != nil {
+		return err
+	}
+	f.advance(service)
+	if service.state != StateRunning {
+		return ErrServiceNotActive
+	}
+	service.stop(exitCode)
+	f.sweep()
+	return nil
+}
+
+// Exists 判断服务是否存在（包括已标记删除但尚未移除的服务）
+func (f *FakeSCM) Exists(name string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sweep()
+	_, exists := f.services[strings.ToLower(name)]
+	return exists
+}
+
+// CreateService 创建模拟服务，config.BinaryPathName 原样保存为命令行
+func (f *FakeSCM) CreateService(name string, config SCMServiceConfig) (ServiceHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sweep()
+	if existing, exists := f.services[strings.ToLower(name)]; exists {
+		if existing.markedForDelete {
+			return nil, ErrServiceMarkedForDelete
+		}
+		return nil, ErrServiceExists
+	}
+
+	if config.StartType == 0 {
+		config.StartType = scmStartManual
+	}
+	if config.DisplayName == "" {
+		config.DisplayName = name
+	}
+	if config.ServiceStartName == "" {
+		config.ServiceStartName = ServiceAccountLocalSystem
+	}
+	config.Dependencies = append([]string(nil), config.Dependencies...)
+	config.Password = ""
+
+	service := &fakeService{
+		name:     name,
+		config:   config,
+		behavior: f.DefaultBehavior,
+		state:    StateStopped,
+	}
+	f.services[strings.ToLower(name)] = service
+
+	return f.open(service), nil
+}
+
+// OpenService 打开模拟服务
+func (f *FakeSCM) OpenService(name string) (ServiceHandle, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	service, err := f.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return f.open(service), nil
+}
+
+// ListServices 列出所有模拟服务，按名称排序
+func (f *FakeSCM) ListServices() ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sweep()
+	names := make([]string, 0, len(f.services))
+	for _, service := range f.services {
+		names = append(names, service.name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// GrantServiceLogonRight 记录授予登录权限的账户
+func (f *FakeSCM) GrantServiceLogonRight(account string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.logonRights[strings.ToLower(account)] = true
+	return nil
+}
+
+// HasServiceLogonRight 判断账户是否已被授予"作为服务登录"权限
+func (f *FakeSCM) HasServiceLogonRight(account string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.logonRights[strings.ToLower(account)]
+}
+
+// RegisterEventSource 记录注册的事件源
+func (f *FakeSCM) RegisterEventSource(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.RegisterEventSourceError != nil {
+		return f.RegisterEventSourceError
+	}
+	f.eventSources[strings.ToLower(name)] = true
+	return nil
+}
+
+// RemoveEventSource 删除记录的事件源
+func (f *FakeSCM) RemoveEventSource(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.eventSources, strings.ToLower(name))
+	return nil
+}
+
+// HasEventSource 判断事件源是否已注册
+func (f *FakeSCM) HasEventSource(name string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.eventSources[strings.ToLower(name)]
+}
+
+// Disconnect 模拟后端无需断开连接
+func (f *FakeSCM) Disconnect() error {
+	return nil
+}
+
+// lookup 查找服务，调用方需持有锁
+func (f *FakeSCM) lookup(name string) (*fakeService, error) {
+	f.sweep()
+	service, exists := f.services[strings.ToLower(name)]
+	if !exists {
+		return nil, ErrServiceDoesNotExist
+	}
+	return service, nil
+}
+
+func (f *FakeSCM) open(service *fakeService) *fakeServiceHandle {
+	service.handles++
+	return &fakeServiceHandle{scm: f, service: service}
+}
+
+// advance 按时钟推进挂起状态
+func (f *FakeSCM) advance(service *fakeService) {
+	if service.state != StateStartPending && service.state != StateStopPending {
+		return
+	}
+	if service.until.IsZero() || f.now().Before(service.until) {
+		return
+	}
+
+	switch service.target {
+	case StateRunning:
+		service.state = StateRunning
+		service.checkPoint = 0
+	default:
+		service.stop(service.serviceExitCode)
+	}
+	service.until = time.Time{}
+}
+
+// sweep 推进所有服务的状态，并移除已标记删除、已停止且没有打开句柄的服务
+func (f *FakeSCM) sweep() {
+	for key, service := range f.services {
+		f.advance(service)
+		if service.markedForDelete && service.state == StateStopped && service.handles == 0 {
+			delete(f.services, key)
+		}
+	}
+}
+
+// beginPending 进入挂起状态，hang 为 true 时不会自动结束
+func (f *FakeSCM) beginPending(service *fakeService, state, target ServiceState, duration time.Duration, hang bool) {
+	service.state = state
+	service.target = target
+	service.checkPoint = 1
+	service.until = time.Time{}
+	if !hang {
+		service.until = f.now().Add(duration)
+	}
+	f.advance(service)
+}
+
+// stop 进入停止状态，exitCode 非0时作为服务特定错误码报告
+func (service *fakeService) stop(exitCode uint32) {
+	service.state = StateStopped
+	service.pid = 0
+	service.checkPoint = 0
+	service.win32ExitCode = 0
+	service.serviceExitCode = 0
+	if exitCode != 0 {
+		service.win32ExitCode = errorServiceSpecificError
+		service.serviceExitCode = exitCode
+	}
+}
+
+// status 返回当前状态
+func (service *fakeService) status() ServiceStatus {
+	status := ServiceStatus{
+		State:                   service.state,
+		CheckPoint:              service.checkPoint,
+		ProcessId:               service.pid,
+		Win32ExitCode:           service.win32ExitCode,
+		ServiceSpecificExitCode: service.serviceExitCode,
+	}
+	if service.state == StateStartPending || service.state == StateStopPending {
+		status.WaitHint = uint32(fakeWaitHint.Milliseconds())
+	}
+	return status
+}
+
+// fakeServiceHandle 模拟服务的句柄
+type fakeServiceHandle struct {
+	scm     *FakeSCM
+	service *fakeService
+	closed  bool
+}
+
+// acquire 加锁并推进状态，句柄已关闭时返回错误
+func (h *fakeServiceHandle) acquire() error {
+	h.scm.mutex.Lock()
+	if h.closed {
+		h.scm.mutex.Unlock()
+		return ErrInvalidHandle
+	}
+	h.scm.advance(h.service)
+	return nil
+}
+
+func (h *fakeServiceHandle) release() {
+	h.scm.sweep()
+	h.scm.mutex.Unlock()
+}
+
+func (h *fakeServiceHandle) Query() (ServiceStatus, error) {
+	if err := h.acquire(); err != nil {
+		return ServiceStatus{}, err
+	}
+	defer h.release()
+
+	return h.service.status(), nil
+}
+
+func (h *fakeServiceHandle) Start(args ...string) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	service := h.service
+	switch {
+	case service.markedForDelete:
+		return ErrServiceMarkedForDelete
+	case service.config.StartType == scmStartDisabled:
+		return ErrServiceDisabled
+	case service.state != StateStopped:
+		return ErrServiceAlreadyRunning
+	case service.behavior.StartError != nil:
+		return service.behavior.StartError
+	}
+
+	h.scm.nextPID += 4
+	service.pid = h.scm.nextPID
+	service.win32ExitCode = 0
+	service.serviceExitCode = 0
+
+	if service.behavior.FailOnStart {
+		service.serviceExitCode = service.behavior.ExitCode
+		if service.serviceExitCode == 0 {
+			service.serviceExitCode = 1
+		}
+		h.scm.beginPending(service, StateStartPending, StateStopped, service.behavior.StartPending, service.behavior.HangOnStart)
+		return nil
+	}
+
+	h.scm.beginPending(service, StateStartPending, StateRunning, service.behavior.StartPending, service.behavior.HangOnStart)
+	return nil
+}
+
+func (h *fakeServiceHandle) Control(cmd ServiceControl) (ServiceStatus, error) {
+	if err := h.acquire(); err != nil {
+		return ServiceStatus{}, err
+	}
+	defer h.release()
+
+	service := h.service
+	switch cmd {
+	case ControlInterrogate:
+		if service.state == StateStopped {
+			return service.status(), ErrServiceNotActive
+		}
+		return service.status(), nil
+	case ControlStop:
+		switch service.state {
+		case StateStopped:
+			return service.status(), ErrServiceNotActive
+		case StateRunning:
+			service.serviceExitCode = 0
+			h.scm.beginPending(service, StateStopPending, StateStopped, service.behavior.StopPending, service.behavior.HangOnStop)
+			return service.status(), nil
+		default:
+			return service.status(), ErrServiceCannotAcceptCtrl
+		}
+	default:
+		return service.status(), ErrInvalidServiceControl
+	}
+}
+
+func (h *fakeServiceHandle) Config() (SCMServiceConfig, error) {
+	if err := h.acquire(); err != nil {
+		return SCMServiceConfig{}, err
+	}
+	defer h.release()
+
+	config := h.service.config
+	config.Dependencies = append([]string(nil), config.Dependencies...)
+	return config, nil
+}
+
+// UpdateConfig 按 ServiceHandle.UpdateConfig 的规则修改配置
+func (h *fakeServiceHandle) UpdateConfig(config SCMServiceConfig) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	if h.service.markedForDelete {
+		return ErrServiceMarkedForDelete
+	}
+
+	current := &h.service.config
+	if config.StartType != 0 {
+		current.StartType = config.StartType
+	}
+	if config.BinaryPathName != "" {
+		current.BinaryPathName = config.BinaryPathName
+	}
+	if config.Dependencies != nil {
+		current.Dependencies = append([]string(nil), config.Dependencies...)
+	}
+	if config.ServiceStartName != "" {
+		current.ServiceStartName = config.ServiceStartName
+	}
+	if config.DisplayName != "" {
+		current.DisplayName = config.DisplayName
+	}
+	current.Description = config.Description
+	current.DelayedAutoStart = config.DelayedAutoStart
+
+	return nil
+}
+
+func (h *fakeServiceHandle) RecoveryConfig() (*RecoveryConfig, error) {
+	if err := h.acquire(); err != nil {
+		return nil, err
+	}
+	defer h.release()
+
+	recovery := h.service.recovery
+	recovery.Actions = append([]RecoveryAction{}, recovery.Actions...)
+	return &recovery, nil
+}
+
+func (h *fakeServiceHandle) SetRecoveryConfig(recovery RecoveryConfig) error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	recovery.Actions = append([]RecoveryAction(nil), recovery.Actions...)
+	h.service.recovery = recovery
+	return nil
+}
+
+func (h *fakeServiceHandle) ListDependentServices() ([]string, error) {
+	if err := h.acquire(); err != nil {
+		return nil, err
+	}
+	defer h.release()
+
+	var dependents []string
+	var visit func(name string)
+	seen := make(map[string]bool)
+	visit = func(name string) {
+		for key, service := range h.scm.services {
+			if seen[key] || !service.dependsOn(name) {
+				continue
+			}
+			seen[key] = true
+			dependents = append(dependents, service.name)
+			visit(service.name)
+		}
+	}
+	visit(h.service.name)
+
+	sort.Strings(dependents)
+	return dependents, nil
+}
+
+// dependsOn 判断服务是否直接依赖 name
+func (service *fakeService) dependsOn(name string) bool {
+	for _, dep := range service.config.Dependencies {
+		if strings.EqualFold(dep, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Delete 标记服务删除，服务停止且所有句柄关闭后才会移除
+func (h *fakeServiceHandle) Delete() error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	if h.service.markedForDelete {
+		return ErrServiceMarkedForDelete
+	}
+	h.service.markedForDelete = true
+	return nil
+}
+
+func (h *fakeServiceHandle) Close() error {
+	if err := h.acquire(); err != nil {
+		return err
+	}
+	defer h.release()
+
+	h.closed = true
+	h.service.handles--
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestFakeSCMPendingStates(t *testing.T) {
+	clock := newFakeClock()
+	scm := NewFakeSCM()
+	scm.SetClock(clock.Now)
+	scm.DefaultBehavior = FakeServiceBehavior{StartPending: time.Second, StopPending: 2 * time.Second}
+
+	handle, err := scm.CreateService("svc", SCMServiceConfig{BinaryPathName: `"C:\app.exe" --flag`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+
+	if err := handle.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if status, _ := handle.Query(); status.State != StateStartPending || status.WaitHint == 0 {
+		t.Fatalf("启动后状态 = %+v", status)
+	}
+	if err := handle.Start(); !errors.Is(err, ErrServiceAlreadyRunning) {
+		t.Fatalf("重复启动: %v", err)
+	}
+
+	clock.Advance(time.Second)
+	status, _ := handle.Query()
+	if status.State != StateRunning || status.ProcessId == 0 {
+		t.Fatalf("启动完成后状态 = %+v", status)
+	}
+
+	if _, err := handle.Control(ControlStop); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if _, err := handle.Control(ControlStop); !errors.Is(err, ErrServiceCannotAcceptCtrl) {
+		t.Fatalf("停止挂起时再次停止: %v", err)
+	}
+	clock.Advance(2 * time.Second)
+	if status, _ := handle.Query(); status.State != StateStopped || status.ProcessId != 0 {
+		t.Fatalf("停止完成后状态 = %+v", status)
+	}
+	if _, err := handle.Control(ControlStop); !errors.Is(err, ErrServiceNotActive) {
+		t.Fatalf("停止已停止的服务: %v", err)
+	}
+}
+
+func TestFakeSCMFailures(t *testing.T) {
+	scm := NewFakeSCM()
+
+	handle, err := scm.CreateService("svc", SCMServiceConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+
+	scm.SetBehavior("svc", FakeServiceBehavior{FailOnStart: true, ExitCode: 7})
+	if err := handle.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	status, _ := handle.Query()
+	if status.State != StateStopped || status.Win32ExitCode != errorServiceSpecificError || status.ServiceSpecificExitCode != 7 {
+		t.Fatalf("启动失败后状态 = %+v", status)
+	}
+
+	scm.SetBehavior("svc", FakeServiceBehavior{})
+	handle.Start()
+	if err := scm.Crash("svc", 9); err != nil {
+		t.Fatalf("Crash: %v", err)
+	}
+	if status, _ := handle.Query(); status.State != StateStopped || status.ServiceSpecificExitCode != 9 {
+		t.Fatalf("崩溃后状态 = %+v", status)
+	}
+
+	handle.UpdateConfig(SCMServiceConfig{StartType: scmStartDisabled})
+	if err := handle.Start(); !errors.Is(err, ErrServiceDisabled) {
+		t.Fatalf("启动已禁用的服务: %v", err)
+	}
+}
+
+func TestFakeSCMDeleteWaitsForHandles(t *testing.T) {
+	scm := NewFakeSCM()
+
+	handle, _ := scm.CreateService("svc", SCMServiceConfig{})
+	if err := handle.Delete(); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if !scm.Exists("svc") {
+		t.Fatalf("仍有打开的句柄时服务不应移除")
+	}
+	if _, err := scm.CreateService("svc", SCMServiceConfig{}); !errors.Is(err, ErrServiceMarkedForDelete) {
+		t.Fatalf("创建已标记删除的服务: %v", err)
+	}
+
+	handle.Close()
+	if scm.Exists("SVC") {
+		t.Fatalf("句柄关闭后服务应被移除")
+	}
+	if _, err := scm.OpenService("svc"); !errors.Is(err, ErrServiceDoesNotExist) {
+		t.Fatalf("打开已删除的服务: %v", err)
+	}
+	if _, err := handle.Query(); !errors.Is(err, ErrInvalidHandle) {
+		t.Fatalf("使用已关闭的句柄: %v", err)
+	}
+}
+
+func TestFakeSCMUpdateConfig(t *testing.T) {
+	scm := NewFakeSCM()
+
+	imagePath := `"C:\Program Files\app.exe" --service-wrapper svc`
+	handle, _ := scm.CreateService("svc", SCMServiceConfig{
+		BinaryPathName: imagePath,
+		Dependencies:   []string{"Tcpip"},
+		Description:    "old",
+	})
+	defer handle.Close()
+
+	config, _ := handle.Config()
+	if config.BinaryPathName != imagePath || config.ServiceStartName != ServiceAccountLocalSystem || config.DisplayName != "svc" {
+		t.Fatalf("创建后配置 = %+v", config)
+	}
+
+	// 空字段和 nil 依赖表示不修改，描述总会写入
+	if err := handle.UpdateConfig(SCMServiceConfig{StartType: scmStartAutomatic, DelayedAutoStart: true}); err != nil {
+		t.Fatal(err)
+	}
+	config, _ = handle.Config()
+	want := SCMServiceConfig{
+		StartType:        scmStartAutomatic,
+		DelayedAutoStart: true,
+		BinaryPathName:   imagePath,
+		Dependencies:     []string{"Tcpip"},
+		ServiceStartName: ServiceAccountLocalSystem,
+		DisplayName:      "svc",
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("配置 = %+v, 期望 %+v", config, want)
+	}
+
+	handle.UpdateConfig(SCMServiceConfig{Dependencies: []string{}, ServiceStartName: `NT SERVICE\svc`})
+	config, _ = handle.Config()
+	if len(config.Dependencies) != 0 || config.ServiceStartName != `NT SERVICE\svc` {
+		t.Fatalf("配置 = %+v", config)
+	}
+}
+
+func TestFakeSCMListDependentServices(t *testing.T) {
+	scm := NewFakeSCM()
+
+	base, _ := scm.CreateService("base", SCMServiceConfig{})
+	defer base.Close()
+	scm.CreateService("middle", SCMServiceConfig{Dependencies: []string{"BASE"}})
+	scm.CreateService("top", SCMServiceConfig{Dependencies: []string{"middle"}})
+	scm.CreateService("other", SCMServiceConfig{})
+
+	dependents, err := base.ListDependentServices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dependents, []string{"middle", "top"}) {
+		t.Fatalf("依赖服务 = %v", dependents)
+	}
+}
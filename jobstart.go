@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// jobStartSteps 在作业对象中启动目标程序的各个步骤，Windows 上由系统调用实现，测试时可替换
+type jobStartSteps interface {
+	// CreateJob 创建作业对象
+	CreateJob() (uintptr, error)
+	// StartSuspended 以挂起状态启动目标程序，主线程在 Resume 之前不会执行
+	StartSuspended(cmd *exec.Cmd) error
+	// AssignToJob 将目标程序加入作业对象
+	AssignToJob(job uintptr, cmd *exec.Cmd) error
+	// Resume 恢复目标程序的主线程
+	Resume(cmd *exec.Cmd) error
+	// Terminate 结束已启动但无法恢复运行的目标程序
+	Terminate(cmd *exec.Cmd)
+	// CloseJob 关闭作业对象句柄
+	CloseJob(job uintptr)
+}
+
+// startInJob 以挂起状态启动目标程序，加入作业对象后再恢复运行，
+// 保证目标程序派生子进程之前已在作业中。加入作业失败时通过 events 报告并关闭作业，
+// 目标程序照常运行，返回的作业句柄为0，调用方只能跟踪主进程。
+func startInJob(cmd *exec.Cmd, steps jobStartSteps, events wrapperEvents) (uintptr, error) {
+	job, err := steps.CreateJob()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := steps.StartSuspended(cmd); err != nil {
+		steps.CloseJob(job)
+		return 0, err
+	}
+
+	if err := steps.AssignToJob(job, cmd); err != nil {
+		events.warning(EventJobObjectError, "将目标程序加入作业对象失败，仅跟踪主进程: %v", err)
+		steps.CloseJob(job)
+		job = 0
+	}
+
+	if err := steps.Resume(cmd); err != nil {
+		steps.Terminate(cmd)
+		if job != 0 {
+			steps.CloseJob(job)
+		}
+		return 0, fmt.Errorf("恢复目标程序运行失败: %v", err)
+	}
+
+	return job, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeJobStartSteps 记录 startInJob 调用的步骤，并按配置返回错误
+type fakeJobStartSteps struct {
+	calls     []string
+	createErr error
+	startErr  error
+	assignErr error
+	resumeErr error
+}
+
+func (f *fakeJobStartSteps) CreateJob() (uintptr, error) {
+	f.calls = append(f.calls, "create")
+	if f.createErr != nil {
+		return 0, f.createErr
+	}
+	return 42, nil
+}
+
+func (f *fakeJobStartSteps) StartSuspended(cmd *exec.Cmd) error {
+	f.calls = append(f.calls, "start")
+	return f.startErr
+}
+
+func (f *fakeJobStartSteps) AssignToJob(job uintptr, cmd *exec.Cmd) error {
+	f.calls = append(f.calls, "assign")
+	return f.assignErr
+}
+
+func (f *fakeJobStartSteps) Resume(cmd *exec.Cmd) error {
+	f.calls = append(f.calls, "resume")
+	return f.resumeErr
+}
+
+func (f *fakeJobStartSteps) Terminate(cmd *exec.Cmd) {
+	f.calls = append(f.calls, "terminate")
+}
+
+func (f *fakeJobStartSteps) CloseJob(job uintptr) {
+	f.calls = append(f.calls, "close")
+}
+
+func TestStartInJob(t *testing.T) {
+	failure := errors.New("failure")
+
+	tests := []struct {
+		name    string
+		steps   fakeJobStartSteps
+		calls   []string
+		job     uintptr
+		err     bool
+		warning bool
+	}{
+		// 必须在恢复运行之前加入作业，否则目标程序可能已派生不受跟踪的子进程
+		{"success", fakeJobStartSteps{}, []string{"create", "start", "assign", "resume"}, 42, false, false},
+		{"create fails", fakeJobStartSteps{createErr: failure}, []string{"create"}, 0, true, false},
+		{"start fails", fakeJobStartSteps{startErr: failure}, []string{"create", "start", "close"}, 0, true, false},
+		{"assign fails", fakeJobStartSteps{assignErr: failure}, []string{"create", "start", "assign", "close", "resume"}, 0, false, true},
+		{"resume fails", fakeJobStartSteps{resumeErr: failure}, []string{"create", "start", "assign", "resume", "terminate", "close"}, 0, true, false},
+		{"assign and resume fail", fakeJobStartSteps{assignErr: failure, resumeErr: failure}, []string{"create", "start", "assign", "close", "resume", "terminate"}, 0, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &RecordingEventSink{}
+			events := wrapperEvents{serviceName: "svc", sink: sink}
+
+			job, err := startInJob(exec.Command("app"), &tt.steps, events)
+			if (err != nil) != tt.err || job != tt.job {
+				t.Fatalf("startInJob = %d, %v", job, err)
+			}
+			if !reflect.DeepEqual(tt.steps.calls, tt.calls) {
+				t.Fatalf("调用顺序 = %q, 期望 %q", tt.steps.calls, tt.calls)
+			}
+
+			emitted := sink.Events()
+			if !tt.warning {
+				if len(emitted) != 0 {
+					t.Fatalf("不应输出事件: %+v", emitted)
+				}
+				return
+			}
+			if len(emitted) != 1 || emitted[0].ID != EventJobObjectError || emitted[0].Level != WrapperEventWarning ||
+				!strings.Contains(emitted[0].Message, "failure") {
+				t.Fatalf("事件 = %+v", emitted)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxLogPageLines 单次分页读取的最大行数
+	maxLogPageLines = 5000
+	// maxLogChunkBytes 单次按字节读取的最大长度
+	maxLogChunkBytes = 4 * 1024 * 1024
+	// defaultLogSearchResults 搜索默认返回的最大匹配数
+	defaultLogSearchResults = 500
+	// maxLogSearchContext 搜索结果上下文的最大行数
+	maxLogSearchContext = 20
+	// latestLogTailBytes 读取最新日志时返回的最大字节数
+	latestLogTailBytes = 1024 * 1024
+)
+
+// LogPage 按行读取的日志片段
+type LogPage struct {
+	File       string   `json:"file"`
+	StartLine  int      `json:"startLine"` // 第一行的行号，从1开始
+	Lines      []string `json:"lines"`
+	TotalLines int      `json:"totalLines"`
+}
+
+// LogChunk 按字节读取的日志片段
+type LogChunk struct {
+	File   string `json:"file"`
+	Offset int64  `json:"offset"`
+	Data   string `json:"data"`
+	EOF    bool   `json:"eof"`
+}
+
+// LogSearchQuery 日志搜索条件
+type LogSearchQuery struct {
+	Pattern         string `json:"pattern"`
+	Regex           bool   `json:"regex"`
+	CaseInsensitive bool   `json:"caseInsensitive"`
+	Context         int    `json:"context"`    // 匹配行前后附带的行数
+	MaxResults      int    `json:"maxResults"` // 最大匹配数，0 使用默认值
+}
+
+// LogSearchMatch 一条匹配结果
+type LogSearchMatch struct {
+	LineNumber int      `json:"lineNumber"`
+	Line       string   `json:"line"`
+	Before     []string `json:"before"`
+	After      []string `json:"after"`
+}
+
+// LogSearchResult 日志搜索结果
+type LogSearchResult struct {
+	File      string           `json:"file"`
+	Matches   []LogSearchMatch `json:"matches"`
+	Truncated bool             `json:"truncated"` // 匹配数超过上限，结果被截断
+}
+
+// resolveServiceLogFile 查找服务的日志文件，fileName 为空时返回最新的标准输出日志。
+// 只允许访问属于该服务的日志文件，防止读取任意路径。
+func resolveServiceLogFile(serviceID, fileName string) (LogFileInfo, error) {
+	files, err := listServiceLogFiles(osLogFileSystem{}, serviceLogDir(), serviceID)
+	if err != nil {
+		return LogFileInfo{}, err
+	}
+
+	for _, file := range files {
+		if fileName == "" && file.Stream == LogStreamStdout {
+			return file, nil
+		}
+		if fileName != "" && file.Name == fileName {
+			return file, nil
+		}
+	}
+
+	if fileName == "" {
+		return LogFileInfo{}, fmt.Errorf("未找到日志文件")
+	}
+	return LogFileInfo{}, fmt.Errorf("日志文件不存在: %s", fileName)
+}
+
+// openLogFile 打开日志文件，已压缩的文件透明解压
+func openLogFile(file LogFileInfo) (io.ReadCloser, error) {
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	if !file.Compressed {
+		return f, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("解压日志文件失败: %v", err)
+	}
+	return &gzipFile{Reader: gz, file: f}, nil
+}
+
+// gzipFile 关闭时同时关闭解压器和底层文件
+type gzipFile struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (g *gzipFile) Close() error {
+	g.Reader.Close()
+	return g.file.Close()
+}
+
+// forEachLogLine 逐行读取日志，行号从1开始，fn 返回 false 时停止读取
+func forEachLogLine(r io.Reader, fn func(lineNumber int, line string) bool) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			if !fn(lineNumber, line) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("读取日志失败: %v", err)
+		}
+	}
+}
+
+// ReadLogLines 读取从 startLine 开始的 count 行，并统计总行数
+func ReadLogLines(r io.Reader, startLine, count int) (*LogPage, error) {
+	if startLine < 1 {
+		startLine = 1
+	}
+	if count <= 0 || count > maxLogPageLines {
+		count = maxLogPageLines
+	}
+
+	page := &LogPage{StartLine: startLine, Lines: []string{}}
+	err := forEachLogLine(r, func(lineNumber int, line string) bool {
+		page.TotalLines = lineNumber
+		if lineNumber >= startLine && len(page.Lines) < count {
+			page.Lines = append(page.Lines, line)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// ReadLogBytes 读取从 offset 开始的最多 length 字节
+func ReadLogBytes(r io.Reader, offset, length int64) (*LogChunk, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if length <= 0 || length > maxLogChunkBytes {
+		length = maxLogChunkBytes
+	}
+
+	if seeker, ok := r.(io.Seeker); ok {
+		if _, err := seeker.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("定位日志文件失败: %v", err)
+		}
+	} else if _, err := io.CopyN(io.Discard, r, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("读取日志失败: %v", err)
+	}
+
+	buf := make([]byte, length)
+	n, err := io.ReadFull(r, buf)
+	chunk := &LogChunk{Offset: offset, Data: string(buf[:n])}
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		chunk.EOF = true
+	default:
+		return nil, fmt.Errorf("读取日志失败: %v", err)
+	}
+	return chunk, nil
+}
+
+// newLogMatcher 根据搜索条件创建行匹配函数
+func newLogMatcher(query LogSearchQuery) (func(string) bool, error) {
+	if query.Pattern == "" {
+		return nil, fmt.Errorf("搜索内容不能为空")
+	}
+
+	if query.Regex {
+		pattern := query.Pattern
+		if query.CaseInsensitive {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式: %v", err)
+		}
+		return re.MatchString, nil
+	}
+
+	if query.CaseInsensitive {
+		needle := strings.ToLower(query.Pattern)
+		return func(line string) bool {
+			return strings.Contains(strings.ToLower(line), needle)
+		}, nil
+	}
+
+	return func(line string) bool {
+		return strings.Contains(line, query.Pattern)
+	}, nil
+}
+
+// SearchLog 在日志中搜索匹配的行，并附带前后文
+func SearchLog(r io.Reader, query LogSearchQuery) (*LogSearchResult, error) {
+	match, err := newLogMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+
+	contextLines := min(max(query.Context, 0), maxLogSearchContext)
+	maxResults := query.MaxResults
+	if maxResults <= 0 {
+		maxResults = defaultLogSearchResults
+	}
+
+	result := &LogSearchResult{Matches: []LogSearchMatch{}}
+	var before []string
+	var waiting []int // 仍在收集后文的匹配结果下标
+
+	err = forEachLogLine(r, func(lineNumber int, line string) bool {
+		remaining := waiting[:0]
+		for _, idx := range waiting {
+			m := &result.Matches[idx]
+			m.After = append(m.After, line)
+			if len(m.After) < contextLines {
+				remaining = append(remaining, idx)
+			}
+		}
+		waiting = remaining
+
+		if match(line) {
+			if len(result.Matches) >= maxResults {
+				result.Truncated = true
+				return len(waiting) > 0
+			}
+			result.Matches = append(result.Matches, LogSearchMatch{
+				LineNumber: lineNumber,
+				Line:       line,
+				Before:     append([]string{}, before...),
+				After:      []string{},
+			})
+			if contextLines > 0 {
+				waiting = append(waiting, len(result.Matches)-1)
+			}
+		}
+
+		if contextLines > 0 {
+			before = append(before, line)
+			if len(before) > contextLines {
+				before = before[1:]
+			}
+		}
+
+		return !result.Truncated || len(waiting) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readLogTail 读取文件末尾最多 maxBytes 字节，从完整的行开始
+func readLogTail(path string, maxBytes int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("读取日志文件失败: %v", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("读取日志文件失败: %v", err)
+	}
+
+	start := info.Size() - maxBytes
+	if start <= 0 {
+		content, err := io.ReadAll(file)
+		if err != nil {
+			return "", fmt.Errorf("读取日志文件失败: %v", err)
+		}
+		return string(content), nil
+	}
+
+	// 多读取截取位置之前的一个字节，判断第一行是否完整
+	buf := make([]byte, maxBytes+1)
+	n, err := file.ReadAt(buf, start-1)
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("读取日志文件失败: %v", err)
+	}
+	if n == 0 {
+		return "", nil
+	}
+	content := string(buf[1:n])
+
+	if buf[0] != '\n' {
+		if idx := strings.IndexByte(content, '\n'); idx >= 0 {
+			content = content[idx+1:]
+		} else {
+			content = ""
+		}
+	}
+	return content, nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeTestLog 写入日志文件，compressed 为 true 时写入 gzip 压缩的内容
+func writeTestLog(t *testing.T, dir, name, content string, compressed bool) LogFileInfo {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	if compressed {
+		gz := gzip.NewWriter(file)
+		if _, err := gz.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if err := gz.Close(); err != nil {
+			t.Fatal(err)
+		}
+	} else if _, err := file.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return LogFileInfo{Name: name, Path: path, Compressed: compressed}
+}
+
+func TestReadLogLines(t *testing.T) {
+	content := "a\r\nb\nc\n\nlast"
+
+	tests := []struct {
+		start, count int
+		lines        []string
+	}{
+		{1, 2, []string{"a", "b"}},
+		{3, 10, []string{"c", "", "last"}},
+		{0, 1, []string{"a"}},
+		{6, 1, []string{}},
+		{2, 0, []string{"b", "c", "", "last"}},
+	}
+	for _, tt := range tests {
+		page, err := ReadLogLines(strings.NewReader(content), tt.start, tt.count)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(page.Lines, tt.lines) || page.TotalLines != 5 {
+			t.Errorf("ReadLogLines(%d, %d) = %q, 共 %d 行", tt.start, tt.count, page.Lines, page.TotalLines)
+		}
+	}
+}
+
+func TestReadLogBytes(t *testing.T) {
+	dir := t.TempDir()
+	content := "0123456789abcdef"
+
+	for _, compressed := range []bool{false, true} {
+		name := "svc_20240101_000000.log"
+		if compressed {
+			name += ".gz"
+		}
+		file := writeTestLog(t, dir, name, content, compressed)
+
+		tests := []struct {
+			offset, length int64
+			data           string
+			eof            bool
+		}{
+			{0, 4, "0123", false},
+			{10, 6, "abcdef", false},
+			{12, 10, "cdef", true},
+			{20, 4, "", true},
+			{-5, 2, "01", false},
+		}
+		for _, tt := range tests {
+			r, err := openLogFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chunk, err := ReadLogBytes(r, tt.offset, tt.length)
+			r.Close()
+			if err != nil {
+				t.Fatalf("%s: ReadLogBytes(%d, %d): %v", name, tt.offset, tt.length, err)
+			}
+			if chunk.Data != tt.data || chunk.EOF != tt.eof {
+				t.Errorf("%s: ReadLogBytes(%d, %d) = %q, eof=%v", name, tt.offset, tt.length, chunk.Data, chunk.EOF)
+			}
+		}
+	}
+}
+
+func TestSearchLog(t *testing.T) {
+	content := "Error at start\nok 1\nok 2\nerror: disk\nok 3\nWARN retry 42\nERROR final"
+
+	tests := []struct {
+		name    string
+		query   LogSearchQuery
+		lines   []int
+		before  [][]string
+		after   [][]string
+		trunc   bool
+		wantErr bool
+	}{
+		{name: "substring", query: LogSearchQuery{Pattern: "error"}, lines: []int{4}},
+		{name: "case insensitive", query: LogSearchQuery{Pattern: "error", CaseInsensitive: true}, lines: []int{1, 4, 7}},
+		{name: "regex", query: LogSearchQuery{Pattern: `retry \d+$`, Regex: true}, lines: []int{6}},
+		{name: "regex case insensitive", query: LogSearchQuery{Pattern: `^error`, Regex: true, CaseInsensitive: true}, lines: []int{1, 4, 7}},
+		// 文件开头和结尾的匹配只附带实际存在的上下文
+		{
+			name:   "context at edges",
+			query:  LogSearchQuery{Pattern: "error", CaseInsensitive: true, Context: 2},
+			lines:  []int{1, 4, 7},
+			before: [][]string{{}, {"ok 1", "ok 2"}, {"ok 3", "WARN retry 42"}},
+			after:  [][]string{{"ok 1", "ok 2"}, {"ok 3", "WARN retry 42"}, {}},
+		},
+		// 截断后仍为已有结果收集完后文
+		{
+			name:   "truncated",
+			query:  LogSearchQuery{Pattern: "ok", Context: 1, MaxResults: 2},
+			lines:  []int{2, 3},
+			before: [][]string{{"Error at start"}, {"ok 1"}},
+			after:  [][]string{{"ok 2"}, {"error: disk"}},
+			trunc:  true,
+		},
+		{name: "exact limit", query: LogSearchQuery{Pattern: "WARN", MaxResults: 1}, lines: []int{6}},
+		{name: "empty pattern", query: LogSearchQuery{}, wantErr: true},
+		{name: "bad regex", query: LogSearchQuery{Pattern: "(", Regex: true}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := SearchLog(strings.NewReader(content), tt.query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望返回错误")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []int
+			for i, match := range result.Matches {
+				lines = append(lines, match.LineNumber)
+				if tt.before != nil && !reflect.DeepEqual(match.Before, tt.before[i]) {
+					t.Errorf("第 %d 行的前文 = %q, 期望 %q", match.LineNumber, match.Before, tt.before[i])
+				}
+				if tt.after != nil && !reflect.DeepEqual(match.After, tt.after[i]) {
+					t.Errorf("第 %d 行的后文 = %q, 期望 %q", match.LineNumber, match.After, tt.after[i])
+				}
+			}
+			if !reflect.DeepEqual(lines, tt.lines) || result.Truncated != tt.trunc {
+				t.Fatalf("匹配行 = %v, truncated=%v; 期望 %v, %v", lines, result.Truncated, tt.lines, tt.trunc)
+			}
+		})
+	}
+}
+
+func TestSearchLogCompressed(t *testing.T) {
+	file := writeTestLog(t, t.TempDir(), "svc_20240101_000000.log.gz", "first\nneedle here\nlast\n", true)
+
+	r, err := openLogFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	result, err := SearchLog(r, LogSearchQuery{Pattern: "NEEDLE", CaseInsensitive: true, Context: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Matches) != 1 {
+		t.Fatalf("Matches = %+v", result.Matches)
+	}
+	match := result.Matches[0]
+	if match.LineNumber != 2 || !reflect.DeepEqual(match.Before, []string{"first"}) || !reflect.DeepEqual(match.After, []string{"last"}) {
+		t.Fatalf("Match = %+v", match)
+	}
+}
+
+func TestReadLogTail(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTestLog(t, dir, "svc.log", "first line\nsecond\nthird\n", false).Path
+
+	tests := []struct {
+		maxBytes int64
+		want     string
+	}{
+		{1 << 20, "first line\nsecond\nthird\n"},
+		// 截取位置落在行中间时丢弃不完整的第一行
+		{10, "third\n"},
+		{13, "second\nthird\n"},
+		{24, "first line\nsecond\nthird\n"},
+		{23, "second\nthird\n"},
+		{0, ""},
+	}
+	for _, tt := range tests {
+		got, err := readLogTail(path, tt.maxBytes)
+		if err != nil || got != tt.want {
+			t.Errorf("readLogTail(%d) = %q, %v; 期望 %q", tt.maxBytes, got, err, tt.want)
+		}
+	}
+
+	if _, err := readLogTail(filepath.Join(dir, "missing.log"), 10); err == nil {
+		t.Fatalf("文件不存在时应返回错误")
+	}
+}
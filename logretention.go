@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logRetentionInterval 包装器定期执行日志保留策略的间隔
+const logRetentionInterval = 1 * time.Hour
+
+// LogRetention 服务日志保留策略，所有限制为0时表示不限制
+type LogRetention struct {
+	MaxFiles      int           // 每个输出流（标准输出、标准错误）保留的日志文件数量
+	MaxTotalBytes int64         // 所有日志文件的总字节数
+	MaxAge        time.Duration // 日志文件的最长保留时间（按最后修改时间计算）
+}
+
+// IsZero 判断保留策略是否未设置任何限制
+func (retention LogRetention) IsZero() bool {
+	return retention.MaxFiles <= 0 && retention.MaxTotalBytes <= 0 && retention.MaxAge <= 0
+}
+
+// LogFileInfo 服务的一个日志文件
+type LogFileInfo struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Stream     string    `json:"stream"` // "stdout" 或 "stderr"
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+	StartTime  time.Time `json:"startTime"` // 文件创建时间（来自文件名）
+	EndTime    time.Time `json:"endTime"`   // 最后写入时间
+
+	seq int // 同一秒内轮转的序号（来自文件名）
+}
+
+// listServiceLogFiles 列出服务的所有日志文件（包括标准错误和已压缩的文件），按从新到旧排序
+func listServiceLogFiles(fs logFileSystem, dir, serviceID string) ([]LogFileInfo, error) {
+	matches, err := fs.Glob(filepath.Join(dir, serviceID+"_*"))
+	if err != nil {
+		return nil, fmt.Errorf("查找日志文件失败: %v", err)
+	}
+
+	files := make([]LogFileInfo, 0, len(matches))
+	for _, match := range matches {
+		name := filepath.Base(match)
+
+		stream := LogStreamStdout
+		started, seq, ok := parseLogFileName(name, serviceID, logStreamSuffix(LogStreamStdout))
+		if !ok {
+			stream = LogStreamStderr
+			started, seq, ok = parseLogFileName(name, serviceID, logStreamSuffix(LogStreamStderr))
+		}
+		if !ok {
+			continue
+		}
+
+		info, err := fs.Stat(match)
+		if err != nil {
+			continue
+		}
+
+		files = append(files, LogFileInfo{
+			Name:       name,
+			Path:       match,
+			Stream:     stream,
+			Size:       info.Size(),
+			Compressed: strings.HasSuffix(name, ".gz"),
+			StartTime:  started,
+			EndTime:    info.ModTime(),
+			seq:        seq,
+		})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if !files[i].StartTime.Equal(files[j].StartTime) {
+			return files[i].StartTime.After(files[j].StartTime)
+		}
+		if files[i].seq != files[j].seq {
+			return files[i].seq > files[j].seq
+		}
+		return files[i].Stream == LogStreamStdout && files[j].Stream == LogStreamStderr
+	})
+	return files, nil
+}
+
+// isCompressing 判断日志文件是否存在未完成的压缩临时文件
+func isCompressing(fs logFileSystem, path string) bool {
+	_, err := fs.Stat(path + ".gz.tmp")
+	return err == nil
+}
+
+// ApplyLogRetention 对服务的所有日志文件执行保留策略，从最新的文件开始累计数量和大小，
+// 超出限制或过期的文件被删除。文件数量按输出流分别计算，总大小按所有文件计算。active 中的文件正在写入，计入累计值但不会被删除。
+// 返回被删除的文件路径；部分文件删除失败时继续处理其余文件，并一并返回错误。
+func ApplyLogRetention(fs logFileSystem, dir, serviceID string, retention LogRetention, now time.Time, active []string) ([]string, error) {
+	if retention.IsZero() {
+		return nil, nil
+	}
+
+	files, err := listServiceLogFiles(fs, dir, serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	protected := make(map[string]bool, len(active))
+	for _, path := range active {
+		protected[filepath.Clean(path)] = true
+	}
+
+	var removed []string
+	var removeErrs []error
+	var totalBytes int64
+	streamFiles := make(map[string]int)
+	for _, file := range files {
+		totalBytes += file.Size
+		streamFiles[file.Stream]++
+
+		if protected[filepath.Clean(file.Path)] {
+			continue
+		}
+		// 其他写入器仍在后台压缩的文件，压缩完成后会以 .gz 文件参与下次清理
+		if !file.Compressed && isCompressing(fs, file.Path) {
+			continue
+		}
+
+		expired := retention.MaxFiles > 0 && streamFiles[file.Stream] > retention.MaxFiles
+		expired = expired || (retention.MaxTotalBytes > 0 && totalBytes > retention.MaxTotalBytes)
+		expired = expired || (retention.MaxAge > 0 && now.Sub(file.EndTime) > retention.MaxAge)
+		if !expired {
+			continue
+		}
+
+		if err := fs.Remove(file.Path); err != nil {
+			removeErrs = append(removeErrs, err)
+			continue
+		}
+		removed = append(removed, file.Path)
+	}
+
+	if len(removeErrs) > 0 {
+		return removed, fmt.Errorf("删除历史日志文件失败: %v", errors.Join(removeErrs...))
+	}
+	return removed, nil
+}
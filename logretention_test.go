@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+// writeLogFile 创建日志文件并设置最后修改时间
+func writeLogFile(t *testing.T, dir, name string, size int, modTime time.Time) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// remainingLogFiles 返回目录中剩余的文件名，按名称排序
+func remainingLogFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestApplyLogRetention(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	stdout := logStreamSuffix(LogStreamStdout)
+	stderr := logStreamSuffix(LogStreamStderr)
+
+	// 每个输出流各有三个文件，最新的为正在写入的文件
+	files := []struct {
+		name string
+		size int
+		age  time.Duration
+	}{
+		{"svc_20260310_110000" + stdout, 10, 0},
+		{"svc_20260310_110000" + stderr, 10, 0},
+		{"svc_20260309_110000" + stdout + ".gz", 100, 24 * time.Hour},
+		{"svc_20260309_110000" + stderr, 100, 24 * time.Hour},
+		{"svc_20260301_110000" + stdout, 1000, 9 * 24 * time.Hour},
+		{"svc_20260301_110000" + stderr, 1000, 9 * 24 * time.Hour},
+		{"other_20260301_110000" + stdout, 1000, 9 * 24 * time.Hour},
+	}
+
+	tests := []struct {
+		name      string
+		retention LogRetention
+		removed   int
+		remaining []string
+	}{
+		{"none", LogRetention{}, 0, nil},
+		{"max files per stream", LogRetention{MaxFiles: 2}, 2, []string{
+			"other_20260301_110000" + stdout,
+			"svc_20260309_110000" + stdout + ".gz",
+			"svc_20260309_110000" + stderr,
+			"svc_20260310_110000" + stdout,
+			"svc_20260310_110000" + stderr,
+		}},
+		{"max total bytes", LogRetention{MaxTotalBytes: 200}, 3, []string{
+			"other_20260301_110000" + stdout,
+			"svc_20260309_110000" + stdout + ".gz",
+			"svc_20260310_110000" + stdout,
+			"svc_20260310_110000" + stderr,
+		}},
+		// 正在写入的文件即使超出限制也不会被删除
+		{"active protected", LogRetention{MaxFiles: 1, MaxAge: time.Nanosecond}, 4, []string{
+			"other_20260301_110000" + stdout,
+			"svc_20260310_110000" + stdout,
+			"svc_20260310_110000" + stderr,
+		}},
+		{"max age", LogRetention{MaxAge: 7 * 24 * time.Hour}, 2, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var active []string
+			for _, file := range files {
+				path := writeLogFile(t, dir, file.name, file.size, now.Add(-file.age))
+				if file.age == 0 {
+					active = append(active, path)
+				}
+			}
+
+			removed, err := ApplyLogRetention(osLogFileSystem{}, dir, "svc", tt.retention, now, active)
+			if err != nil {
+				t.Fatalf("ApplyLogRetention: %v", err)
+			}
+			if len(removed) != tt.removed {
+				t.Fatalf("删除了 %d 个文件，期望 %d: %q", len(removed), tt.removed, removed)
+			}
+			if tt.remaining != nil {
+				sort.Strings(tt.remaining)
+				if got := remainingLogFiles(t, dir); !reflect.DeepEqual(got, tt.remaining) {
+					t.Fatalf("剩余文件 = %q\n期望 %q", got, tt.remaining)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyLogRetentionSkipsCompressing(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	stdout := logStreamSuffix(LogStreamStdout)
+	stderr := logStreamSuffix(LogStreamStderr)
+
+	dir := t.TempDir()
+	active := writeLogFile(t, dir, "svc_20260310_110000"+stdout, 10, now)
+	writeLogFile(t, dir, "svc_20260309_110000"+stdout, 10, now.Add(-24*time.Hour))
+	// 标准错误的写入器刚轮转，旧文件仍在后台压缩
+	writeLogFile(t, dir, "svc_20260309_110000"+stderr, 10, now.Add(-24*time.Hour))
+	writeLogFile(t, dir, "svc_20260309_110000"+stderr+".gz.tmp", 5, now)
+
+	removed, err := ApplyLogRetention(osLogFileSystem{}, dir, "svc", LogRetention{MaxAge: time.Hour}, now, []string{active})
+	if err != nil {
+		t.Fatalf("ApplyLogRetention: %v", err)
+	}
+	if len(removed) != 1 || filepath.Base(removed[0]) != "svc_20260309_110000"+stdout {
+		t.Fatalf("删除的文件 = %q", removed)
+	}
+	want := []string{
+		"svc_20260309_110000" + stderr,
+		"svc_20260309_110000" + stderr + ".gz.tmp",
+		"svc_20260310_110000" + stdout,
+	}
+	if got := remainingLogFiles(t, dir); !reflect.DeepEqual(got, want) {
+		t.Fatalf("剩余文件 = %q\n期望 %q", got, want)
+	}
+}
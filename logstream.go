@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志流类型
+const (
+	LogStreamStdout = "stdout"
+	LogStreamStderr = "stderr"
+	LogStreamMerged = "merged"
+)
+
+const (
+	// logLineTimestampLayout 日志行前缀中的时间戳格式
+	logLineTimestampLayout = "2006-01-02 15:04:05.000"
+	// maxPendingLineBytes 未遇到换行时缓存的最大字节数，超过后直接输出
+	maxPendingLineBytes = 64 * 1024
+)
+
+// logStreamSuffix 返回日志流对应的文件后缀
+func logStreamSuffix(stream string) string {
+	if stream == LogStreamStderr {
+		return ".err.log"
+	}
+	return ".log"
+}
+
+// logStreamTag 返回日志行前缀中的流标记
+func logStreamTag(stream string) string {
+	if stream == LogStreamStderr {
+		return "[err]"
+	}
+	return "[out]"
+}
+
+// LinePrefixWriter 按行缓冲输出，并为每一行加上时间戳和流标记
+type LinePrefixWriter struct {
+	mutex   sync.Mutex
+	out     io.Writer
+	tag     string
+	now     func() time.Time
+	pending []byte
+}
+
+// NewLinePrefixWriter 创建按行加前缀的写入器，now 为 nil 时使用系统时钟
+func NewLinePrefixWriter(out io.Writer, stream string, now func() time.Time) *LinePrefixWriter {
+	if now == nil {
+		now = time.Now
+	}
+	return &LinePrefixWriter{
+		out: out,
+		tag: logStreamTag(stream),
+		now: now,
+	}
+}
+
+// Write 写入数据，只输出完整的行，不完整的行保留到下一次写入或 Flush
+func (lw *LinePrefixWriter) Write(p []byte) (int, error) {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	lw.pending = append(lw.pending, p...)
+
+	for {
+		idx := bytes.IndexByte(lw.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		if err := lw.writeLine(lw.pending[:idx+1]); err != nil {
+			return 0, err
+		}
+		lw.pending = lw.pending[idx+1:]
+	}
+
+	if len(lw.pending) > maxPendingLineBytes {
+		if err := lw.flushPending(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush 输出缓存中不完整的行
+func (lw *LinePrefixWriter) Flush() error {
+	lw.mutex.Lock()
+	defer lw.mutex.Unlock()
+
+	return lw.flushPending()
+}
+
+func (lw *LinePrefixWriter) flushPending() error {
+	if len(lw.pending) == 0 {
+		return nil
+	}
+	line := append(lw.pending, '\n')
+	lw.pending = nil
+	return lw.writeLine(line)
+}
+
+func (lw *LinePrefixWriter) writeLine(line []byte) error {
+	prefix := lw.now().Format(logLineTimestampLayout) + " " + lw.tag + " "
+	buf := make([]byte, 0, len(prefix)+len(line))
+	buf = append(buf, prefix...)
+	buf = append(buf, line...)
+	_, err := lw.out.Write(buf)
+	return err
+}
+
+// serviceLogFiles 返回服务指定日志流的所有未压缩日志文件，按文件名中的时间戳和序号从旧到新排序
+func serviceLogFiles(serviceID, stream string) ([]LogFileInfo, error) {
+	all, err := listServiceLogFiles(osLogFileSystem{}, serviceLogDir(), serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]LogFileInfo, 0, len(all))
+	for i := len(all) - 1; i >= 0; i-- {
+		if all[i].Stream == stream && !all[i].Compressed {
+			files = append(files, all[i])
+		}
+	}
+	return files, nil
+}
+
+// latestServiceLogFile 返回服务指定日志流的最新日志文件
+func latestServiceLogFile(serviceID, stream string) (string, error) {
+	file, err := latestServiceLog(serviceID, stream)
+	if err != nil {
+		return "", err
+	}
+	return file.Path, nil
+}
+
+// latestServiceLog 返回服务指定日志流的最新日志文件信息
+func latestServiceLog(serviceID, stream string) (LogFileInfo, error) {
+	files, err := serviceLogFiles(serviceID, stream)
+	if err != nil {
+		return LogFileInfo{}, err
+	}
+	if len(files) == 0 {
+		return LogFileInfo{}, fmt.Errorf("未找到日志文件")
+	}
+	return files[len(files)-1], nil
+}
+
+// readLatestServiceLog 读取服务最新日志的末尾部分，stream 为 merged 时按时间合并标准输出和标准错误。
+// 合并依赖每行的时间戳，timestamped 为 false（服务未启用 TimestampLogs）时不支持 merged。
+// 需要查看完整内容时使用分页读取接口。
+func readLatestServiceLog(serviceID, stream string, timestamped bool) (string, error) {
+	switch stream {
+	case LogStreamStdout, LogStreamStderr:
+		path, err := latestServiceLogFile(serviceID, stream)
+		if err != nil {
+			return "", err
+		}
+		return readLogTail(path, latestLogTailBytes)
+	case LogStreamMerged:
+		if !timestamped {
+			return "", fmt.Errorf("服务未启用日志时间戳，无法按时间合并标准输出和标准错误")
+		}
+		stdout, err := latestServiceLog(serviceID, LogStreamStdout)
+		if err != nil {
+			return "", err
+		}
+		stdoutContent, err := readLogTail(stdout.Path, latestLogTailBytes)
+		if err != nil {
+			return "", err
+		}
+
+		// 只合并与标准输出日志属于同一次运行的标准错误日志：
+		// 在标准输出日志创建之前已停止写入的文件来自之前的运行
+		stderr, err := latestServiceLog(serviceID, LogStreamStderr)
+		if err != nil || stderr.EndTime.Before(stdout.StartTime) {
+			return stdoutContent, nil
+		}
+		stderrContent, err := readLogTail(stderr.Path, latestLogTailBytes)
+		if err != nil {
+			return "", err
+		}
+		return mergeTimestampedLogs(stdoutContent, stderrContent), nil
+	default:
+		return "", fmt.Errorf("不支持的日志流: %s", stream)
+	}
+}
+
+// timestampedLine 带有排序时间的日志行
+type timestampedLine struct {
+	time time.Time
+	text string
+}
+
+// mergeTimestampedLogs 按行首时间戳合并多份日志，时间相同时保持原有顺序。
+// 没有时间戳的行（如日志头）沿用上一行的时间，保持与上一行相邻。
+func mergeTimestampedLogs(logs ...string) string {
+	var lines []timestampedLine
+
+	for _, content := range logs {
+		if content == "" {
+			continue
+		}
+		var last time.Time
+		for _, text := range strings.SplitAfter(content, "\n") {
+			if text == "" {
+				continue
+			}
+			if len(text) >= len(logLineTimestampLayout) {
+				if t, err := time.ParseInLocation(logLineTimestampLayout, text[:len(logLineTimestampLayout)], time.Local); err == nil {
+					last = t
+				}
+			}
+			if !strings.HasSuffix(text, "\n") {
+				text += "\n"
+			}
+			lines = append(lines, timestampedLine{time: last, text: text})
+		}
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time.Before(lines[j].time)
+	})
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line.text)
+	}
+	return b.String()
+}
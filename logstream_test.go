@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLinePrefixWriter(t *testing.T) {
+	clock := newFakeClock()
+	var out bytes.Buffer
+	writer := NewLinePrefixWriter(&out, LogStreamStdout, clock.Now)
+	stamp := clock.Now().Format(logLineTimestampLayout)
+
+	writer.Write([]byte("hel"))
+	if out.Len() != 0 {
+		t.Fatalf("不完整的行不应输出: %q", out.String())
+	}
+	writer.Write([]byte("lo\nsecond\r\nthi"))
+	clock.Advance(time.Second)
+	writer.Write([]byte("rd\n"))
+	later := clock.Now().Format(logLineTimestampLayout)
+
+	want := stamp + " [out] hello\n" + stamp + " [out] second\r\n" + later + " [out] third\n"
+	if out.String() != want {
+		t.Fatalf("输出 = %q\n期望 %q", out.String(), want)
+	}
+
+	out.Reset()
+	writer.Write([]byte("tail"))
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := later + " [out] tail\n"; out.String() != want {
+		t.Fatalf("Flush 输出 = %q\n期望 %q", out.String(), want)
+	}
+}
+
+func TestLinePrefixWriterLongLine(t *testing.T) {
+	clock := newFakeClock()
+	var out bytes.Buffer
+	writer := NewLinePrefixWriter(&out, LogStreamStderr, clock.Now)
+
+	long := strings.Repeat("x", maxPendingLineBytes+1)
+	if n, err := writer.Write([]byte(long)); err != nil || n != len(long) {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	want := clock.Now().Format(logLineTimestampLayout) + " [err] " + long + "\n"
+	if out.String() != want {
+		t.Fatalf("超长的行应直接输出，实际输出 %d 字节", out.Len())
+	}
+}
+
+func TestMergeTimestampedLogs(t *testing.T) {
+	stdout := "=== 服务日志开始 ===\n" +
+		"2024-01-01 10:00:00.000 [out] a\n" +
+		"2024-01-01 10:00:02.000 [out] c\n" +
+		"continued\n" +
+		"2024-01-01 10:00:03.000 [out] e"
+	stderr := "2024-01-01 10:00:01.000 [err] b\n" +
+		"2024-01-01 10:00:02.000 [err] d\n"
+
+	want := "=== 服务日志开始 ===\n" +
+		"2024-01-01 10:00:00.000 [out] a\n" +
+		"2024-01-01 10:00:01.000 [err] b\n" +
+		"2024-01-01 10:00:02.000 [out] c\n" +
+		"continued\n" +
+		"2024-01-01 10:00:02.000 [err] d\n" +
+		"2024-01-01 10:00:03.000 [out] e\n"
+	if got := mergeTimestampedLogs(stdout, stderr); got != want {
+		t.Fatalf("mergeTimestampedLogs = %q\n期望 %q", got, want)
+	}
+
+	if got := mergeTimestampedLogs("", stderr, ""); got != stderr {
+		t.Fatalf("只有一份日志时应原样返回: %q", got)
+	}
+	if got := mergeTimestampedLogs(); got != "" {
+		t.Fatalf("没有日志时应返回空: %q", got)
+	}
+}
+
+// setLogDir 将服务日志目录指向临时目录
+func setLogDir(t *testing.T) string {
+	t.Helper()
+
+	t.Setenv("ProgramData", t.TempDir())
+	dir := serviceLogDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestServiceLogFilesOrder(t *testing.T) {
+	dir := setLogDir(t)
+
+	for _, name := range []string{
+		"svc_20240101_100000_10.log",
+		"svc_20240101_100000_9.log",
+		"svc_20240101_100000.log",
+		"svc_20231231_235959_2.log",
+		"svc_20240101_100001.log.gz",
+		"svc_20240101_100001.err.log",
+		"svc_other.log",
+	} {
+		writeTestLog(t, dir, name, "x\n", false)
+	}
+
+	files, err := serviceLogFiles("svc", LogStreamStdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	want := []string{"svc_20231231_235959_2.log", "svc_20240101_100000.log", "svc_20240101_100000_9.log", "svc_20240101_100000_10.log"}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("serviceLogFiles = %q\n期望 %q", names, want)
+	}
+
+	if path, err := latestServiceLogFile("svc", LogStreamStdout); err != nil || filepath.Base(path) != "svc_20240101_100000_10.log" {
+		t.Fatalf("latestServiceLogFile = %s, %v", path, err)
+	}
+	if _, err := latestServiceLogFile("missing", LogStreamStdout); err == nil {
+		t.Fatalf("没有日志文件时应返回错误")
+	}
+}
+
+func TestReadLatestServiceLogMerged(t *testing.T) {
+	dir := setLogDir(t)
+
+	started := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+	previous := started.Add(-time.Hour)
+	stdoutName := "svc_" + started.Format(logTimestampLayout) + ".log"
+	oldStderrName := "svc_" + previous.Format(logTimestampLayout) + ".err.log"
+
+	writeTestLog(t, dir, stdoutName, "2024-01-01 10:00:00.000 [out] a\n2024-01-01 10:00:02.000 [out] c\n", false)
+	writeTestLog(t, dir, oldStderrName, "2024-01-01 09:00:01.000 [err] old\n", false)
+	// 之前运行的标准错误日志在本次运行开始前就已停止写入
+	if err := os.Chtimes(filepath.Join(dir, oldStderrName), previous, previous.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readLatestServiceLog("svc", LogStreamMerged, false); err == nil {
+		t.Fatalf("未启用时间戳时应拒绝合并")
+	}
+
+	got, err := readLatestServiceLog("svc", LogStreamMerged, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01 10:00:00.000 [out] a\n2024-01-01 10:00:02.000 [out] c\n"; got != want {
+		t.Fatalf("不应合并之前运行的标准错误日志: %q", got)
+	}
+
+	stderrName := "svc_" + started.Format(logTimestampLayout) + ".err.log"
+	writeTestLog(t, dir, stderrName, "2024-01-01 10:00:01.000 [err] b\n", false)
+	if err := os.Chtimes(filepath.Join(dir, stderrName), started, started.Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = readLatestServiceLog("svc", LogStreamMerged, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01 10:00:00.000 [out] a\n2024-01-01 10:00:01.000 [err] b\n2024-01-01 10:00:02.000 [out] c\n"; got != want {
+		t.Fatalf("合并结果 = %q\n期望 %q", got, want)
+	}
+
+	if got, err := readLatestServiceLog("svc", LogStreamStderr, false); err != nil || got != "2024-01-01 10:00:01.000 [err] b\n" {
+		t.Fatalf("读取标准错误日志 = %q, %v", got, err)
+	}
+	if _, err := readLatestServiceLog("svc", "bogus", true); err == nil {
+		t.Fatalf("不支持的日志流应返回错误")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// defaultTailInterval 日志跟踪的轮询间隔
+	defaultTailInterval = 500 * time.Millisecond
+	// tailChunkBytes 每批读取的最大字节数，避免一次推送过多内容
+	tailChunkBytes = 256 * 1024
+)
+
+// LogTailBatch 一批新增的日志行
+type LogTailBatch struct {
+	File   string   `json:"file"`
+	Lines  []string `json:"lines"`
+	Offset int64    `json:"offset"` // 已读取到的文件偏移量，可用于断点续读
+	Reset  bool     `json:"reset"`  // 文件被截断或切换到新文件，之前的内容已失效
+}
+
+// LogTailer 跟踪日志文件的增长，处理截断和轮转
+type LogTailer struct {
+	resolve  func() (string, error)
+	onBatch  func(LogTailBatch)
+	interval time.Duration
+	path     string
+	offset   int64
+	pending  []byte
+}
+
+// NewLogTailer 创建日志跟踪器。resolve 返回当前应跟踪的日志文件，返回值变化时视为轮转；
+// offset 为首个文件的起始偏移量，小于0表示从文件末尾开始。
+func NewLogTailer(resolve func() (string, error), offset int64, interval time.Duration, onBatch func(LogTailBatch)) *LogTailer {
+	if interval <= 0 {
+		interval = defaultTailInterval
+	}
+	return &LogTailer{
+		resolve:  resolve,
+		onBatch:  onBatch,
+		interval: interval,
+		offset:   offset,
+	}
+}
+
+// Run 持续跟踪日志直到 ctx 被取消
+func (lt *LogTailer) Run(ctx context.Context) error {
+	ticker := time.NewTicker(lt.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := lt.Poll(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll 执行一次检查，读取新增内容并处理截断和轮转
+func (lt *LogTailer) Poll() error {
+	if lt.path == "" {
+		path, err := lt.resolve()
+		if err != nil {
+			// 日志文件尚未创建，等待下一次轮询
+			return nil
+		}
+		lt.path = path
+	}
+
+	file, err := lt.open()
+	if err != nil {
+		return err
+	}
+	if file != nil {
+		defer file.Close()
+		if err := lt.readFrom(file); err != nil {
+			return err
+		}
+	}
+
+	next, err := lt.resolve()
+	if err != nil || next == lt.path {
+		return nil
+	}
+
+	// 文件已轮转：写入器已关闭旧文件，先把旧句柄读到末尾，避免丢失检查之间写入的内容，
+	// 然后输出剩余的不完整行，从新文件开头读取
+	if file != nil {
+		if err := lt.readFrom(file); err != nil {
+			return err
+		}
+	}
+	if len(lt.pending) > 0 {
+		lt.emit([]string{string(lt.pending)}, false)
+		lt.pending = nil
+	}
+	lt.path = next
+	lt.offset = 0
+	lt.emit(nil, true)
+
+	newFile, err := lt.open()
+	if err != nil || newFile == nil {
+		return err
+	}
+	defer newFile.Close()
+	return lt.readFrom(newFile)
+}
+
+// open 打开当前跟踪的日志文件，文件不存在时返回 nil
+func (lt *LogTailer) open() (*os.File, error) {
+	file, err := os.Open(lt.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	return file, nil
+}
+
+// readFrom 从当前偏移量读取到文件末尾
+func (lt *LogTailer) readFrom(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("读取日志文件信息失败: %v", err)
+	}
+
+	if lt.offset < 0 {
+		lt.offset = info.Size()
+	}
+	if info.Size() < lt.offset {
+		// 文件被截断，从头开始读取
+		lt.offset = 0
+		lt.pending = nil
+		lt.emit(nil, true)
+	}
+
+	buf := make([]byte, tailChunkBytes)
+	for lt.offset < info.Size() {
+		n, err := file.ReadAt(buf, lt.offset)
+		if n > 0 {
+			lt.offset += int64(n)
+			lt.consume(buf[:n])
+		}
+		if err == io.EOF || n == 0 {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("读取日志文件失败: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// consume 将数据拆分为完整的行并推送，不完整的行留到下一次
+func (lt *LogTailer) consume(data []byte) {
+	lt.pending = append(lt.pending, data...)
+
+	var lines []string
+	for {
+		idx := bytes.IndexByte(lt.pending, '\n')
+		if idx < 0 {
+			break
+		}
+		lines = append(lines, string(bytes.TrimSuffix(lt.pending[:idx], []byte("\r"))))
+		lt.pending = lt.pending[idx+1:]
+	}
+	lt.pending = append([]byte(nil), lt.pending...)
+
+	if len(lines) > 0 {
+		lt.emit(lines, false)
+	}
+}
+
+func (lt *LogTailer) emit(lines []string, reset bool) {
+	lt.onBatch(LogTailBatch{
+		File:   lt.path,
+		Lines:  lines,
+		Offset: lt.offset - int64(len(lt.pending)),
+		Reset:  reset,
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// appendFile 向文件末尾追加内容
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// tailRecorder 记录 LogTailer 推送的批次
+type tailRecorder struct {
+	batches []LogTailBatch
+}
+
+func (r *tailRecorder) onBatch(batch LogTailBatch) {
+	r.batches = append(r.batches, batch)
+}
+
+// take 返回并清空已记录的批次，只保留行和重置标志便于比较
+func (r *tailRecorder) take() []LogTailBatch {
+	batches := r.batches
+	r.batches = nil
+	for i := range batches {
+		batches[i].Offset = 0
+	}
+	return batches
+}
+
+func TestLogTailerRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "svc_1.log")
+	newPath := filepath.Join(dir, "svc_2.log")
+	appendFile(t, oldPath, "skipped\n")
+
+	current := oldPath
+	rotate := false
+	resolve := func() (string, error) {
+		if rotate && current == oldPath {
+			// 模拟写入器在两次检查之间写完旧文件后切换到新文件
+			appendFile(t, oldPath, "late\npartial")
+			appendFile(t, newPath, "new\n")
+			current = newPath
+		}
+		return current, nil
+	}
+
+	recorder := &tailRecorder{}
+	tailer := NewLogTailer(resolve, -1, 0, recorder.onBatch)
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if batches := recorder.take(); len(batches) != 0 {
+		t.Fatalf("offset 为负数时应从文件末尾开始: %+v", batches)
+	}
+
+	appendFile(t, oldPath, "a\nb")
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	want := []LogTailBatch{{File: oldPath, Lines: []string{"a"}}}
+	if batches := recorder.take(); !reflect.DeepEqual(batches, want) {
+		t.Fatalf("batches = %+v", batches)
+	}
+
+	appendFile(t, oldPath, "c\n")
+	rotate = true
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	want = []LogTailBatch{
+		{File: oldPath, Lines: []string{"bc"}},
+		{File: oldPath, Lines: []string{"late"}},
+		{File: oldPath, Lines: []string{"partial"}},
+		{File: newPath, Reset: true},
+		{File: newPath, Lines: []string{"new"}},
+	}
+	if batches := recorder.take(); !reflect.DeepEqual(batches, want) {
+		t.Fatalf("轮转时 batches = %+v\n期望 %+v", batches, want)
+	}
+}
+
+func TestLogTailerTruncation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.log")
+	appendFile(t, path, "one\r\ntwo\n")
+
+	recorder := &tailRecorder{}
+	tailer := NewLogTailer(func() (string, error) { return path, nil }, 0, 0, recorder.onBatch)
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if batches := recorder.take(); !reflect.DeepEqual(batches, []LogTailBatch{{File: path, Lines: []string{"one", "two"}}}) {
+		t.Fatalf("batches = %+v", batches)
+	}
+
+	if err := os.WriteFile(path, []byte("3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	want := []LogTailBatch{{File: path, Reset: true}, {File: path, Lines: []string{"3"}}}
+	if batches := recorder.take(); !reflect.DeepEqual(batches, want) {
+		t.Fatalf("截断后 batches = %+v", batches)
+	}
+
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if batches := recorder.take(); len(batches) != 0 {
+		t.Fatalf("没有新内容时不应推送: %+v", batches)
+	}
+}
+
+func TestLogTailerWaitsForFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.log")
+
+	recorder := &tailRecorder{}
+	tailer := NewLogTailer(func() (string, error) { return path, nil }, 0, 0, recorder.onBatch)
+	if err := tailer.Poll(); err != nil {
+		t.Fatalf("文件不存在时不应返回错误: %v", err)
+	}
+
+	appendFile(t, path, "ready\n")
+	if err := tailer.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if batches := recorder.take(); !reflect.DeepEqual(batches, []LogTailBatch{{File: path, Lines: []string{"ready"}}}) {
+		t.Fatalf("batches = %+v", batches)
+	}
+}
@@ -1,3 +1,5 @@
+//go:build windows
+
 package main
 
 import (
@@ -104,13 +106,22 @@ func main() {
 	}
 
 	if isWrapper, serviceName := IsServiceWrapperMode(); isWrapper {
-		config, configErr := LoadServiceConfigFromRegistry(serviceName)
+		sink := newWrapperEventSink(serviceName)
+		events := wrapperEvents{serviceName: serviceName, sink: sink}
+
+		config, configErr := LoadServiceConfigFromRegistry(serviceName, events)
 		if configErr != nil {
-			log.Fatalf("加载服务配置失败: %v", configErr)
+			sink.Close()
+			os.Exit(1)
 		}
 
-		if err := RunAsWindowsService(serviceName, *config); err != nil {
-			log.Fatalf("运行Windows服务失败: %v", err)
+		err := RunAsWindowsService(serviceName, *config, sink)
+		if err != nil {
+			events.error(EventStartFailed, "运行Windows服务失败: %v", err)
+		}
+		sink.Close()
+		if err != nil {
+			os.Exit(1)
 		}
 		return
 	}
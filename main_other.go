@@ -0,0 +1,14 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// 图形界面和服务包装器只支持Windows；其他平台上只编译与平台无关的部分，用于运行测试
+func main() {
+	fmt.Fprintln(os.Stderr, "本程序只支持在Windows上运行")
+	os.Exit(1)
+}
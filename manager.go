@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,42 +10,69 @@ import (
 	"time"
 
 	"github.com/wailsapp/wails/v2/pkg/runtime"
-	"golang.org/x/sys/windows"
-	"golang.org/x/sys/windows/registry"
-	"golang.org/x/sys/windows/svc"
-	"golang.org/x/sys/windows/svc/mgr"
 )
 
+// serviceConfigRefreshInterval GetServices 重新从SCM读取服务配置的最短间隔，
+// 运行状态由 statusCache 单独缓存
+const serviceConfigRefreshInterval = 30 * time.Second
+
 // WindowsServiceManager 使用Windows Service Control Manager API管理服务
 type WindowsServiceManager struct {
 	mutex       sync.RWMutex
-	dataFile    string
 	services    map[string]*Service
 	statusCache *ServiceStatusCache
+	refreshedAt map[string]time.Time // 服务配置上次从SCM读取的时间
 	ctx         context.Context
+	emitEvent   func(name string, data ...interface{}) // 向界面发射事件，未设置上下文时为 nil
+	connect     func() (ServiceControlBackend, error)
+	parameters  ServiceParameterProvider
+	events      WrapperEventSink
+
+	// externalServices 打开保存已纳管外部服务的参数存储，值名为服务名，DWORD 值为1表示只读
+	externalServices func(writable bool) (ServiceParameterStore, error)
 }
 
-// NewWindowsServiceManager 创建新的Windows服务管理器
-func NewWindowsServiceManager() *WindowsServiceManager {
+// NewWindowsServiceManagerWithBackend 创建使用指定服务控制后端和参数存储的服务管理器，
+// connect 在每次操作时建立连接，如 FakeSCM.Connect；parameters 保存包装器服务的配置，
+// externalServices 保存已纳管的外部服务。与 FakeSCM 一起使用时可传入 MemoryParameterProvider。
+func NewWindowsServiceManagerWithBackend(connect func() (ServiceControlBackend, error), parameters ServiceParameterProvider,
+	externalServices func(writable bool) (ServiceParameterStore, error)) *WindowsServiceManager {
 	cache := NewServiceStatusCache()
 	cache.StartCleanupRoutine()
 
 	return &WindowsServiceManager{
-		services:    make(map[string]*Service),
-		dataFile:    filepath.Join(os.TempDir(), "windows_services_data.json"),
-		statusCache: cache,
+		services:         make(map[string]*Service),
+		statusCache:      cache,
+		refreshedAt:      make(map[string]time.Time),
+		connect:          connect,
+		parameters:       parameters,
+		events:           StdLogEventSink{},
+		externalServices: externalServices,
 	}
 }
 
+// SetEventSink 设置服务管理操作中非致命错误的输出目标
+func (wsm *WindowsServiceManager) SetEventSink(sink WrapperEventSink) {
+	wsm.events = sink
+}
+
+// warning 输出与服务相关的警告事件
+func (wsm *WindowsServiceManager) warning(serviceID string, id uint32, format string, args ...interface{}) {
+	wrapperEvents{serviceName: serviceID, sink: wsm.events}.warning(id, format, args...)
+}
+
 // SetContext 设置上下文用于事件发射
 func (wsm *WindowsServiceManager) SetContext(ctx context.Context) {
 	wsm.ctx = ctx
+	wsm.emitEvent = func(name string, data ...interface{}) {
+		runtime.EventsEmit(ctx, name, data...)
+	}
 }
 
 // emitServiceStatusChanged 发射服务状态变化事件
 func (wsm *WindowsServiceManager) emitServiceStatusChanged(serviceID, status string, pid int) {
-	if wsm.ctx != nil {
-		runtime.EventsEmit(wsm.ctx, "service-status-changed", map[string]interface{}{
+	if wsm.emitEvent != nil {
+		wsm.emitEvent("service-status-changed", map[string]interface{}{
 			"serviceId": serviceID,
 			"status":    status,
 			"pid":       pid,
@@ -56,22 +82,22 @@ func (wsm *WindowsServiceManager) emitServiceStatusChanged(serviceID, status str
 
 // emitServicesUpdated 发射服务列表更新事件
 func (wsm *WindowsServiceManager) emitServicesUpdated() {
-	if wsm.ctx != nil {
+	if wsm.emitEvent != nil {
 		services := make([]*Service, 0, len(wsm.services))
 		for _, service := range wsm.services {
 			services = append(services, service)
 		}
-		runtime.EventsEmit(wsm.ctx, "services-updated", services)
+		wsm.emitEvent("services-updated", services)
 	}
 }
 
-// connectSCM 连接到Windows服务控制管理器
-func (wsm *WindowsServiceManager) connectSCM() (*mgr.Mgr, error) {
-	return mgr.Connect()
+// connectSCM 连接到服务控制后端
+func (wsm *WindowsServiceManager) connectSCM() (ServiceControlBackend, error) {
+	return wsm.connect()
 }
 
 // withSCM 使用SCM执行操作的辅助函数
-func (wsm *WindowsServiceManager) withSCM(operation func(*mgr.Mgr) error) error {
+func (wsm *WindowsServiceManager) withSCM(operation func(ServiceControlBackend) error) error {
 	scm, err := wsm.connectSCM()
 	if err != nil {
 		return fmt.Errorf("连接服务控制管理器失败: %v", err)
@@ -82,7 +108,7 @@ func (wsm *WindowsServiceManager) withSCM(operation func(*mgr.Mgr) error) error
 }
 
 // waitForServiceState 等待服务达到指定状态
-func (wsm *WindowsServiceManager) waitForServiceState(windowsService *mgr.Service, targetState svc.State, timeout time.Duration) error {
+func (wsm *WindowsServiceManager) waitForServiceState(windowsService ServiceHandle, targetState ServiceState, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 
 	for time.Now().Before(deadline) {
@@ -95,7 +121,7 @@ func (wsm *WindowsServiceManager) waitForServiceState(windowsService *mgr.Servic
 			return nil
 		}
 
-		if targetState == svc.Running && status.State == svc.Stopped {
+		if targetState == StateRunning && status.State == StateStopped {
 			return fmt.Errorf("服务启动失败")
 		}
 
@@ -105,103 +131,206 @@ func (wsm *WindowsServiceManager) waitForServiceState(windowsService *mgr.Servic
 	return fmt.Errorf("等待服务状态超时")
 }
 
-// setServiceRegistryValue 通用的服务注册表值设置函数
-func (wsm *WindowsServiceManager) setServiceRegistryValue(serviceName, subKey, valueName, value string) error {
-	keyPath := fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\%s`, serviceName)
-	if subKey != "" {
-		keyPath = fmt.Sprintf(`%s\%s`, keyPath, subKey)
+// setServiceWorkingDirectory 通过注册表设置服务的工作目录
+func (wsm *WindowsServiceManager) setServiceWorkingDirectory(serviceName, workingDir string) error {
+	return wsm.setServiceParameter(serviceName, parameterAppDirectory, StringParameter(workingDir))
+}
+
+// wrapperCommandLine 返回内置服务包装器的命令行（当前程序+参数模式）
+func wrapperCommandLine(serviceName string) (string, error) {
+	currentExe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("获取当前可执行文件路径失败: %v", err)
 	}
 
-	var key registry.Key
-	var err error
+	return fmt.Sprintf(`"%s" %s %s`, currentExe, serviceWrapperFlag, serviceName), nil
+}
 
-	if subKey != "" {
-		parentKey, err := registry.OpenKey(registry.LOCAL_MACHINE, fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\%s`, serviceName), registry.SET_VALUE)
-		if err != nil {
-			return fmt.Errorf("打开服务注册表键失败: %v", err)
-		}
-		defer parentKey.Close()
+// storeServiceConfigInRegistry 将服务配置存储到服务参数，未设置的值被删除，
+// 更新配置时不会残留旧值
+func (wsm *WindowsServiceManager) storeServiceConfigInRegistry(serviceName string, config ServiceConfig) error {
+	return wsm.withServiceParameters(serviceName, func(store ServiceParameterStore) error {
+		return encodeServiceParameters(store, config)
+	})
+}
 
-		key, _, err = registry.CreateKey(parentKey, subKey, registry.SET_VALUE)
-		if err != nil {
-			return fmt.Errorf("创建注册表子键失败: %v", err)
-		}
-	} else {
-		key, err = registry.OpenKey(registry.LOCAL_MACHINE, keyPath, registry.SET_VALUE)
-		if err != nil {
-			return fmt.Errorf("打开服务注册表键失败: %v", err)
-		}
+// withServiceParameters 以读写方式打开服务参数并执行 fn
+func (wsm *WindowsServiceManager) withServiceParameters(serviceName string, fn func(store ServiceParameterStore) error) error {
+	store, err := wsm.parameters.Open(serviceName, true)
+	if err != nil {
+		return err
 	}
-	defer key.Close()
+	defer store.Close()
+
+	return fn(store)
+}
 
-	err = key.SetStringValue(valueName, value)
+// setServiceParameter 设置单个服务参数
+func (wsm *WindowsServiceManager) setServiceParameter(serviceName, name string, value ParameterValue) error {
+	return wsm.withServiceParameters(serviceName, func(store ServiceParameterStore) error {
+		return store.Set(name, value)
+	})
+}
+
+// loadServiceConfig 从服务参数读取服务配置
+func (wsm *WindowsServiceManager) loadServiceConfig(serviceName string) (*ServiceConfig, error) {
+	store, err := wsm.parameters.Open(serviceName, false)
 	if err != nil {
-		return fmt.Errorf("设置注册表值失败: %v", err)
+		return nil, fmt.Errorf("打开服务配置失败: %v", err)
 	}
+	defer store.Close()
 
-	return nil
+	return decodeServiceParameters(store, serviceName)
 }
 
-// setServiceWorkingDirectory 通过注册表设置服务的工作目录
-func (wsm *WindowsServiceManager) setServiceWorkingDirectory(serviceName, workingDir string) error {
-	return wsm.setServiceRegistryValue(serviceName, "Parameters", "AppDirectory", workingDir)
+// applyConfig 将服务配置复制到服务记录
+func (service *Service) applyConfig(config ServiceConfig) {
+	service.Name = config.Name
+	service.Description = config.Description
+	service.StartType = config.StartType
+	service.Account = config.Account
+	service.ExePath = config.ExePath
+	service.Args = config.Args
+	service.ArgList = config.ArgList
+	service.WorkingDir = config.WorkingDir
+	service.RestartPolicy = config.RestartPolicy
+	service.MaxRestarts = config.MaxRestarts
+	service.RestartWindowSec = config.RestartWindowSec
+	service.RestartDelayMs = config.RestartDelayMs
+	service.RestartMaxDelayMs = config.RestartMaxDelayMs
+	service.ExitActions = config.ExitActions
+	service.StopMethodSkip = config.StopMethodSkip
+	service.StopConsoleTimeoutMs = config.StopConsoleTimeoutMs
+	service.StopWindowTimeoutMs = config.StopWindowTimeoutMs
+	service.DetachChildren = config.DetachChildren
+	service.Environment = config.Environment
+	service.EnvFiles = config.EnvFiles
+	service.LogRotateBytes = config.LogRotateBytes
+	service.LogRotateDaily = config.LogRotateDaily
+	service.LogCompress = config.LogCompress
+	service.LogMaxFiles = config.LogMaxFiles
+	service.LogMaxTotalBytes = config.LogMaxTotalBytes
+	service.LogMaxAgeDays = config.LogMaxAgeDays
+	service.SeparateStderr = config.SeparateStderr
+	service.TimestampLogs = config.TimestampLogs
+	if config.Recovery != nil {
+		service.Recovery = config.Recovery
+	}
+	if config.Dependencies != nil {
+		service.Dependencies = config.Dependencies
+	}
+	service.UpdatedAt = time.Now()
 }
 
-// setServiceImagePathDirect 直接设置服务的ImagePath值
-func (wsm *WindowsServiceManager) setServiceImagePathDirect(serviceName, imagePath string) error {
-	return wsm.setServiceRegistryValue(serviceName, "", "ImagePath", imagePath)
-}
+// normalizeServiceConfig 校验服务配置并规范化各字段，创建和更新服务时共用
+func normalizeServiceConfig(config *ServiceConfig) error {
+	if _, err := os.Stat(config.ExePath); os.IsNotExist(err) {
+		return fmt.Errorf("可执行文件不存在: %s", config.ExePath)
+	}
 
-// createServiceWrapper 设置内置服务包装器（使用当前程序+参数模式）
-func (wsm *WindowsServiceManager) createServiceWrapper(serviceName, exePath, args, workingDir string) (string, error) {
-	currentExe, err := os.Executable()
+	switch RestartPolicy(config.RestartPolicy) {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("不支持的重启策略: %s", config.RestartPolicy)
+	}
+
+	if config.StartType == "" {
+		config.StartType = ServiceStartAutomatic
+	}
+	if err := config.StartType.applyTo(&SCMServiceConfig{}); err != nil {
+		return err
+	}
+
+	if config.Recovery != nil {
+		if err := config.Recovery.validate(); err != nil {
+			return err
+		}
+	}
+
+	exitActions, err := ParseExitActions(config.ExitActions)
 	if err != nil {
-		return "", fmt.Errorf("获取当前可执行文件路径失败: %v", err)
+		return fmt.Errorf("退出动作配置无效: %v", err)
 	}
+	config.ExitActions = exitActions.Entries()
 
-	err = wsm.storeServiceConfigInRegistry(serviceName, exePath, args, workingDir)
+	environment, err := normalizeEnvironment(config.Environment)
 	if err != nil {
-		return "", fmt.Errorf("存储服务配置失败: %v", err)
+		return err
 	}
+	config.Environment = environment
+	config.EnvFiles = compactStrings(config.EnvFiles)
+	config.Dependencies = normalizeDependencies(config.Dependencies)
 
-	return fmt.Sprintf(`"%s" --service-wrapper %s`, currentExe, serviceName), nil
+	if len(config.ArgList) > 0 {
+		config.Args = JoinCommandLine(config.ArgList)
+	}
+
+	if config.WorkingDir == "" {
+		config.WorkingDir = filepath.Dir(config.ExePath)
+	}
+
+	return nil
 }
 
-// storeServiceConfigInRegistry 将服务配置存储到注册表
-func (wsm *WindowsServiceManager) storeServiceConfigInRegistry(serviceName, exePath, args, workingDir string) error {
-	if err := wsm.setServiceRegistryValue(serviceName, "Parameters", "ExePath", exePath); err != nil {
-		return fmt.Errorf("设置ExePath失败: %v", err)
+// serviceDescription 返回服务描述，未填写时使用默认描述
+func serviceDescription(config ServiceConfig) string {
+	if config.Description != "" {
+		return config.Description
 	}
+	return fmt.Sprintf("由Windows服务管理器创建的服务: %s", config.Name)
+}
 
-	if args != "" {
-		if err := wsm.setServiceRegistryValue(serviceName, "Parameters", "Args", args); err != nil {
-			return fmt.Errorf("设置Args失败: %v", err)
+// containsEmptyString 检查字符串列表中是否包含空字符串
+func containsEmptyString(values []string) bool {
+	for _, value := range values {
+		if value == "" {
+			return true
 		}
 	}
+	return false
+}
 
-	if workingDir != "" {
-		if err := wsm.setServiceRegistryValue(serviceName, "Parameters", "WorkingDir", workingDir); err != nil {
-			return fmt.Errorf("设置WorkingDir失败: %v", err)
+// compactStrings 去除字符串列表中的空白项
+func compactStrings(values []string) []string {
+	compacted := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			compacted = append(compacted, value)
 		}
 	}
-
-	return nil
+	return compacted
 }
 
-// GetServices 获取所有由我们管理的服务
+// GetServices 获取所有由我们管理的服务。运行状态每次更新，
+// 启动类型等配置超过 serviceConfigRefreshInterval 才重新从SCM读取
 func (wsm *WindowsServiceManager) GetServices() ([]*Service, error) {
-	wsm.mutex.RLock()
-	defer wsm.mutex.RUnlock()
+	return wsm.listServices(false)
+}
+
+// RefreshServices 立即从SCM重新读取所有服务的配置并返回服务列表
+func (wsm *WindowsServiceManager) RefreshServices() ([]*Service, error) {
+	return wsm.listServices(true)
+}
+
+// listServices 更新并返回服务列表，force 为 true 时忽略配置缓存
+func (wsm *WindowsServiceManager) listServices(force bool) ([]*Service, error) {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
 
 	var services []*Service
 
-	err := wsm.withSCM(func(scm *mgr.Mgr) error {
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		now := time.Now()
 		services = make([]*Service, 0, len(wsm.services))
 		for _, service := range wsm.services {
 			status, pid := wsm.getServiceRealTimeStatus(scm, service.ID)
 			service.Status = status
 			service.PID = pid
-			service.UpdatedAt = time.Now()
+			if force || now.Sub(wsm.refreshedAt[service.ID]) >= serviceConfigRefreshInterval {
+				wsm.refreshServiceFromSCM(scm, service)
+				wsm.refreshedAt[service.ID] = now
+			}
+			service.UpdatedAt = now
 			services = append(services, service)
 		}
 		return nil
@@ -211,8 +340,6 @@ func (wsm *WindowsServiceManager) GetServices() ([]*Service, error) {
 		return nil, err
 	}
 
-	go wsm.saveServices()
-
 	return services, nil
 }
 
@@ -221,9 +348,10 @@ func (wsm *WindowsServiceManager) CreateService(config ServiceConfig) (*Service,
 	wsm.mutex.Lock()
 	defer wsm.mutex.Unlock()
 
-	if _, err := os.Stat(config.ExePath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("可执行文件不存在: %s", config.ExePath)
+	if err := normalizeServiceConfig(&config); err != nil {
+		return nil, err
 	}
+	workingDir := config.WorkingDir
 
 	serviceName := wsm.generateServiceName(config.Name)
 
@@ -231,62 +359,84 @@ func (wsm *WindowsServiceManager) CreateService(config ServiceConfig) (*Service,
 		return nil, fmt.Errorf("服务名称已存在: %s", serviceName)
 	}
 
-	workingDir := config.WorkingDir
-	if workingDir == "" {
-		workingDir = filepath.Dir(config.ExePath)
+	account := resolveServiceAccount(serviceName, config.Account)
+	config.Account = account.StartName
+
+	wrapperPath, err := wrapperCommandLine(serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("创建服务包装器失败: %v", err)
 	}
 
 	var service *Service
 
-	err := wsm.withSCM(func(scm *mgr.Mgr) error {
-		serviceConfig := mgr.Config{
-			ServiceType:  windows.SERVICE_WIN32_OWN_PROCESS,
-			StartType:    mgr.StartAutomatic,
-			ErrorControl: mgr.ErrorNormal,
-			DisplayName:  config.Name,
-			Description:  fmt.Sprintf("由Windows服务管理器创建的服务: %s", config.Name),
+	err = wsm.withSCM(func(scm ServiceControlBackend) error {
+		if err := prepareServiceAccount(scm, account, config.Password); err != nil {
+			return err
 		}
 
-		binaryPath := config.ExePath
-		if config.Args != "" {
-			binaryPath = fmt.Sprintf("\"%s\" %s", config.ExePath, config.Args)
+		serviceConfig := SCMServiceConfig{
+			BinaryPathName:   wrapperPath,
+			DisplayName:      config.Name,
+			Description:      serviceDescription(config),
+			ServiceStartName: account.StartName,
+			Password:         config.Password,
 		}
 
-		windowsService, err := scm.CreateService(serviceName, binaryPath, serviceConfig)
+		if err := config.StartType.applyTo(&serviceConfig); err != nil {
+			return err
+		}
+
+		if len(config.Dependencies) > 0 {
+			if err := wsm.validateServiceDependencies(scm, serviceName, config.Dependencies); err != nil {
+				return err
+			}
+			serviceConfig.Dependencies = config.Dependencies
+		}
+
+		windowsService, err := scm.CreateService(serviceName, serviceConfig)
 		if err != nil {
 			return fmt.Errorf("创建Windows服务失败: %v", err)
 		}
 		defer windowsService.Close()
 
-		wrapperPath, err := wsm.createServiceWrapper(serviceName, config.ExePath, config.Args, workingDir)
+		err = wsm.storeServiceConfigInRegistry(serviceName, config)
 		if err != nil {
 			windowsService.Delete()
-			return fmt.Errorf("创建服务包装器失败: %v", err)
+			return fmt.Errorf("存储服务配置失败: %v", err)
 		}
 
-		err = wsm.setServiceImagePathDirect(serviceName, wrapperPath)
-		if err != nil {
-			windowsService.Delete()
-			return fmt.Errorf("设置服务路径失败: %v", err)
+		if config.Recovery != nil {
+			err = applyServiceRecovery(windowsService, *config.Recovery)
+			if err != nil {
+				windowsService.Delete()
+				return fmt.Errorf("设置故障恢复失败: %v", err)
+			}
 		}
 
 		err = wsm.setServiceWorkingDirectory(serviceName, workingDir)
 		if err != nil {
-			fmt.Printf("警告：设置工作目录失败: %v\n", err)
+			wsm.warning(serviceName, EventConfigError, "设置工作目录失败: %v", err)
+		}
+
+		err = scm.RegisterEventSource(serviceName)
+		if err != nil {
+			wsm.warning(serviceName, EventConfigError, "%v", err)
+		}
+
+		createdAt := time.Now()
+		err = wsm.setServiceParameter(serviceName, parameterCreatedAt, QWordParameter(uint64(createdAt.Unix())))
+		if err != nil {
+			wsm.warning(serviceName, EventConfigError, "记录创建时间失败: %v", err)
 		}
 
 		service = &Service{
-			ID:         serviceName,
-			Name:       config.Name,
-			ExePath:    config.ExePath,
-			Args:       config.Args,
-			WorkingDir: workingDir,
-			Status:     "stopped",
-			PID:        0,
-			AutoStart:  false,
-			CreatedAt:  time.Now(),
-			UpdatedAt:  time.Now(),
+			ID:        serviceName,
+			Kind:      ServiceKindWrapper,
+			Status:    "stopped",
+			PID:       0,
+			CreatedAt: createdAt,
 		}
+		service.applyConfig(config)
 
 		return nil
 	})
@@ -296,11 +446,10 @@ func (wsm *WindowsServiceManager) CreateService(config ServiceConfig) (*Service,
 	}
 
 	wsm.services[serviceName] = service
-	wsm.saveServices()
-	
+
 	// 发射服务列表更新事件
 	wsm.emitServicesUpdated()
-	
+
 	// 自动启动服务
 	go func() {
 		time.Sleep(1 * time.Second)
@@ -319,8 +468,11 @@ func (wsm *WindowsServiceManager) StartService(serviceID string) error {
 	if !exists {
 		return fmt.Errorf("服务不存在: %s", serviceID)
 	}
+	if err := service.checkControllable(); err != nil {
+		return err
+	}
 
-	return wsm.withSCM(func(scm *mgr.Mgr) error {
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
 		windowsService, err := scm.OpenService(serviceID)
 		if err != nil {
 			return fmt.Errorf("打开服务失败: %v", err)
@@ -332,34 +484,11 @@ func (wsm *WindowsServiceManager) StartService(serviceID string) error {
 			return fmt.Errorf("查询服务状态失败: %v", err)
 		}
 
-		if status.State == svc.Running {
+		if status.State == StateRunning {
 			return fmt.Errorf("服务已经在运行")
 		}
 
-		err = windowsService.Start()
-		if err != nil {
-			return fmt.Errorf("启动服务失败: %v", err)
-		}
-
-		err = wsm.waitForServiceState(windowsService, svc.Running, 30*time.Second)
-		if err != nil {
-			service.Status = "error"
-			service.UpdatedAt = time.Now()
-			wsm.saveServices()
-			return err
-		}
-
-		status, _ = windowsService.Query()
-		service.Status = "running"
-		service.PID = int(status.ProcessId)
-		service.UpdatedAt = time.Now()
-		wsm.statusCache.Set(serviceID, "running", int(status.ProcessId))
-		wsm.saveServices()
-		
-		// 发射状态变化事件
-		wsm.emitServiceStatusChanged(serviceID, "running", int(status.ProcessId))
-
-		return nil
+		return wsm.startServiceLocked(windowsService, service)
 	})
 }
 
@@ -372,8 +501,11 @@ func (wsm *WindowsServiceManager) StopService(serviceID string) error {
 	if !exists {
 		return fmt.Errorf("服务不存在: %s", serviceID)
 	}
+	if err := service.checkControllable(); err != nil {
+		return err
+	}
 
-	return wsm.withSCM(func(scm *mgr.Mgr) error {
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
 		windowsService, err := scm.OpenService(serviceID)
 		if err != nil {
 			return fmt.Errorf("打开服务失败: %v", err)
@@ -385,59 +517,221 @@ func (wsm *WindowsServiceManager) StopService(serviceID string) error {
 			return fmt.Errorf("查询服务状态失败: %v", err)
 		}
 
-		if status.State == svc.Stopped {
+		if status.State == StateStopped {
 			service.Status = "stopped"
 			service.PID = 0
 			service.UpdatedAt = time.Now()
-			wsm.saveServices()
 			return nil
 		}
 
-		_, err = windowsService.Control(svc.Stop)
+		return wsm.stopServiceLocked(windowsService, service)
+	})
+}
+
+// maxRestartDelay RestartService 允许的最长启动前等待时间
+const maxRestartDelay = 10 * time.Minute
+
+// RestartService 重启Windows服务：停止并等待服务完全停止，等待 delayMs 毫秒后再启动。
+// 过程中发射 stopping、starting 状态变化事件。服务未运行时直接启动。
+// 等待期间不持有 wsm.mutex，只在更新服务记录时加锁。
+func (wsm *WindowsServiceManager) RestartService(serviceID string, delayMs int) error {
+	delay := time.Duration(delayMs) * time.Millisecond
+	if delayMs < 0 || delay > maxRestartDelay {
+		return fmt.Errorf("重启延迟必须在0到%d毫秒之间: %d", maxRestartDelay.Milliseconds(), delayMs)
+	}
+
+	wsm.mutex.RLock()
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		wsm.mutex.RUnlock()
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	err := service.checkControllable()
+	wsm.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
 		if err != nil {
-			return fmt.Errorf("发送停止信号失败: %v", err)
+			return fmt.Errorf("打开服务失败: %v", err)
 		}
+		defer windowsService.Close()
+
+		return wsm.restartService(windowsService, service, delay)
+	})
+}
+
+// startServiceLocked 启动服务并等待进入运行状态，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) startServiceLocked(windowsService ServiceHandle, service *Service) error {
+	err := windowsService.Start()
+	if err != nil {
+		return fmt.Errorf("启动服务失败: %v", err)
+	}
+
+	err = wsm.waitForServiceState(windowsService, StateRunning, 30*time.Second)
+	if err != nil {
+		service.Status = "error"
+		service.UpdatedAt = time.Now()
+		return err
+	}
+
+	wsm.markServiceRunningLocked(windowsService, service)
+	return nil
+}
+
+// stopServiceLocked 停止服务并等待进入停止状态，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) stopServiceLocked(windowsService ServiceHandle, service *Service) error {
+	if err := wsm.stopServiceAndWait(windowsService); err != nil {
+		return err
+	}
+
+	wsm.markServiceStoppedLocked(service)
+	return nil
+}
+
+// stopServiceAndWait 发送停止信号并等待服务进入停止状态，不访问服务记录
+func (wsm *WindowsServiceManager) stopServiceAndWait(windowsService ServiceHandle) error {
+	_, err := windowsService.Control(ControlStop)
+	if err != nil {
+		return fmt.Errorf("发送停止信号失败: %v", err)
+	}
+
+	return wsm.waitForServiceState(windowsService, StateStopped, 30*time.Second)
+}
+
+// markServiceRunningLocked 将服务记录更新为运行中并发射状态变化事件，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) markServiceRunningLocked(windowsService ServiceHandle, service *Service) {
+	status, _ := windowsService.Query()
+	service.Status = "running"
+	service.PID = int(status.ProcessId)
+	service.UpdatedAt = time.Now()
+	wsm.statusCache.Set(service.ID, "running", int(status.ProcessId))
+
+	// 发射状态变化事件
+	wsm.emitServiceStatusChanged(service.ID, "running", int(status.ProcessId))
+}
+
+// markServiceStoppedLocked 将服务记录更新为已停止并发射状态变化事件，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) markServiceStoppedLocked(service *Service) {
+	service.Status = "stopped"
+	service.PID = 0
+	service.UpdatedAt = time.Now()
+	wsm.statusCache.Set(service.ID, "stopped", 0)
+
+	// 发射状态变化事件
+	wsm.emitServiceStatusChanged(service.ID, "stopped", 0)
+}
 
-		err = wsm.waitForServiceState(windowsService, svc.Stopped, 30*time.Second)
+// restartService 按顺序停止、等待、启动服务，调用方不能持有 wsm.mutex：
+// 等待服务状态和启动前的延迟期间不加锁，只在更新服务记录时短暂加锁。
+// 服务正在启动或停止时先等待其进入稳定状态，避免与其他操作竞争。
+func (wsm *WindowsServiceManager) restartService(windowsService ServiceHandle, service *Service, delay time.Duration) error {
+	status, err := windowsService.Query()
+	if err != nil {
+		return fmt.Errorf("查询服务状态失败: %v", err)
+	}
+
+	switch status.State {
+	case StateStartPending, StateContinuePending:
+		if err := wsm.waitForServiceState(windowsService, StateRunning, 30*time.Second); err != nil {
+			return fmt.Errorf("等待服务启动完成失败: %v", err)
+		}
+		status.State = StateRunning
+	case StatePausePending:
+		if err := wsm.waitForServiceState(windowsService, StatePaused, 30*time.Second); err != nil {
+			return fmt.Errorf("等待服务暂停完成失败: %v", err)
+		}
+		status.State = StatePaused
+	}
+
+	if status.State != StateStopped {
+		wsm.statusCache.Set(service.ID, "stopping", int(status.ProcessId))
+		wsm.emitServiceStatusChanged(service.ID, "stopping", int(status.ProcessId))
+
+		if status.State == StateStopPending {
+			err = wsm.waitForServiceState(windowsService, StateStopped, 30*time.Second)
+		} else {
+			err = wsm.stopServiceAndWait(windowsService)
+		}
 		if err != nil {
-			return err
+			wsm.statusCache.Remove(service.ID)
+			return fmt.Errorf("重启服务时停止失败: %v", err)
 		}
 
-		service.Status = "stopped"
-		service.PID = 0
-		service.UpdatedAt = time.Now()
-		wsm.statusCache.Set(serviceID, "stopped", 0)
-		wsm.saveServices()
-		
-		// 发射状态变化事件
-		wsm.emitServiceStatusChanged(serviceID, "stopped", 0)
+		wsm.mutex.Lock()
+		wsm.markServiceStoppedLocked(service)
+		wsm.mutex.Unlock()
+	}
 
-		return nil
-	})
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+
+	wsm.statusCache.Set(service.ID, "starting", 0)
+	wsm.emitServiceStatusChanged(service.ID, "starting", 0)
+
+	started := false
+	if err = windowsService.Start(); err != nil {
+		err = fmt.Errorf("启动服务失败: %v", err)
+	} else {
+		started = true
+		err = wsm.waitForServiceState(windowsService, StateRunning, 30*time.Second)
+	}
+
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	if err != nil {
+		if started {
+			service.Status = "error"
+			service.UpdatedAt = time.Now()
+		}
+		wsm.statusCache.Remove(service.ID)
+		wsm.emitServiceStatusChanged(service.ID, service.Status, 0)
+		return fmt.Errorf("重启服务时启动失败: %v", err)
+	}
+
+	wsm.markServiceRunningLocked(windowsService, service)
+	return nil
 }
 
-// DeleteService 删除Windows服务
+// DeleteService 删除Windows服务，外部服务只取消纳管
 func (wsm *WindowsServiceManager) DeleteService(serviceID string) error {
 	wsm.mutex.Lock()
 	defer wsm.mutex.Unlock()
 
-	_, exists := wsm.services[serviceID]
+	service, exists := wsm.services[serviceID]
 	if !exists {
 		return fmt.Errorf("服务不存在: %s", serviceID)
 	}
 
-	return wsm.withSCM(func(scm *mgr.Mgr) error {
+	// 外部服务只取消纳管，不删除服务本身
+	if service.isExternal() {
+		return wsm.releaseExternalServiceLocked(serviceID)
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
 		windowsService, err := scm.OpenService(serviceID)
+		if isServiceNotExist(err) {
+			// 服务已在SCM中删除，只移除孤立的记录
+			delete(wsm.services, serviceID)
+			delete(wsm.refreshedAt, serviceID)
+			wsm.statusCache.Remove(serviceID)
+			wsm.emitServicesUpdated()
+			return nil
+		}
 		if err != nil {
 			return fmt.Errorf("打开服务失败: %v", err)
 		}
 		defer windowsService.Close()
 
 		status, err := windowsService.Query()
-		if err == nil && status.State != svc.Stopped {
-			windowsService.Control(svc.Stop)
+		if err == nil && status.State != StateStopped {
+			windowsService.Control(ControlStop)
 
-			wsm.waitForServiceState(windowsService, svc.Stopped, 30*time.Second)
+			wsm.waitForServiceState(windowsService, StateStopped, 30*time.Second)
 		}
 
 		err = windowsService.Delete()
@@ -445,10 +739,13 @@ func (wsm *WindowsServiceManager) DeleteService(serviceID string) error {
 			return fmt.Errorf("删除服务失败: %v", err)
 		}
 
+		// 删除服务对应的事件源，已写入的事件仍保留在事件日志中
+		scm.RemoveEventSource(serviceID)
+
 		delete(wsm.services, serviceID)
+		delete(wsm.refreshedAt, serviceID)
 		wsm.statusCache.Remove(serviceID)
-		wsm.saveServices()
-		
+
 		// 发射服务列表更新事件
 		wsm.emitServicesUpdated()
 
@@ -456,8 +753,88 @@ func (wsm *WindowsServiceManager) DeleteService(serviceID string) error {
 	})
 }
 
+// GetServiceEnvironment 获取服务的环境变量配置
+func (wsm *WindowsServiceManager) GetServiceEnvironment(serviceID string) ([]string, error) {
+	wsm.mutex.RLock()
+	defer wsm.mutex.RUnlock()
+
+	if _, exists := wsm.services[serviceID]; !exists {
+		return nil, fmt.Errorf("服务不存在: %s", serviceID)
+	}
+
+	config, err := wsm.loadServiceConfig(serviceID)
+	if err != nil {
+		return nil, err
+	}
+
+	return config.Environment, nil
+}
+
+// serviceTimestampLogs 返回服务是否为每行日志加上时间戳
+func (wsm *WindowsServiceManager) serviceTimestampLogs(serviceID string) (bool, error) {
+	wsm.mutex.RLock()
+	defer wsm.mutex.RUnlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return false, fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	return service.TimestampLogs, nil
+}
+
+// SetServiceEnvironment 设置服务的环境变量配置，服务下次启动时生效
+func (wsm *WindowsServiceManager) SetServiceEnvironment(serviceID string, environment []string) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkConfigurable(); err != nil {
+		return err
+	}
+
+	environment, err := normalizeEnvironment(environment)
+	if err != nil {
+		return err
+	}
+
+	err = wsm.withServiceParameters(serviceID, func(store ServiceParameterStore) error {
+		config, err := decodeServiceParameters(store, serviceID)
+		if err != nil {
+			return err
+		}
+		config.Environment = environment
+		return encodeServiceParameters(store, *config)
+	})
+	if err != nil {
+		return fmt.Errorf("保存环境变量失败: %v", err)
+	}
+
+	service.Environment = environment
+	service.UpdatedAt = time.Now()
+	wsm.emitServicesUpdated()
+
+	return nil
+}
+
+// normalizeEnvironment 校验环境变量配置并转换为统一的存储格式
+func normalizeEnvironment(environment []string) ([]string, error) {
+	entries, err := ParseEnvEntries(environment)
+	if err != nil {
+		return nil, fmt.Errorf("环境变量配置无效: %v", err)
+	}
+
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		normalized = append(normalized, entry.String())
+	}
+	return normalized, nil
+}
+
 // getServiceRealTimeStatus 获取服务实时状态（使用缓存优化）
-func (wsm *WindowsServiceManager) getServiceRealTimeStatus(scm *mgr.Mgr, serviceName string) (string, int) {
+func (wsm *WindowsServiceManager) getServiceRealTimeStatus(scm ServiceControlBackend, serviceName string) (string, int) {
 	if cachedStatus, found := wsm.statusCache.Get(serviceName); found {
 		return cachedStatus.Status, cachedStatus.PID
 	}
@@ -479,16 +856,16 @@ func (wsm *WindowsServiceManager) getServiceRealTimeStatus(scm *mgr.Mgr, service
 	var pid int
 
 	switch status.State {
-	case svc.Running:
+	case StateRunning:
 		statusStr = "running"
 		pid = int(status.ProcessId)
-	case svc.Stopped:
+	case StateStopped:
 		statusStr = "stopped"
 		pid = 0
-	case svc.StartPending:
+	case StateStartPending:
 		statusStr = "starting"
 		pid = 0
-	case svc.StopPending:
+	case StateStopPending:
 		statusStr = "stopping"
 		pid = int(status.ProcessId)
 	default:
@@ -501,95 +878,38 @@ func (wsm *WindowsServiceManager) getServiceRealTimeStatus(scm *mgr.Mgr, service
 	return statusStr, pid
 }
 
-// generateServiceName 生成唯一的服务名称
-func (wsm *WindowsServiceManager) generateServiceName(displayName string) string {
-	cleanName := strings.Map(func(r rune) rune {
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
-			return r
-		}
-		return '_'
-	}, displayName)
-
-	return fmt.Sprintf("WSM_%s_%d", cleanName, time.Now().Unix())
-}
-
-// saveServices 保存服务数据到文件
-func (wsm *WindowsServiceManager) saveServices() {
-	data, err := json.MarshalIndent(wsm.services, "", "  ")
+// refreshServiceFromSCM 从SCM读取服务的启动类型、运行账户和故障恢复配置，读取失败时保留原值；
+// 服务已在SCM中删除，或包装器服务的 ImagePath 不再指向包装器时标记为孤立。调用方需持有 wsm.mutex 的写锁
+func (wsm *WindowsServiceManager) refreshServiceFromSCM(scm ServiceControlBackend, service *Service) {
+	windowsService, err := scm.OpenService(service.ID)
 	if err != nil {
+		if isServiceNotExist(err) {
+			service.Orphaned = true
+		}
 		return
 	}
-	os.WriteFile(wsm.dataFile, data, 0644)
-}
+	defer windowsService.Close()
 
-// loadServices 从文件加载服务数据
-func (wsm *WindowsServiceManager) loadServices() {
-	if _, err := os.Stat(wsm.dataFile); os.IsNotExist(err) {
-		return
+	if config, err := windowsService.Config(); err == nil {
+		service.Orphaned = !service.isExternal() && !isWrapperImagePath(config.BinaryPathName, service.ID)
+		service.StartType = serviceStartTypeFromConfig(config)
+		service.Account = config.ServiceStartName
+		service.Dependencies = config.Dependencies
 	}
 
-	data, err := os.ReadFile(wsm.dataFile)
-	if err != nil {
-		return
+	if recovery, err := queryServiceRecovery(windowsService); err == nil {
+		service.Recovery = recovery
 	}
-
-	json.Unmarshal(data, &wsm.services)
 }
 
-// SetServiceAutoStart 设置服务开机自启动
-func (wsm *WindowsServiceManager) SetServiceAutoStart(serviceID string, enabled bool) error {
-	wsm.mutex.Lock()
-	defer wsm.mutex.Unlock()
-
-	service, exists := wsm.services[serviceID]
-	if !exists {
-		return fmt.Errorf("服务不存在: %s", serviceID)
-	}
-
-	return wsm.withSCM(func(scm *mgr.Mgr) error {
-		windowsService, err := scm.OpenService(serviceID)
-		if err != nil {
-			return fmt.Errorf("打开服务失败: %v", err)
-		}
-		defer windowsService.Close()
-
-		// 获取当前服务配置
-		config, err := windowsService.Config()
-		if err != nil {
-			return fmt.Errorf("获取服务配置失败: %v", err)
-		}
-
-		// 修改启动类型
-		if enabled {
-			config.StartType = mgr.StartAutomatic
-		} else {
-			config.StartType = mgr.StartManual
-		}
-
-		// 更新服务配置
-		err = windowsService.UpdateConfig(config)
-		if err != nil {
-			return fmt.Errorf("更新服务配置失败: %v", err)
+// generateServiceName 生成唯一的服务名称
+func (wsm *WindowsServiceManager) generateServiceName(displayName string) string {
+	cleanName := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
 		}
+		return '_'
+	}, displayName)
 
-		// 更新内存中的服务信息
-		service.AutoStart = enabled
-		service.UpdatedAt = time.Now()
-		wsm.saveServices()
-
-		return nil
-	})
-}
-
-// GetServiceAutoStart 获取服务开机自启动状态
-func (wsm *WindowsServiceManager) GetServiceAutoStart(serviceID string) bool {
-	wsm.mutex.RLock()
-	defer wsm.mutex.RUnlock()
-
-	service, exists := wsm.services[serviceID]
-	if !exists {
-		return false
-	}
-
-	return service.AutoStart
+	return fmt.Sprintf("WSM_%s_%d", cleanName, time.Now().Unix())
 }
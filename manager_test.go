@@ -0,0 +1,544 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestManager 创建使用 FakeSCM 和内存参数存储的服务管理器
+func newTestManager(t *testing.T) (*WindowsServiceManager, *FakeSCM, *MemoryParameterProvider) {
+	t.Helper()
+
+	scm := NewFakeSCM()
+	parameters := NewMemoryParameterProvider()
+	externalServices := func(writable bool) (ServiceParameterStore, error) {
+		return parameters.Open("ExternalServices", writable)
+	}
+	return NewWindowsServiceManagerWithBackend(scm.Connect, parameters, externalServices), scm, parameters
+}
+
+// testServiceConfig 返回目标程序存在的服务配置
+func testServiceConfig(t *testing.T, name string) ServiceConfig {
+	t.Helper()
+
+	exePath := filepath.Join(t.TempDir(), "app.exe")
+	if err := os.WriteFile(exePath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return ServiceConfig{Name: name, ExePath: exePath, StartType: ServiceStartManual}
+}
+
+// scmConfigOf 读取模拟服务的SCM配置
+func scmConfigOf(t *testing.T, scm *FakeSCM, name string) SCMServiceConfig {
+	t.Helper()
+
+	handle, err := scm.OpenService(name)
+	if err != nil {
+		t.Fatalf("打开服务 %s: %v", name, err)
+	}
+	defer handle.Close()
+
+	config, err := handle.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestCreateServiceUsesWrapperImagePath(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	config := testServiceConfig(t, "Demo App")
+	config.ArgList = []string{"--port", "8080"}
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	scmConfig := scmConfigOf(t, scm, service.ID)
+	if strings.HasPrefix(scmConfig.BinaryPathName, `""`) {
+		t.Fatalf("ImagePath 被重复加引号: %s", scmConfig.BinaryPathName)
+	}
+	name, ok := parseWrapperImagePath(scmConfig.BinaryPathName)
+	if !ok || name != service.ID {
+		t.Fatalf("ImagePath 不是包装器模式: %s", scmConfig.BinaryPathName)
+	}
+	if scmConfig.DisplayName != "Demo App" || scmConfig.StartType != scmStartManual {
+		t.Fatalf("SCM配置不正确: %+v", scmConfig)
+	}
+	if !scm.HasEventSource(service.ID) {
+		t.Fatalf("未注册事件源")
+	}
+
+	stored, err := wsm.loadServiceConfig(service.ID)
+	if err != nil {
+		t.Fatalf("loadServiceConfig: %v", err)
+	}
+	if stored.ExePath != config.ExePath || !reflect.DeepEqual(stored.ArgList, config.ArgList) {
+		t.Fatalf("服务参数不正确: %+v", stored)
+	}
+}
+
+func TestCreateServiceAccount(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	config := testServiceConfig(t, "Account")
+	config.Account = `CORP\svc`
+	if _, err := wsm.CreateService(config); err == nil {
+		t.Fatalf("用户账户缺少密码时应返回错误")
+	}
+	if names, _ := scm.ListServices(); len(names) != 0 {
+		t.Fatalf("校验失败时不应创建服务: %v", names)
+	}
+
+	config.Password = "secret"
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if got := scmConfigOf(t, scm, service.ID).ServiceStartName; got != `CORP\svc` {
+		t.Fatalf("ServiceStartName = %q", got)
+	}
+	if !scm.HasServiceLogonRight(`CORP\svc`) {
+		t.Fatalf("未授予作为服务登录权限")
+	}
+
+	if err := wsm.SetServiceAccount(service.ID, "virtual", ""); err != nil {
+		t.Fatalf("SetServiceAccount: %v", err)
+	}
+	if got := scmConfigOf(t, scm, service.ID).ServiceStartName; got != `NT SERVICE\`+service.ID {
+		t.Fatalf("ServiceStartName = %q", got)
+	}
+}
+
+func TestServiceRecoveryRoundTrip(t *testing.T) {
+	wsm, _, _ := newTestManager(t)
+
+	config := testServiceConfig(t, "Recovery")
+	config.Recovery = &RecoveryConfig{
+		Actions:        []RecoveryAction{{Type: RecoveryRestart, DelayMs: 1000}},
+		ResetPeriodSec: 3600,
+	}
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	recovery, err := wsm.GetServiceRecovery(service.ID)
+	if err != nil {
+		t.Fatalf("GetServiceRecovery: %v", err)
+	}
+	if !reflect.DeepEqual(*recovery, *config.Recovery) {
+		t.Fatalf("恢复配置 = %+v, 期望 %+v", recovery, config.Recovery)
+	}
+
+	updated := RecoveryConfig{
+		Actions: []RecoveryAction{
+			{Type: RecoveryRunCommand, DelayMs: 0},
+			{Type: RecoveryNone},
+		},
+		ResetPeriodSec:     -1,
+		Command:            `C:\notify.exe`,
+		OnNonCrashFailures: true,
+	}
+	if err := wsm.SetServiceRecovery(service.ID, updated); err != nil {
+		t.Fatalf("SetServiceRecovery: %v", err)
+	}
+	if recovery, _ = wsm.GetServiceRecovery(service.ID); !reflect.DeepEqual(*recovery, updated) {
+		t.Fatalf("恢复配置 = %+v, 期望 %+v", recovery, updated)
+	}
+
+	if err := wsm.SetServiceRecovery(service.ID, RecoveryConfig{Actions: []RecoveryAction{{Type: "bogus"}}}); err == nil {
+		t.Fatalf("不支持的恢复动作应返回错误")
+	}
+}
+
+func TestUpdateServiceDependencies(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	if _, err := scm.CreateService("Tcpip", SCMServiceConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := testServiceConfig(t, "Deps")
+	config.Dependencies = []string{"Tcpip"}
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if got := scmConfigOf(t, scm, service.ID).Dependencies; !reflect.DeepEqual(got, []string{"Tcpip"}) {
+		t.Fatalf("Dependencies = %v", got)
+	}
+
+	// Dependencies 为 nil 时保留原有依赖
+	config.Dependencies = nil
+	config.Description = "更新后的描述"
+	if _, err := wsm.UpdateService(service.ID, config, false); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	scmConfig := scmConfigOf(t, scm, service.ID)
+	if !reflect.DeepEqual(scmConfig.Dependencies, []string{"Tcpip"}) || scmConfig.Description != "更新后的描述" {
+		t.Fatalf("SCM配置 = %+v", scmConfig)
+	}
+
+	// 空列表清除依赖
+	config.Dependencies = []string{}
+	if _, err := wsm.UpdateService(service.ID, config, false); err != nil {
+		t.Fatalf("UpdateService: %v", err)
+	}
+	if got := scmConfigOf(t, scm, service.ID).Dependencies; len(got) != 0 {
+		t.Fatalf("Dependencies = %v, 期望清除", got)
+	}
+
+	config.Dependencies = []string{"Missing"}
+	if _, err := wsm.UpdateService(service.ID, config, false); err == nil {
+		t.Fatalf("依赖不存在的服务时应返回错误")
+	}
+}
+
+func TestStartStopDeleteService(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Lifecycle"))
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	if err := wsm.StartService(service.ID); err != nil {
+		t.Fatalf("StartService: %v", err)
+	}
+	if service.Status != "running" || service.PID == 0 {
+		t.Fatalf("启动后状态 = %s, PID = %d", service.Status, service.PID)
+	}
+
+	if err := wsm.StopService(service.ID); err != nil {
+		t.Fatalf("StopService: %v", err)
+	}
+	if service.Status != "stopped" {
+		t.Fatalf("停止后状态 = %s", service.Status)
+	}
+
+	if err := wsm.DeleteService(service.ID); err != nil {
+		t.Fatalf("DeleteService: %v", err)
+	}
+	if scm.Exists(service.ID) || scm.HasEventSource(service.ID) {
+		t.Fatalf("删除后服务或事件源仍存在")
+	}
+}
+
+func TestStartServiceFailure(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Failing"))
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+	if err := scm.SetBehavior(service.ID, FakeServiceBehavior{FailOnStart: true, ExitCode: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wsm.StartService(service.ID); err == nil {
+		t.Fatalf("服务启动失败时应返回错误")
+	}
+}
+
+func TestCreateServiceReportsWarnings(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+	sink := &RecordingEventSink{}
+	wsm.SetEventSink(sink)
+	scm.RegisterEventSourceError = errors.New("access denied")
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Warn"))
+	if err != nil {
+		t.Fatalf("注册事件源失败不应导致创建失败: %v", err)
+	}
+
+	events := sink.Events()
+	if len(events) != 1 {
+		t.Fatalf("事件 = %+v", events)
+	}
+	if event := events[0]; event.Level != WrapperEventWarning || event.ID != EventConfigError ||
+		event.Service != service.ID || !strings.Contains(event.Message, "access denied") {
+		t.Fatalf("警告事件 = %+v", event)
+	}
+}
+
+func TestGetServicesCachesSCMConfig(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Cached"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wsm.GetServices(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 在管理器之外修改启动类型，例如通过 services.msc
+	handle, _ := scm.OpenService(service.ID)
+	config, _ := handle.Config()
+	config.StartType = scmStartDisabled
+	if err := handle.UpdateConfig(config); err != nil {
+		t.Fatal(err)
+	}
+	handle.Close()
+
+	services, err := wsm.GetServices()
+	if err != nil || len(services) != 1 {
+		t.Fatalf("GetServices = %v, %v", services, err)
+	}
+	if services[0].StartType != ServiceStartManual {
+		t.Fatalf("缓存有效期内不应重新读取配置: %s", services[0].StartType)
+	}
+
+	services, err = wsm.RefreshServices()
+	if err != nil || services[0].StartType != ServiceStartDisabled {
+		t.Fatalf("RefreshServices 后启动类型 = %v, %v", services, err)
+	}
+
+	// 缓存过期后 GetServices 重新读取配置
+	config.StartType = scmStartAutomatic
+	handle, _ = scm.OpenService(service.ID)
+	handle.UpdateConfig(config)
+	handle.Close()
+	wsm.refreshedAt[service.ID] = time.Now().Add(-serviceConfigRefreshInterval)
+	if services, _ := wsm.GetServices(); services[0].StartType != ServiceStartAutomatic {
+		t.Fatalf("缓存过期后启动类型 = %s", services[0].StartType)
+	}
+}
+
+func TestServiceStartTypeRoundTrip(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	config := testServiceConfig(t, "StartType")
+	config.StartType = ServiceStartDelayed
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scmConfig := scmConfigOf(t, scm, service.ID); scmConfig.StartType != scmStartAutomatic || !scmConfig.DelayedAutoStart {
+		t.Fatalf("SCM配置 = %+v", scmConfig)
+	}
+
+	for _, startType := range []ServiceStartType{ServiceStartDisabled, ServiceStartManual, ServiceStartAutomatic, ServiceStartDelayed} {
+		if err := wsm.SetServiceStartType(service.ID, startType); err != nil {
+			t.Fatalf("SetServiceStartType(%s): %v", startType, err)
+		}
+		if got, err := wsm.GetServiceStartType(service.ID); err != nil || got != startType || service.StartType != startType {
+			t.Fatalf("启动类型 = %s, %s, %v; 期望 %s", got, service.StartType, err, startType)
+		}
+	}
+
+	if err := wsm.SetServiceStartType(service.ID, "boot"); err == nil {
+		t.Fatalf("不支持的启动类型应返回错误")
+	}
+}
+
+func TestUpdateServiceRejectsEmptyName(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	config := testServiceConfig(t, "Named")
+	service, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config.Name = "  "
+	config.Args = "--changed"
+	if _, err := wsm.UpdateService(service.ID, config, false); err == nil {
+		t.Fatalf("名称为空时应返回错误")
+	}
+	if got := scmConfigOf(t, scm, service.ID).DisplayName; got != "Named" {
+		t.Fatalf("DisplayName = %q", got)
+	}
+	if stored, _ := wsm.loadServiceConfig(service.ID); stored.Args != "" || service.Name != "Named" {
+		t.Fatalf("拒绝更新时不应修改配置: %+v", stored)
+	}
+}
+
+func TestSetServiceEnvironment(t *testing.T) {
+	wsm, _, parameters := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟旧版本写入的参数：没有格式版本
+	store, err := parameters.Open(service.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Delete(parameterSchemaVersion)
+	store.Close()
+
+	if err := wsm.SetServiceEnvironment(service.ID, []string{"A=1", "", "PATH+=C:\\bin"}); err != nil {
+		t.Fatalf("SetServiceEnvironment: %v", err)
+	}
+	want := []string{"A=1", "PATH+=C:\\bin"}
+	if stored, err := wsm.loadServiceConfig(service.ID); err != nil || !reflect.DeepEqual(stored.Environment, want) {
+		t.Fatalf("保存的环境变量 = %+v, %v", stored, err)
+	}
+	if !reflect.DeepEqual(service.Environment, want) {
+		t.Fatalf("服务记录环境变量 = %q", service.Environment)
+	}
+
+	store, err = parameters.Open(service.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, exists, _ := store.Get(parameterSchemaVersion)
+	store.Close()
+	if !exists || value.Number != serviceParametersVersion {
+		t.Fatalf("保存环境变量后应写入格式版本: %+v, %v", value, exists)
+	}
+
+	if err := wsm.SetServiceEnvironment(service.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := wsm.loadServiceConfig(service.ID); len(stored.Environment) != 0 {
+		t.Fatalf("清空后环境变量 = %q", stored.Environment)
+	}
+
+	if err := wsm.SetServiceEnvironment(service.ID, []string{"BAD"}); err == nil {
+		t.Fatalf("无效配置应返回错误")
+	}
+}
+
+// statusRecorder 记录 service-status-changed 事件中的状态
+type statusRecorder struct {
+	mutex    sync.Mutex
+	statuses []string
+	notify   chan string
+}
+
+func recordServiceStatus(wsm *WindowsServiceManager) *statusRecorder {
+	recorder := &statusRecorder{notify: make(chan string, 16)}
+	wsm.emitEvent = func(name string, data ...interface{}) {
+		if name != "service-status-changed" {
+			return
+		}
+		status := data[0].(map[string]interface{})["status"].(string)
+		recorder.mutex.Lock()
+		recorder.statuses = append(recorder.statuses, status)
+		recorder.mutex.Unlock()
+		recorder.notify <- status
+	}
+	return recorder
+}
+
+func (recorder *statusRecorder) Statuses() []string {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	return append([]string(nil), recorder.statuses...)
+}
+
+func TestRestartService(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "Restart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wsm.StartService(service.ID); err != nil {
+		t.Fatal(err)
+	}
+	oldPID := service.PID
+	recorder := recordServiceStatus(wsm)
+
+	done := make(chan error, 1)
+	go func() { done <- wsm.RestartService(service.ID, 300) }()
+
+	for status := range recorder.notify {
+		if status == "stopped" {
+			break
+		}
+	}
+	// 启动前的延迟期间不持有锁，其他操作可以继续
+	if _, err := wsm.GetServices(); err != nil {
+		t.Fatal(err)
+	}
+	if got := recorder.Statuses(); !reflect.DeepEqual(got, []string{"stopping", "stopped"}) {
+		t.Fatalf("延迟期间的事件 = %q", got)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("RestartService: %v", err)
+	}
+	if got := recorder.Statuses(); !reflect.DeepEqual(got, []string{"stopping", "stopped", "starting", "running"}) {
+		t.Fatalf("重启事件 = %q", got)
+	}
+	if queryState(t, scm, service.ID) != StateRunning || service.Status != "running" || service.PID == 0 || service.PID == oldPID {
+		t.Fatalf("重启后状态 = %s, PID = %d（原PID %d）", service.Status, service.PID, oldPID)
+	}
+}
+
+func TestRestartServiceStopped(t *testing.T) {
+	wsm, _, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "RestartStopped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := recordServiceStatus(wsm)
+
+	if err := wsm.RestartService(service.ID, 0); err != nil {
+		t.Fatalf("RestartService: %v", err)
+	}
+	if got := recorder.Statuses(); !reflect.DeepEqual(got, []string{"starting", "running"}) {
+		t.Fatalf("未运行服务的重启事件 = %q", got)
+	}
+}
+
+func TestRestartServiceStartFailure(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "RestartFailing"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wsm.StartService(service.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := scm.SetBehavior(service.ID, FakeServiceBehavior{FailOnStart: true, ExitCode: 3}); err != nil {
+		t.Fatal(err)
+	}
+	recorder := recordServiceStatus(wsm)
+
+	if err := wsm.RestartService(service.ID, 0); err == nil || !strings.Contains(err.Error(), "重启服务时启动失败") {
+		t.Fatalf("启动失败时应返回错误: %v", err)
+	}
+	if got := recorder.Statuses(); !reflect.DeepEqual(got, []string{"stopping", "stopped", "starting", "error"}) {
+		t.Fatalf("启动失败的重启事件 = %q", got)
+	}
+	if service.Status != "error" {
+		t.Fatalf("启动失败后状态 = %s", service.Status)
+	}
+}
+
+func TestRestartServiceRejectsInvalidDelay(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	service, err := wsm.CreateService(testServiceConfig(t, "RestartDelay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wsm.StartService(service.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, delayMs := range []int{-1, int(maxRestartDelay.Milliseconds()) + 1} {
+		if err := wsm.RestartService(service.ID, delayMs); err == nil {
+			t.Errorf("RestartService(%d) 应返回错误", delayMs)
+		}
+	}
+	if queryState(t, scm, service.ID) != StateRunning {
+		t.Fatalf("延迟无效时不应停止服务")
+	}
+}
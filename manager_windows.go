@@ -0,0 +1,8 @@
+//go:build windows
+
+package main
+
+// NewWindowsServiceManager 创建新的Windows服务管理器
+func NewWindowsServiceManager() *WindowsServiceManager {
+	return NewWindowsServiceManagerWithBackend(connectWindowsSCM, RegistryParameterProvider{}, openExternalServicesStore)
+}
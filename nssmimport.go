@@ -0,0 +1,507 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nssmExitKey NSSM 保存退出动作的子键，默认值为默认动作，其余值名为退出码
+const nssmExitKey = "AppExit"
+
+// nssmDailyRotateSeconds AppRotateSeconds 为一天时按跨天轮转处理
+const nssmDailyRotateSeconds = 24 * 60 * 60
+
+// NssmImportItem 单个 NSSM 服务的导入结果
+type NssmImportItem struct {
+	ServiceName string         `json:"serviceName"`
+	DisplayName string         `json:"displayName"`
+	ImagePath   string         `json:"imagePath"`
+	Config      *ServiceConfig `json:"config"`   // 转换后的服务配置
+	Unmapped    []string       `json:"unmapped"` // 无法转换、导入后会丢失的 NSSM 参数
+	Warnings    []string       `json:"warnings"` // 已转换但行为与 NSSM 不完全一致的参数
+	Imported    bool           `json:"imported"`
+	Error       string         `json:"error"`
+}
+
+// NssmImportReport NSSM 服务导入报告
+type NssmImportReport struct {
+	DryRun   bool             `json:"dryRun"`
+	Services []NssmImportItem `json:"services"`
+}
+
+// nssmParameters 从 NSSM 服务 Parameters 键读取的参数
+type nssmParameters struct {
+	strings map[string]string   // REG_SZ / REG_EXPAND_SZ（未展开）
+	multi   map[string][]string // REG_MULTI_SZ
+	ints    map[string]uint64   // REG_DWORD / REG_QWORD
+	exit    map[string]string   // AppExit 子键，"" 为默认动作
+	subKeys []string            // 除 AppExit 外的子键
+}
+
+// isNssmImagePath 判断服务的 ImagePath 是否指向 nssm.exe
+func isNssmImagePath(imagePath string) bool {
+	args := SplitCommandLine(imagePath)
+	if len(args) == 0 {
+		return false
+	}
+	// ImagePath 总是 Windows 路径，按两种分隔符取文件名
+	exe := args[0][strings.LastIndexAny(args[0], `\/`)+1:]
+	return strings.EqualFold(exe, "nssm.exe")
+}
+
+// readNssmParameters 从服务参数读取 NSSM 的配置
+func (wsm *WindowsServiceManager) readNssmParameters(serviceName string) (*nssmParameters, error) {
+	store, err := wsm.parameters.Open(serviceName, false)
+	if err != nil {
+		return nil, fmt.Errorf("打开NSSM参数失败: %v", err)
+	}
+	defer store.Close()
+
+	params := &nssmParameters{
+		strings: make(map[string]string),
+		multi:   make(map[string][]string),
+		ints:    make(map[string]uint64),
+		exit:    make(map[string]string),
+	}
+
+	names, err := store.Names()
+	if err != nil {
+		return nil, fmt.Errorf("读取NSSM参数失败: %v", err)
+	}
+	for _, name := range names {
+		value, ok, err := store.Get(name)
+		if err != nil || !ok {
+			continue
+		}
+		switch {
+		case value.isText():
+			params.strings[name] = value.String
+		case value.Kind == ParameterStrings:
+			params.multi[name] = value.Strings
+		case value.isInteger():
+			params.ints[name] = value.Number
+		}
+	}
+
+	subStores, ok := store.(parameterSubStores)
+	if !ok {
+		return params, nil
+	}
+	subNames, err := subStores.SubNames()
+	if err != nil {
+		return nil, fmt.Errorf("读取NSSM参数子键失败: %v", err)
+	}
+	for _, subName := range subNames {
+		if !strings.EqualFold(subName, nssmExitKey) {
+			params.subKeys = append(params.subKeys, subName)
+			continue
+		}
+		if err := readNssmExitActions(subStores, subName, params.exit); err != nil {
+			return nil, err
+		}
+	}
+
+	return params, nil
+}
+
+// readNssmExitActions 读取 AppExit 子键中的退出动作
+func readNssmExitActions(parent parameterSubStores, subName string, exit map[string]string) error {
+	store, err := parent.OpenSub(subName)
+	if err != nil {
+		return fmt.Errorf("打开NSSM退出动作失败: %v", err)
+	}
+	defer store.Close()
+
+	names, err := store.Names()
+	if err != nil {
+		return fmt.Errorf("读取NSSM退出动作失败: %v", err)
+	}
+	for _, name := range names {
+		if value, ok, err := store.Get(name); err == nil && ok && value.isText() {
+			exit[name] = value.String
+		}
+	}
+	return nil
+}
+
+// nssmConversion 转换 NSSM 参数时收集的配置和说明
+type nssmConversion struct {
+	params   *nssmParameters
+	config   ServiceConfig
+	handled  map[string]bool
+	unmapped []string
+	warnings []string
+}
+
+func (c *nssmConversion) str(name string) (string, bool) {
+	c.handled[name] = true
+	value, ok := c.params.strings[name]
+	return value, ok
+}
+
+func (c *nssmConversion) num(name string) (uint64, bool) {
+	c.handled[name] = true
+	value, ok := c.params.ints[name]
+	return value, ok
+}
+
+func (c *nssmConversion) list(name string) ([]string, bool) {
+	c.handled[name] = true
+	value, ok := c.params.multi[name]
+	return value, ok
+}
+
+func (c *nssmConversion) unmap(format string, args ...interface{}) {
+	c.unmapped = append(c.unmapped, fmt.Sprintf(format, args...))
+}
+
+func (c *nssmConversion) warn(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// convertNssmParameters 将 NSSM 参数转换为服务配置，返回无法转换的参数和需要注意的差异
+func convertNssmParameters(params *nssmParameters) (ServiceConfig, []string, []string) {
+	c := &nssmConversion{params: params, handled: make(map[string]bool)}
+
+	if application, ok := c.str("Application"); ok {
+		c.config.ExePath = expandRegistryString(application)
+	}
+	if appParameters, ok := c.str("AppParameters"); ok {
+		c.config.Args = expandRegistryString(appParameters)
+	}
+	if appDirectory, ok := c.str("AppDirectory"); ok {
+		c.config.WorkingDir = expandRegistryString(appDirectory)
+	}
+
+	c.convertEnvironment()
+	c.convertLogging()
+	c.convertExitActions()
+	c.convertStopMethods()
+
+	if restartDelay, ok := c.num("AppRestartDelay"); ok && restartDelay > 0 {
+		c.config.RestartDelayMs = int(restartDelay)
+	}
+	if killTree, ok := c.num("AppKillProcessTree"); ok && killTree == 0 {
+		c.config.DetachChildren = true
+	}
+
+	names := make([]string, 0, len(params.strings)+len(params.multi)+len(params.ints))
+	for name := range params.strings {
+		names = append(names, name)
+	}
+	for name := range params.multi {
+		names = append(names, name)
+	}
+	for name := range params.ints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !c.handled[name] {
+			c.unmap("%s = %s", name, params.describe(name))
+		}
+	}
+	for _, subKey := range params.subKeys {
+		c.unmap(`%s\`, subKey)
+	}
+
+	return c.config, c.unmapped, c.warnings
+}
+
+// convertEnvironment 转换 AppEnvironment 和 AppEnvironmentExtra
+func (c *nssmConversion) convertEnvironment() {
+	var entries []string
+
+	if environment, ok := c.list("AppEnvironment"); ok && len(environment) > 0 {
+		c.warn("AppEnvironment 在 NSSM 中替换整个环境，导入后改为在继承的环境上设置这些变量")
+		entries = append(entries, environment...)
+	}
+	if extra, ok := c.list("AppEnvironmentExtra"); ok {
+		entries = append(entries, extra...)
+	}
+
+	for _, entry := range entries {
+		if entry == "" {
+			continue
+		}
+		parsed, err := ParseEnvEntry(entry)
+		if err != nil || parsed.Op != EnvSet {
+			c.unmap("环境变量 %s", entry)
+			continue
+		}
+		c.config.Environment = append(c.config.Environment, parsed.String())
+	}
+}
+
+// convertLogging 转换输出重定向和日志轮转参数。
+// 包装器的日志固定写入日志目录，无法保留 NSSM 中自定义的日志路径。
+func (c *nssmConversion) convertLogging() {
+	stdout, hasStdout := c.str("AppStdout")
+	stderr, hasStderr := c.str("AppStderr")
+	if hasStdout && stdout != "" {
+		c.unmap("AppStdout = %s（日志改为写入包装器日志目录）", stdout)
+	}
+	if hasStderr && stderr != "" {
+		c.unmap("AppStderr = %s（日志改为写入包装器日志目录）", stderr)
+		if !strings.EqualFold(stderr, stdout) {
+			c.config.SeparateStderr = true
+		}
+	}
+
+	if timestamp, ok := c.num("AppTimestampLog"); ok && timestamp != 0 {
+		c.config.TimestampLogs = true
+	}
+
+	rotateFiles, _ := c.num("AppRotateFiles")
+	rotateOnline, hasOnline := c.num("AppRotateOnline")
+	rotateSeconds, _ := c.num("AppRotateSeconds")
+	rotateLow, _ := c.num("AppRotateBytes")
+	rotateHigh, _ := c.num("AppRotateBytesHigh")
+	c.handled["AppRotateDelay"] = true
+	if rotateFiles == 0 {
+		return
+	}
+
+	if rotateBytes := rotateHigh<<32 | rotateLow; rotateBytes > 0 {
+		c.config.LogRotateBytes = int64(rotateBytes)
+	}
+	switch {
+	case rotateSeconds == nssmDailyRotateSeconds:
+		c.config.LogRotateDaily = true
+		c.warn("AppRotateSeconds = %d 改为跨天轮转", rotateSeconds)
+	case rotateSeconds > 0:
+		c.unmap("AppRotateSeconds = %d（只支持按大小或跨天轮转）", rotateSeconds)
+	}
+	if !hasOnline || rotateOnline == 0 {
+		c.warn("NSSM 只在服务启动时轮转日志，导入后运行期间也会轮转")
+	}
+}
+
+// convertExitActions 转换 AppExit 退出动作。NSSM 默认动作为 Restart，且不限制重启次数，导入后同样不限制。
+func (c *nssmConversion) convertExitActions() {
+	defaultAction := "Restart"
+	if action, ok := c.params.exit[""]; ok && action != "" {
+		defaultAction = action
+	}
+
+	switch strings.ToLower(defaultAction) {
+	case "restart":
+		c.config.RestartPolicy = string(RestartAlways)
+		c.config.MaxRestarts = unlimitedRestarts
+	case "exit":
+		c.config.RestartPolicy = string(RestartNever)
+	default:
+		if action, ok := c.exitAction("默认", defaultAction); ok {
+			c.config.ExitActions = append(c.config.ExitActions, exitActionDefaultKey+"="+action)
+		}
+	}
+
+	codes := make([]string, 0, len(c.params.exit))
+	for code := range c.params.exit {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		a, _ := strconv.ParseInt(codes[i], 0, 64)
+		b, _ := strconv.ParseInt(codes[j], 0, 64)
+		return a < b
+	})
+	for _, code := range codes {
+		if action, ok := c.exitAction("退出码 "+code, c.params.exit[code]); ok {
+			c.config.ExitActions = append(c.config.ExitActions, code+"="+action)
+		}
+	}
+}
+
+// exitAction 将 NSSM 退出动作转换为退出动作表中的动作
+func (c *nssmConversion) exitAction(label, action string) (string, bool) {
+	switch strings.ToLower(action) {
+	case "restart":
+		return string(ExitActionRestart), true
+	case "ignore":
+		return string(ExitActionIgnore), true
+	case "exit":
+		return string(ExitActionExit), true
+	case "suicide":
+		c.warn("%s 的 Suicide 动作改为 exit，需要启用出错停止时的恢复动作才会触发SCM故障恢复", label)
+		return string(ExitActionExit), true
+	default:
+		c.unmap(`%s\%s = %s`, nssmExitKey, label, action)
+		return "", false
+	}
+}
+
+// convertStopMethods 转换停止方式参数，跳过标志的低两位与 NSSM 一致
+func (c *nssmConversion) convertStopMethods() {
+	if skip, ok := c.num("AppStopMethodSkip"); ok {
+		c.config.StopMethodSkip = int(skip) & (StopMethodSkipConsole | StopMethodSkipWindow)
+		if skip&8 != 0 {
+			c.unmap("AppStopMethodSkip 终止进程标志（停止超时后总会结束目标程序）")
+		}
+	}
+	if timeout, ok := c.num("AppStopMethodConsole"); ok {
+		c.config.StopConsoleTimeoutMs = int(timeout)
+	}
+	if timeout, ok := c.num("AppStopMethodWindow"); ok {
+		c.config.StopWindowTimeoutMs = int(timeout)
+	}
+}
+
+// describe 返回参数值的文本形式，用于导入报告
+func (params *nssmParameters) describe(name string) string {
+	if value, ok := params.strings[name]; ok {
+		return value
+	}
+	if value, ok := params.multi[name]; ok {
+		return strings.Join(value, "; ")
+	}
+	return strconv.FormatUint(params.ints[name], 10)
+}
+
+// expandRegistryString 展开 REG_EXPAND_SZ 中的环境变量引用，未定义的变量保留原样
+func expandRegistryString(value string) string {
+	return expandEnvReferences(value, os.LookupEnv)
+}
+
+// ImportNssmServices 导入以 NSSM 运行的服务：转换 Parameters 中的 NSSM 参数，
+// 并将 ImagePath 改为内置包装器模式。serviceNames 为空时导入全部 NSSM 服务；
+// dryRun 为 true 时只生成报告，不修改任何配置。
+func (wsm *WindowsServiceManager) ImportNssmServices(serviceNames []string, dryRun bool) (*NssmImportReport, error) {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	report := &NssmImportReport{DryRun: dryRun, Services: []NssmImportItem{}}
+
+	selected := make(map[string]bool)
+	for _, name := range serviceNames {
+		selected[strings.ToLower(name)] = true
+	}
+
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		names, err := scm.ListServices()
+		if err != nil {
+			return fmt.Errorf("枚举服务失败: %v", err)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if len(selected) > 0 && !selected[strings.ToLower(name)] {
+				continue
+			}
+			if item, ok := wsm.importNssmService(scm, name, dryRun); ok {
+				report.Services = append(report.Services, item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		wsm.emitServicesUpdated()
+	}
+	return report, nil
+}
+
+// importNssmService 转换并导入单个服务，服务不是 NSSM 服务时返回 false
+func (wsm *WindowsServiceManager) importNssmService(scm ServiceControlBackend, name string, dryRun bool) (NssmImportItem, bool) {
+	windowsService, err := scm.OpenService(name)
+	if err != nil {
+		return NssmImportItem{}, false
+	}
+	defer windowsService.Close()
+
+	scmConfig, err := windowsService.Config()
+	if err != nil || !isNssmImagePath(scmConfig.BinaryPathName) {
+		return NssmImportItem{}, false
+	}
+
+	item := NssmImportItem{
+		ServiceName: name,
+		DisplayName: scmConfig.DisplayName,
+		ImagePath:   scmConfig.BinaryPathName,
+		Unmapped:    []string{},
+		Warnings:    []string{},
+	}
+
+	params, err := wsm.readNssmParameters(name)
+	if err != nil {
+		item.Error = err.Error()
+		return item, true
+	}
+
+	config, unmapped, warnings := convertNssmParameters(params)
+	config.Name = scmConfig.DisplayName
+	config.Description = scmConfig.Description
+	config.StartType = serviceStartTypeFromConfig(scmConfig)
+	config.Account = scmConfig.ServiceStartName
+	item.Config = &config
+	item.Unmapped = append(item.Unmapped, unmapped...)
+	item.Warnings = append(item.Warnings, warnings...)
+
+	if err := normalizeServiceConfig(&config); err != nil {
+		item.Error = err.Error()
+		return item, true
+	}
+
+	if status, err := windowsService.Query(); err == nil && status.State != StateStopped {
+		item.Warnings = append(item.Warnings, "服务正在运行，重新启动后改由包装器运行")
+	}
+
+	if dryRun {
+		return item, true
+	}
+
+	if err := wsm.adoptNssmService(scm, windowsService, name, scmConfig, config); err != nil {
+		item.Error = err.Error()
+		return item, true
+	}
+	item.Imported = true
+
+	if service, ok := wsm.discoverWrapperService(scm, name); ok {
+		wsm.services[service.ID] = service
+	}
+
+	return item, true
+}
+
+// adoptNssmService 写入转换后的配置并将 ImagePath 改为包装器模式。
+// NSSM 的参数保留在 Parameters 中，恢复 NssmImagePath 即可回退到 NSSM。
+func (wsm *WindowsServiceManager) adoptNssmService(scm ServiceControlBackend, windowsService ServiceHandle, serviceName string, scmConfig SCMServiceConfig, config ServiceConfig) error {
+	if err := wsm.setServiceParameter(serviceName, parameterNssmImagePath, StringParameter(scmConfig.BinaryPathName)); err != nil {
+		return fmt.Errorf("保存原ImagePath失败: %v", err)
+	}
+
+	wrapperPath, err := wrapperCommandLine(serviceName)
+	if err != nil {
+		return fmt.Errorf("创建服务包装器失败: %v", err)
+	}
+	if err := wsm.storeServiceConfigInRegistry(serviceName, config); err != nil {
+		return fmt.Errorf("存储服务配置失败: %v", err)
+	}
+
+	// 只修改 ImagePath，保留账户和依赖
+	scmConfig.BinaryPathName = wrapperPath
+	scmConfig.ServiceStartName = ""
+	scmConfig.Dependencies = nil
+	if err := windowsService.UpdateConfig(scmConfig); err != nil {
+		return fmt.Errorf("设置服务路径失败: %v", err)
+	}
+
+	if err := scm.RegisterEventSource(serviceName); err != nil {
+		wsm.warning(serviceName, EventConfigError, "%v", err)
+	}
+
+	createdAt := QWordParameter(uint64(time.Now().Unix()))
+	if err := wsm.setServiceParameter(serviceName, parameterCreatedAt, createdAt); err != nil {
+		wsm.warning(serviceName, EventConfigError, "记录创建时间失败: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setParameters 写入服务参数，用于准备测试数据
+func setParameters(t *testing.T, provider ServiceParameterProvider, serviceName string, values map[string]ParameterValue) {
+	t.Helper()
+
+	store, err := provider.Open(serviceName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	for name, value := range values {
+		if err := store.Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestImportNssmServices(t *testing.T) {
+	wsm, scm, parameters := newTestManager(t)
+
+	exePath := testServiceConfig(t, "legacy").ExePath
+	nssmImagePath := `C:\tools\nssm.exe`
+	if _, err := scm.CreateService("legacy", SCMServiceConfig{BinaryPathName: nssmImagePath, DisplayName: "Legacy"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scm.CreateService("plain", SCMServiceConfig{BinaryPathName: `C:\plain.exe`}); err != nil {
+		t.Fatal(err)
+	}
+	setParameters(t, parameters, "legacy", map[string]ParameterValue{
+		"Application":   ExpandStringParameter(exePath),
+		"AppParameters": StringParameter(`--port 8080`),
+		"AppNoConsole":  DWordParameter(1),
+	})
+	setParameters(t, parameters, `legacy\AppExit`, map[string]ParameterValue{
+		"":  StringParameter("Exit"),
+		"3": StringParameter("Restart"),
+	})
+	setParameters(t, parameters, `legacy\Custom`, nil)
+
+	report, err := wsm.ImportNssmServices(nil, true)
+	if err != nil {
+		t.Fatalf("ImportNssmServices: %v", err)
+	}
+	if len(report.Services) != 1 {
+		t.Fatalf("报告应只包含 NSSM 服务: %+v", report.Services)
+	}
+	item := report.Services[0]
+	if item.Error != "" || item.Imported {
+		t.Fatalf("试运行结果 = %+v", item)
+	}
+	if item.Config.ExePath != exePath || item.Config.Args != "--port 8080" ||
+		item.Config.RestartPolicy != string(RestartNever) ||
+		!reflect.DeepEqual(item.Config.ExitActions, []string{"3=restart"}) {
+		t.Fatalf("转换后的配置 = %+v", item.Config)
+	}
+	if len(item.Unmapped) != 2 || item.Unmapped[0] != "AppNoConsole = 1" || !strings.EqualFold(item.Unmapped[1], `Custom\`) {
+		t.Fatalf("未转换的参数 = %q", item.Unmapped)
+	}
+	if got := scmConfigOf(t, scm, "legacy").BinaryPathName; got != nssmImagePath {
+		t.Fatalf("试运行不应修改 ImagePath: %s", got)
+	}
+
+	report, err = wsm.ImportNssmServices([]string{"LEGACY"}, false)
+	if err != nil {
+		t.Fatalf("ImportNssmServices: %v", err)
+	}
+	if item := report.Services[0]; !item.Imported || item.Error != "" {
+		t.Fatalf("导入结果 = %+v", item)
+	}
+
+	if name, ok := parseWrapperImagePath(scmConfigOf(t, scm, "legacy").BinaryPathName); !ok || name != "legacy" {
+		t.Fatalf("导入后 ImagePath 不是包装器模式")
+	}
+	store, _ := parameters.Open("legacy", false)
+	defer store.Close()
+	if value, _, _ := store.Get(parameterNssmImagePath); value.String != nssmImagePath {
+		t.Fatalf("NssmImagePath = %q", value.String)
+	}
+	if service := wsm.services["legacy"]; service == nil || service.ExePath != exePath {
+		t.Fatalf("导入的服务未加入列表: %+v", service)
+	}
+}
+
+// newNssmParameters 创建测试用的 NSSM 参数
+func newNssmParameters(strs map[string]string, multi map[string][]string, ints map[string]uint64, exit map[string]string) *nssmParameters {
+	params := &nssmParameters{strings: strs, multi: multi, ints: ints, exit: exit}
+	if params.strings == nil {
+		params.strings = map[string]string{}
+	}
+	if params.multi == nil {
+		params.multi = map[string][]string{}
+	}
+	if params.ints == nil {
+		params.ints = map[string]uint64{}
+	}
+	if params.exit == nil {
+		// 默认动作为 Exit，避免每个用例都带上重启配置
+		params.exit = map[string]string{"": "Exit"}
+	}
+	return params
+}
+
+func TestConvertNssmParameters(t *testing.T) {
+	t.Setenv("NSSM_TEST_ROOT", `D:\svc`)
+
+	never := string(RestartNever)
+	offlineWarning := "NSSM 只在服务启动时轮转日志，导入后运行期间也会轮转"
+
+	tests := []struct {
+		name     string
+		params   *nssmParameters
+		config   ServiceConfig
+		unmapped []string
+		warnings []string
+	}{
+		{
+			name: "application and directory",
+			params: newNssmParameters(map[string]string{
+				"Application":   `%NSSM_TEST_ROOT%\app.exe`,
+				"AppParameters": "--port 8080",
+				"AppDirectory":  `%NSSM_TEST_ROOT%\data`,
+			}, nil, nil, nil),
+			config: ServiceConfig{ExePath: `D:\svc\app.exe`, Args: "--port 8080", WorkingDir: `D:\svc\data`, RestartPolicy: never},
+		},
+		{
+			name:     "environment extra",
+			params:   newNssmParameters(nil, map[string][]string{"AppEnvironmentExtra": {"A=1", "", "B=x=y", "-C", "BAD"}}, nil, nil),
+			config:   ServiceConfig{Environment: []string{"A=1", "B=x=y"}, RestartPolicy: never},
+			unmapped: []string{"环境变量 -C", "环境变量 BAD"},
+		},
+		{
+			name: "environment replaces",
+			params: newNssmParameters(nil, map[string][]string{
+				"AppEnvironment":      {`PATH=C:\bin`},
+				"AppEnvironmentExtra": {"A=1"},
+			}, nil, nil),
+			config:   ServiceConfig{Environment: []string{`PATH=C:\bin`, "A=1"}, RestartPolicy: never},
+			warnings: []string{"AppEnvironment 在 NSSM 中替换整个环境，导入后改为在继承的环境上设置这些变量"},
+		},
+		{
+			name: "stdout and stderr same file",
+			params: newNssmParameters(map[string]string{
+				"AppStdout": `C:\logs\app.log`,
+				"AppStderr": `C:\LOGS\app.log`,
+			}, nil, map[string]uint64{"AppTimestampLog": 1}, nil),
+			config: ServiceConfig{TimestampLogs: true, RestartPolicy: never},
+			unmapped: []string{
+				`AppStdout = C:\logs\app.log（日志改为写入包装器日志目录）`,
+				`AppStderr = C:\LOGS\app.log（日志改为写入包装器日志目录）`,
+			},
+		},
+		{
+			name: "separate stderr",
+			params: newNssmParameters(map[string]string{
+				"AppStdout": `C:\logs\out.log`,
+				"AppStderr": `C:\logs\err.log`,
+			}, nil, nil, nil),
+			config: ServiceConfig{SeparateStderr: true, RestartPolicy: never},
+			unmapped: []string{
+				`AppStdout = C:\logs\out.log（日志改为写入包装器日志目录）`,
+				`AppStderr = C:\logs\err.log（日志改为写入包装器日志目录）`,
+			},
+		},
+		{
+			name:     "stderr only",
+			params:   newNssmParameters(map[string]string{"AppStdout": "", "AppStderr": `C:\logs\err.log`}, nil, nil, nil),
+			config:   ServiceConfig{SeparateStderr: true, RestartPolicy: never},
+			unmapped: []string{`AppStderr = C:\logs\err.log（日志改为写入包装器日志目录）`},
+		},
+		{
+			name: "rotate bytes 64-bit",
+			params: newNssmParameters(nil, nil, map[string]uint64{
+				"AppRotateFiles":     1,
+				"AppRotateOnline":    1,
+				"AppRotateBytes":     0x10,
+				"AppRotateBytesHigh": 2,
+				"AppRotateDelay":     100,
+			}, nil),
+			config: ServiceConfig{LogRotateBytes: 2<<32 | 0x10, RestartPolicy: never},
+		},
+		{
+			name: "rotate daily",
+			params: newNssmParameters(nil, nil, map[string]uint64{
+				"AppRotateFiles":   1,
+				"AppRotateOnline":  1,
+				"AppRotateSeconds": 86400,
+			}, nil),
+			config:   ServiceConfig{LogRotateDaily: true, RestartPolicy: never},
+			warnings: []string{"AppRotateSeconds = 86400 改为跨天轮转"},
+		},
+		{
+			name: "rotate seconds unsupported",
+			params: newNssmParameters(nil, nil, map[string]uint64{
+				"AppRotateFiles":   1,
+				"AppRotateSeconds": 3600,
+				"AppRotateBytes":   1024,
+			}, nil),
+			config:   ServiceConfig{LogRotateBytes: 1024, RestartPolicy: never},
+			unmapped: []string{"AppRotateSeconds = 3600（只支持按大小或跨天轮转）"},
+			warnings: []string{offlineWarning},
+		},
+		{
+			name: "rotate disabled",
+			params: newNssmParameters(nil, nil, map[string]uint64{
+				"AppRotateFiles":   0,
+				"AppRotateBytes":   1024,
+				"AppRotateSeconds": 86400,
+			}, nil),
+			config: ServiceConfig{RestartPolicy: never},
+		},
+		{
+			name:   "default restart",
+			params: newNssmParameters(nil, nil, nil, map[string]string{}),
+			config: ServiceConfig{RestartPolicy: string(RestartAlways), MaxRestarts: unlimitedRestarts},
+		},
+		{
+			name:     "default suicide",
+			params:   newNssmParameters(nil, nil, nil, map[string]string{"": "Suicide"}),
+			config:   ServiceConfig{ExitActions: []string{"default=exit"}},
+			warnings: []string{"默认 的 Suicide 动作改为 exit，需要启用出错停止时的恢复动作才会触发SCM故障恢复"},
+		},
+		{
+			name: "exit codes",
+			params: newNssmParameters(nil, nil, nil, map[string]string{
+				"":     "Restart",
+				"0x10": "Ignore",
+				"3":    "Suicide",
+				"5":    "Bogus",
+				"0":    "Exit",
+			}),
+			config:   ServiceConfig{RestartPolicy: string(RestartAlways), MaxRestarts: unlimitedRestarts, ExitActions: []string{"0=exit", "3=exit", "0x10=ignore"}},
+			unmapped: []string{`AppExit\退出码 5 = Bogus`},
+			warnings: []string{"退出码 3 的 Suicide 动作改为 exit，需要启用出错停止时的恢复动作才会触发SCM故障恢复"},
+		},
+		{
+			name: "restart delay and process tree",
+			params: newNssmParameters(nil, nil, map[string]uint64{
+				"AppRestartDelay":    2000,
+				"AppKillProcessTree": 0,
+			}, nil),
+			config: ServiceConfig{RestartDelayMs: 2000, DetachChildren: true, RestartPolicy: never},
+		},
+		{
+			name:     "unhandled parameters",
+			params:   &nssmParameters{strings: map[string]string{"AppPriority": "HIGH"}, multi: map[string][]string{"AppEvents": {"a", "b"}}, ints: map[string]uint64{"AppNoConsole": 1}, exit: map[string]string{"": "Exit"}, subKeys: []string{"Custom"}},
+			config:   ServiceConfig{RestartPolicy: never},
+			unmapped: []string{"AppEvents = a; b", "AppNoConsole = 1", "AppPriority = HIGH", `Custom\`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, unmapped, warnings := convertNssmParameters(tt.params)
+			if !reflect.DeepEqual(config, tt.config) {
+				t.Errorf("配置 = %+v\n期望 %+v", config, tt.config)
+			}
+			if !reflect.DeepEqual(unmapped, tt.unmapped) {
+				t.Errorf("未转换的参数 = %q\n期望 %q", unmapped, tt.unmapped)
+			}
+			if !reflect.DeepEqual(warnings, tt.warnings) {
+				t.Errorf("提示 = %q\n期望 %q", warnings, tt.warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// serviceParametersVersion Parameters 的配置格式版本。
+// 版本0为引入版本号之前的格式，布局与版本1相同，读取时无需转换。
+const serviceParametersVersion = 1
+
+// Parameters 下不属于 ServiceConfig 的参数
+const (
+	parameterSchemaVersion = "SchemaVersion" // 配置格式版本
+	parameterDisplayName   = "DisplayName"   // 包装器日志中使用的显示名称，未设置时使用服务名
+	parameterAppDirectory  = "AppDirectory"  // 工作目录，与 NSSM 兼容
+	parameterCreatedAt     = "CreatedAt"     // 服务创建时间（Unix 秒）
+	parameterNssmImagePath = "NssmImagePath" // 从 NSSM 导入前的 ImagePath
+)
+
+// serviceParameterField Parameters 中与 ServiceConfig 字段对应的参数
+type serviceParameterField struct {
+	name string
+	// encode 返回要写入的值，返回 false 时删除该参数，更新配置时不会残留旧值
+	encode func(config ServiceConfig) (ParameterValue, bool)
+	// decode 将参数值写回配置
+	decode func(value ParameterValue, config *ServiceConfig)
+}
+
+func stringField(name string, field func(*ServiceConfig) *string) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			value := *field(&config)
+			return StringParameter(value), value != ""
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isText() {
+				*field(config) = value.String
+			}
+		},
+	}
+}
+
+func stringsField(name string, field func(*ServiceConfig) *[]string) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			values := *field(&config)
+			return StringsParameter(values), len(values) > 0
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.Kind == ParameterStrings {
+				*field(config) = value.Strings
+			}
+		},
+	}
+}
+
+func intField(name string, field func(*ServiceConfig) *int) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			value := *field(&config)
+			return DWordParameter(uint32(value)), value > 0
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isInteger() {
+				*field(config) = int(value.Number)
+			}
+		},
+	}
+}
+
+// signedIntField 以 DWORD 保存有符号整数，负数按补码存储
+func signedIntField(name string, field func(*ServiceConfig) *int) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			value := *field(&config)
+			return DWordParameter(uint32(int32(value))), value != 0
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isInteger() {
+				*field(config) = int(int32(uint32(value.Number)))
+			}
+		},
+	}
+}
+
+func int64Field(name string, field func(*ServiceConfig) *int64) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			value := *field(&config)
+			return QWordParameter(uint64(value)), value > 0
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isInteger() {
+				*field(config) = int64(value.Number)
+			}
+		},
+	}
+}
+
+func boolField(name string, field func(*ServiceConfig) *bool) serviceParameterField {
+	return serviceParameterField{
+		name: name,
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			return DWordParameter(1), *field(&config)
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isInteger() {
+				*field(config) = value.Number != 0
+			}
+		},
+	}
+}
+
+// useArgList 判断是否以 ArgList 保存参数。REG_MULTI_SZ 无法保存空字符串，
+// 包含空参数时改为保存转义后的命令行
+func useArgList(config ServiceConfig) bool {
+	return len(config.ArgList) > 0 && !containsEmptyString(config.ArgList)
+}
+
+// serviceParameterSchema Parameters 中保存的服务配置（版本1）
+var serviceParameterSchema = []serviceParameterField{
+	stringField("ExePath", func(c *ServiceConfig) *string { return &c.ExePath }),
+	{
+		name: "Args",
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			return StringParameter(config.Args), config.Args != "" && !useArgList(config)
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.isText() {
+				config.Args = value.String
+			}
+		},
+	},
+	{
+		name: "ArgList",
+		encode: func(config ServiceConfig) (ParameterValue, bool) {
+			return StringsParameter(config.ArgList), useArgList(config)
+		},
+		decode: func(value ParameterValue, config *ServiceConfig) {
+			if value.Kind == ParameterStrings && len(value.Strings) > 0 {
+				config.ArgList = value.Strings
+				config.Args = JoinCommandLine(value.Strings)
+			}
+		},
+	},
+	stringField("WorkingDir", func(c *ServiceConfig) *string { return &c.WorkingDir }),
+	stringField("RestartPolicy", func(c *ServiceConfig) *string { return &c.RestartPolicy }),
+	signedIntField("MaxRestarts", func(c *ServiceConfig) *int { return &c.MaxRestarts }),
+	intField("RestartWindowSec", func(c *ServiceConfig) *int { return &c.RestartWindowSec }),
+	intField("RestartDelayMs", func(c *ServiceConfig) *int { return &c.RestartDelayMs }),
+	intField("RestartMaxDelayMs", func(c *ServiceConfig) *int { return &c.RestartMaxDelayMs }),
+	stringsField("ExitActions", func(c *ServiceConfig) *[]string { return &c.ExitActions }),
+	intField("StopMethodSkip", func(c *ServiceConfig) *int { return &c.StopMethodSkip }),
+	intField("StopConsoleTimeoutMs", func(c *ServiceConfig) *int { return &c.StopConsoleTimeoutMs }),
+	intField("StopWindowTimeoutMs", func(c *ServiceConfig) *int { return &c.StopWindowTimeoutMs }),
+	boolField("DetachChildren", func(c *ServiceConfig) *bool { return &c.DetachChildren }),
+	stringsField("Environment", func(c *ServiceConfig) *[]string { return &c.Environment }),
+	stringsField("EnvFiles", func(c *ServiceConfig) *[]string { return &c.EnvFiles }),
+	int64Field("LogRotateBytes", func(c *ServiceConfig) *int64 { return &c.LogRotateBytes }),
+	boolField("LogRotateDaily", func(c *ServiceConfig) *bool { return &c.LogRotateDaily }),
+	boolField("LogCompress", func(c *ServiceConfig) *bool { return &c.LogCompress }),
+	intField("LogMaxFiles", func(c *ServiceConfig) *int { return &c.LogMaxFiles }),
+	int64Field("LogMaxTotalBytes", func(c *ServiceConfig) *int64 { return &c.LogMaxTotalBytes }),
+	intField("LogMaxAgeDays", func(c *ServiceConfig) *int { return &c.LogMaxAgeDays }),
+	boolField("SeparateStderr", func(c *ServiceConfig) *bool { return &c.SeparateStderr }),
+	boolField("TimestampLogs", func(c *ServiceConfig) *bool { return &c.TimestampLogs }),
+}
+
+// encodeServiceParameters 将服务配置写入参数存储，未设置的值被删除
+func encodeServiceParameters(store ServiceParameterStore, config ServiceConfig) error {
+	if config.ExePath == "" {
+		return fmt.Errorf("设置ExePath失败: 可执行文件路径为空")
+	}
+
+	for _, field := range serviceParameterSchema {
+		value, present := field.encode(config)
+		var err error
+		if present {
+			err = store.Set(field.name, value)
+		} else {
+			err = store.Delete(field.name)
+		}
+		if err != nil {
+			return fmt.Errorf("设置%s失败: %v", field.name, err)
+		}
+	}
+
+	if err := store.Set(parameterSchemaVersion, DWordParameter(serviceParametersVersion)); err != nil {
+		return fmt.Errorf("设置%s失败: %v", parameterSchemaVersion, err)
+	}
+	return nil
+}
+
+// decodeServiceParameters 从参数存储读取服务配置
+func decodeServiceParameters(store ServiceParameterStore, serviceName string) (*ServiceConfig, error) {
+	version := 0
+	if value, exists, err := store.Get(parameterSchemaVersion); err != nil {
+		return nil, err
+	} else if exists && value.isInteger() {
+		version = int(value.Number)
+	}
+	if version > serviceParametersVersion {
+		return nil, fmt.Errorf("服务配置版本%d高于当前支持的版本%d", version, serviceParametersVersion)
+	}
+
+	config := &ServiceConfig{Name: serviceName}
+	if value, exists, err := store.Get(parameterDisplayName); err == nil && exists && value.isText() {
+		config.Name = value.String
+	}
+
+	for _, field := range serviceParameterSchema {
+		value, exists, err := store.Get(field.name)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			field.decode(value, config)
+		}
+	}
+
+	if config.ExePath == "" {
+		return nil, fmt.Errorf("读取ExePath失败: 未配置可执行文件路径")
+	}
+	return config, nil
+}
+
+// parameterCreatedAtValue 读取服务创建时间，未记录时使用参数存储的最后修改时间
+func parameterCreatedAtValue(store ServiceParameterStore) time.Time {
+	if value, exists, err := store.Get(parameterCreatedAt); err == nil && exists && value.isInteger() && value.Number > 0 {
+		return time.Unix(int64(value.Number), 0)
+	}
+	if modTimer, ok := store.(parameterModTimer); ok {
+		if modTime, err := modTimer.ModTime(); err == nil {
+			return modTime
+		}
+	}
+	return time.Time{}
+}
+
+// snapshotParameters 读取参数存储中的所有值，用于更新失败时回滚。
+// 类型不受支持的值以空类型记录，回滚时保持原样
+func snapshotParameters(store ServiceParameterStore) (map[string]ParameterValue, error) {
+	names, err := store.Names()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := make(map[string]ParameterValue, len(names))
+	for _, name := range names {
+		value, exists, err := store.Get(name)
+		if errors.Is(err, errUnsupportedParameter) {
+			snapshot[name] = ParameterValue{}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			snapshot[name] = value
+		}
+	}
+	return snapshot, nil
+}
+
+// restoreParameters 将参数存储恢复为快照中的内容，快照之后新增的值被删除
+func restoreParameters(store ServiceParameterStore, snapshot map[string]ParameterValue) error {
+	names, err := store.Names()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, keep := snapshot[name]; !keep {
+			if err := store.Delete(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for name, value := range snapshot {
+		if value.Kind == "" {
+			continue
+		}
+		if err := store.Set(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
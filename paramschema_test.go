@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// parameterProviders 返回需要通过相同测试的参数存储实现
+func parameterProviders(t *testing.T) map[string]ServiceParameterProvider {
+	return map[string]ServiceParameterProvider{
+		"memory": NewMemoryParameterProvider(),
+		"file":   NewFileParameterProvider(t.TempDir()),
+	}
+}
+
+func TestServiceParametersRoundTrip(t *testing.T) {
+	configs := map[string]ServiceConfig{
+		"full": {
+			Name:                 "svc",
+			ExePath:              `C:\Program Files\app\app.exe`,
+			ArgList:              []string{"--config", `C:\a b\c.toml`, `tail\`},
+			WorkingDir:           `C:\Program Files\app`,
+			RestartPolicy:        string(RestartOnFailure),
+			MaxRestarts:          5,
+			RestartWindowSec:     600,
+			RestartDelayMs:       250,
+			RestartMaxDelayMs:    30000,
+			ExitActions:          []string{"0=exit", "default=restart"},
+			StopMethodSkip:       StopMethodSkipWindow,
+			StopConsoleTimeoutMs: 2000,
+			DetachChildren:       true,
+			Environment:          []string{"A=1", "PATH+=;C:\\bin", "-TEMP"},
+			EnvFiles:             []string{".env"},
+			LogRotateBytes:       10 << 30,
+			LogRotateDaily:       true,
+			LogCompress:          true,
+			LogMaxFiles:          7,
+			LogMaxTotalBytes:     1 << 40,
+			LogMaxAgeDays:        30,
+			SeparateStderr:       true,
+			TimestampLogs:        true,
+		},
+		"minimal": {
+			Name:    "svc",
+			ExePath: `C:\app.exe`,
+		},
+		"unlimited-restarts": {
+			Name:          "svc",
+			ExePath:       `C:\app.exe`,
+			RestartPolicy: string(RestartAlways),
+			MaxRestarts:   unlimitedRestarts,
+		},
+		// REG_MULTI_SZ 无法保存空参数，改为保存命令行
+		"empty-arg": {
+			Name:    "svc",
+			ExePath: `C:\app.exe`,
+			ArgList: []string{"a", "", "b"},
+		},
+	}
+
+	for providerName, provider := range parameterProviders(t) {
+		for name, config := range configs {
+			t.Run(providerName+"/"+name, func(t *testing.T) {
+				store, err := provider.Open(name, true)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer store.Close()
+
+				want := config
+				if len(want.ArgList) > 0 {
+					want.Args = JoinCommandLine(want.ArgList)
+				}
+				if err := encodeServiceParameters(store, want); err != nil {
+					t.Fatalf("encode: %v", err)
+				}
+
+				got, err := decodeServiceParameters(store, "svc")
+				if err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				if useArgList(want) {
+					if !reflect.DeepEqual(got.ArgList, want.ArgList) {
+						t.Fatalf("ArgList = %q, 期望 %q", got.ArgList, want.ArgList)
+					}
+				} else if len(want.ArgList) > 0 {
+					if args := SplitCommandLine(got.Args); !reflect.DeepEqual(args, want.ArgList) {
+						t.Fatalf("Args = %q 无法还原 %q", got.Args, want.ArgList)
+					}
+					want.ArgList = nil
+				}
+				if !reflect.DeepEqual(*got, want) {
+					t.Fatalf("decode = %+v\n期望 %+v", *got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeServiceParametersRemovesStaleValues(t *testing.T) {
+	store, _ := NewMemoryParameterProvider().Open("svc", true)
+
+	config := ServiceConfig{ExePath: `C:\app.exe`, ArgList: []string{"x"}, LogMaxFiles: 3}
+	if err := encodeServiceParameters(store, config); err != nil {
+		t.Fatal(err)
+	}
+	config = ServiceConfig{ExePath: `C:\app.exe`, Args: "y z"}
+	if err := encodeServiceParameters(store, config); err != nil {
+		t.Fatal(err)
+	}
+
+	names, _ := store.Names()
+	if want := []string{"Args", "ExePath", parameterSchemaVersion}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("参数 = %v, 期望 %v", names, want)
+	}
+}
+
+func TestDecodeServiceParametersRejectsNewerSchema(t *testing.T) {
+	store, _ := NewMemoryParameterProvider().Open("svc", true)
+	store.Set("ExePath", StringParameter(`C:\app.exe`))
+	store.Set(parameterSchemaVersion, DWordParameter(serviceParametersVersion+1))
+
+	if _, err := decodeServiceParameters(store, "svc"); err == nil {
+		t.Fatalf("高于当前版本的配置应返回错误")
+	}
+
+	store.Delete("ExePath")
+	store.Set(parameterSchemaVersion, DWordParameter(serviceParametersVersion))
+	if _, err := decodeServiceParameters(store, "svc"); err == nil {
+		t.Fatalf("缺少ExePath时应返回错误")
+	}
+}
+
+func TestSnapshotRestoreParameters(t *testing.T) {
+	for providerName, provider := range parameterProviders(t) {
+		t.Run(providerName, func(t *testing.T) {
+			store, err := provider.Open("svc", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+
+			store.Set("ExePath", StringParameter(`C:\old.exe`))
+			store.Set("Path", ExpandStringParameter(`%SystemRoot%\bin`))
+			store.Set("Raw", ParameterValue{Kind: ParameterBinary, Binary: []byte{1, 2, 3}})
+
+			snapshot, err := snapshotParameters(store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store.Set("exepath", StringParameter(`C:\new.exe`))
+			store.Set("Added", QWordParameter(1<<40))
+			store.Delete("Path")
+
+			if err := restoreParameters(store, snapshot); err != nil {
+				t.Fatal(err)
+			}
+			restored, _ := snapshotParameters(store)
+			if !reflect.DeepEqual(restored, snapshot) {
+				t.Fatalf("恢复后 = %+v, 期望 %+v", restored, snapshot)
+			}
+		})
+	}
+}
+
+func TestParameterProviders(t *testing.T) {
+	for providerName, provider := range parameterProviders(t) {
+		t.Run(providerName, func(t *testing.T) {
+			if _, err := provider.Open("missing", false); !errors.Is(err, errParametersNotExist) {
+				t.Fatalf("打开不存在的参数: %v", err)
+			}
+
+			store, err := provider.Open("Svc", true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Set("ArgList", StringsParameter([]string{"a", ""})); err == nil {
+				t.Fatalf("多字符串包含空字符串时应返回错误")
+			}
+			if err := store.Set("MaxRestarts", ParameterValue{Kind: ParameterDWord, Number: 1 << 32}); err == nil {
+				t.Fatalf("DWORD 溢出时应返回错误")
+			}
+			store.Set("MaxRestarts", DWordParameter(3))
+			store.Close()
+
+			// 参数名和服务名不区分大小写
+			readOnly, err := provider.Open("SVC", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer readOnly.Close()
+			if value, ok, _ := readOnly.Get("maxrestarts"); !ok || !reflect.DeepEqual(value, DWordParameter(3)) {
+				t.Fatalf("Get = %+v, %v", value, ok)
+			}
+			if err := readOnly.Set("MaxRestarts", DWordParameter(4)); err == nil {
+				t.Fatalf("只读存储不应允许写入")
+			}
+		})
+	}
+}
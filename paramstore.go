@@ -0,0 +1,439 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ParameterKind 服务参数值类型，与注册表值类型一一对应
+type ParameterKind string
+
+const (
+	ParameterString       ParameterKind = "string"        // REG_SZ
+	ParameterExpandString ParameterKind = "expand-string" // REG_EXPAND_SZ，读取时不展开
+	ParameterStrings      ParameterKind = "strings"       // REG_MULTI_SZ
+	ParameterDWord        ParameterKind = "dword"         // REG_DWORD
+	ParameterQWord        ParameterKind = "qword"         // REG_QWORD
+	ParameterBinary       ParameterKind = "binary"        // REG_BINARY，只用于原样保留
+)
+
+// ParameterValue 带类型的服务参数值
+type ParameterValue struct {
+	Kind    ParameterKind `json:"kind"`
+	String  string        `json:"string,omitempty"`
+	Strings []string      `json:"strings,omitempty"`
+	Number  uint64        `json:"number,omitempty"`
+	Binary  []byte        `json:"binary,omitempty"`
+}
+
+// StringParameter 创建字符串参数值
+func StringParameter(value string) ParameterValue {
+	return ParameterValue{Kind: ParameterString, String: value}
+}
+
+// ExpandStringParameter 创建可展开字符串参数值
+func ExpandStringParameter(value string) ParameterValue {
+	return ParameterValue{Kind: ParameterExpandString, String: value}
+}
+
+// StringsParameter 创建多字符串参数值
+func StringsParameter(values []string) ParameterValue {
+	return ParameterValue{Kind: ParameterStrings, Strings: append([]string(nil), values...)}
+}
+
+// DWordParameter 创建DWORD参数值
+func DWordParameter(value uint32) ParameterValue {
+	return ParameterValue{Kind: ParameterDWord, Number: uint64(value)}
+}
+
+// QWordParameter 创建QWORD参数值
+func QWordParameter(value uint64) ParameterValue {
+	return ParameterValue{Kind: ParameterQWord, Number: value}
+}
+
+// isText 判断是否为字符串类型
+func (value ParameterValue) isText() bool {
+	return value.Kind == ParameterString || value.Kind == ParameterExpandString
+}
+
+// isInteger 判断是否为整数类型
+func (value ParameterValue) isInteger() bool {
+	return value.Kind == ParameterDWord || value.Kind == ParameterQWord
+}
+
+// validate 校验参数值，REG_MULTI_SZ 无法保存空字符串
+func (value ParameterValue) validate(name string) error {
+	switch value.Kind {
+	case ParameterString, ParameterExpandString, ParameterQWord, ParameterBinary:
+	case ParameterStrings:
+		if containsEmptyString(value.Strings) {
+			return fmt.Errorf("参数%s包含空字符串", name)
+		}
+	case ParameterDWord:
+		if value.Number > 1<<32-1 {
+			return fmt.Errorf("参数%s超出DWORD范围", name)
+		}
+	default:
+		return fmt.Errorf("参数%s的类型不受支持: %s", name, value.Kind)
+	}
+	return nil
+}
+
+// ServiceParameterStore 单个服务的参数存储，对应服务注册表键下的 Parameters 子键
+type ServiceParameterStore interface {
+	// Names 列出所有参数名
+	Names() ([]string, error)
+	// Get 读取参数，不存在时返回 false
+	Get(name string) (ParameterValue, bool, error)
+	// Set 写入参数
+	Set(name string, value ParameterValue) error
+	// Delete 删除参数，不存在时不报错
+	Delete(name string) error
+	// Close 关闭存储
+	Close() error
+}
+
+// ServiceParameterProvider 按服务名打开参数存储
+type ServiceParameterProvider interface {
+	// Open 打开服务的参数存储，writable 为 true 时不存在则创建
+	Open(serviceName string, writable bool) (ServiceParameterStore, error)
+}
+
+// parameterModTimer 能够提供最后修改时间的参数存储
+type parameterModTimer interface {
+	ModTime() (time.Time, error)
+}
+
+// parameterSubStores 支持子存储（如注册表子键）的参数存储实现此接口
+type parameterSubStores interface {
+	SubNames() ([]string, error)
+	OpenSub(name string) (ServiceParameterStore, error)
+}
+
+// errParametersNotExist 服务参数不存在
+var errParametersNotExist = errors.New("服务参数不存在")
+
+// errUnsupportedParameter 参数值类型不受支持，如注册表中的 REG_NONE
+var errUnsupportedParameter = errors.New("参数值类型不受支持")
+
+// parameterValues 内存中的参数集合，参数名不区分大小写，与注册表一致
+type parameterValues map[string]namedParameter
+
+type namedParameter struct {
+	Name  string         `json:"name"`
+	Value ParameterValue `json:"value"`
+}
+
+func (values parameterValues) names() []string {
+	names := make([]string, 0, len(values))
+	for _, entry := range values {
+		names = append(names, entry.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (values parameterValues) get(name string) (ParameterValue, bool) {
+	entry, exists := values[strings.ToLower(name)]
+	return entry.Value, exists
+}
+
+func (values parameterValues) set(name string, value ParameterValue) error {
+	if err := value.validate(name); err != nil {
+		return err
+	}
+	key := strings.ToLower(name)
+	if existing, exists := values[key]; exists {
+		name = existing.Name
+	}
+	values[key] = namedParameter{Name: name, Value: value}
+	return nil
+}
+
+// MemoryParameterProvider 内存中的参数存储，用于模拟和不依赖注册表的场景
+type MemoryParameterProvider struct {
+	mutex    sync.Mutex
+	services map[string]parameterValues // 小写服务名 → 参数
+}
+
+// NewMemoryParameterProvider 创建空的内存参数存储
+func NewMemoryParameterProvider() *MemoryParameterProvider {
+	return &MemoryParameterProvider{services: make(map[string]parameterValues)}
+}
+
+// Open 打开服务的内存参数存储
+func (p *MemoryParameterProvider) Open(serviceName string, writable bool) (ServiceParameterStore, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := strings.ToLower(serviceName)
+	if _, exists := p.services[key]; !exists {
+		if !writable {
+			return nil, errParametersNotExist
+		}
+		p.services[key] = make(parameterValues)
+	}
+	return &memoryParameterStore{provider: p, key: key, writable: writable}, nil
+}
+
+// Remove 删除服务的所有参数
+func (p *MemoryParameterProvider) Remove(serviceName string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.services, strings.ToLower(serviceName))
+}
+
+type memoryParameterStore struct {
+	provider *MemoryParameterProvider
+	key      string
+	writable bool
+}
+
+// SubNames 返回以"服务名\子存储名"保存的子存储名列表
+func (s *memoryParameterStore) SubNames() ([]string, error) {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	prefix := s.key + `\`
+	var names []string
+	for key := range s.provider.services {
+		if name := strings.TrimPrefix(key, prefix); name != key && !strings.Contains(name, `\`) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// OpenSub 以相同的读写方式打开子存储
+func (s *memoryParameterStore) OpenSub(name string) (ServiceParameterStore, error) {
+	return s.provider.Open(s.key+`\`+name, s.writable)
+}
+
+func (s *memoryParameterStore) values() (parameterValues, error) {
+	values, exists := s.provider.services[s.key]
+	if !exists {
+		return nil, errParametersNotExist
+	}
+	return values, nil
+}
+
+func (s *memoryParameterStore) Names() ([]string, error) {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	values, err := s.values()
+	if err != nil {
+		return nil, err
+	}
+	return values.names(), nil
+}
+
+func (s *memoryParameterStore) Get(name string) (ParameterValue, bool, error) {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	values, err := s.values()
+	if err != nil {
+		return ParameterValue{}, false, err
+	}
+	value, exists := values.get(name)
+	return value, exists, nil
+}
+
+func (s *memoryParameterStore) Set(name string, value ParameterValue) error {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	if !s.writable {
+		return fmt.Errorf("参数存储为只读")
+	}
+	values, err := s.values()
+	if err != nil {
+		return err
+	}
+	return values.set(name, value)
+}
+
+func (s *memoryParameterStore) Delete(name string) error {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	if !s.writable {
+		return fmt.Errorf("参数存储为只读")
+	}
+	values, err := s.values()
+	if err != nil {
+		return err
+	}
+	delete(values, strings.ToLower(name))
+	return nil
+}
+
+func (s *memoryParameterStore) Close() error {
+	return nil
+}
+
+// FileParameterProvider 以 JSON 文件保存参数，每个服务一个文件
+type FileParameterProvider struct {
+	mutex sync.Mutex
+	dir   string
+}
+
+// NewFileParameterProvider 创建保存在 dir 目录下的文件参数存储
+func NewFileParameterProvider(dir string) *FileParameterProvider {
+	return &FileParameterProvider{dir: dir}
+}
+
+// path 返回服务参数文件路径
+func (p *FileParameterProvider) path(serviceName string) string {
+	return filepath.Join(p.dir, strings.ToLower(serviceName)+".json")
+}
+
+// Open 打开服务的参数文件，writable 为 true 时不存在则创建
+func (p *FileParameterProvider) Open(serviceName string, writable bool) (ServiceParameterStore, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	path := p.path(serviceName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if !writable {
+			return nil, errParametersNotExist
+		}
+		if err := os.MkdirAll(p.dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建参数目录失败: %v", err)
+		}
+		if err := writeParameterFile(path, make(parameterValues)); err != nil {
+			return nil, err
+		}
+	}
+	return &fileParameterStore{provider: p, path: path, writable: writable}, nil
+}
+
+type fileParameterStore struct {
+	provider *FileParameterProvider
+	path     string
+	writable bool
+}
+
+// readParameterFile 读取参数文件
+func readParameterFile(path string) (parameterValues, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errParametersNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取参数文件失败: %v", err)
+	}
+
+	var entries []namedParameter
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("解析参数文件失败: %v", err)
+	}
+
+	values := make(parameterValues, len(entries))
+	for _, entry := range entries {
+		if err := values.set(entry.Name, entry.Value); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// writeParameterFile 写入参数文件，先写临时文件再替换，避免写入中断时损坏
+func writeParameterFile(path string, values parameterValues) error {
+	entries := make([]namedParameter, 0, len(values))
+	for _, name := range values.names() {
+		entries = append(entries, values[strings.ToLower(name)])
+	}
+
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("写入参数文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入参数文件失败: %v", err)
+	}
+	return nil
+}
+
+// update 读取参数文件，修改后写回
+func (s *fileParameterStore) update(modify func(parameterValues) error) error {
+	if !s.writable {
+		return fmt.Errorf("参数存储为只读")
+	}
+
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	values, err := readParameterFile(s.path)
+	if err != nil {
+		return err
+	}
+	if err := modify(values); err != nil {
+		return err
+	}
+	return writeParameterFile(s.path, values)
+}
+
+func (s *fileParameterStore) Names() ([]string, error) {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	values, err := readParameterFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	return values.names(), nil
+}
+
+func (s *fileParameterStore) Get(name string) (ParameterValue, bool, error) {
+	s.provider.mutex.Lock()
+	defer s.provider.mutex.Unlock()
+
+	values, err := readParameterFile(s.path)
+	if err != nil {
+		return ParameterValue{}, false, err
+	}
+	value, exists := values.get(name)
+	return value, exists, nil
+}
+
+func (s *fileParameterStore) Set(name string, value ParameterValue) error {
+	return s.update(func(values parameterValues) error {
+		return values.set(name, value)
+	})
+}
+
+func (s *fileParameterStore) Delete(name string) error {
+	return s.update(func(values parameterValues) error {
+		delete(values, strings.ToLower(name))
+		return nil
+	})
+}
+
+func (s *fileParameterStore) Close() error {
+	return nil
+}
+
+// ModTime 返回参数文件的最后修改时间
+func (s *fileParameterStore) ModTime() (time.Time, error) {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
@@ -0,0 +1,167 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// newProcessLauncher 根据服务配置选择进程启动方式，启动过程中的非致命错误输出到 events
+func newProcessLauncher(config ServiceConfig, events wrapperEvents) ProcessLauncher {
+	return selectProcessLauncher(config, jobObjectLauncher{events: events})
+}
+
+// jobObjectLauncher 将目标程序放入设置了 KILL_ON_JOB_CLOSE 的作业对象中，
+// 子进程默认继承作业，停止服务时可以一并结束整个进程树
+type jobObjectLauncher struct {
+	events wrapperEvents
+}
+
+// Launch 以挂起状态启动目标程序，加入新建的作业对象后再恢复运行
+func (launcher jobObjectLauncher) Launch(cmd *exec.Cmd) (ProcessTree, error) {
+	job, err := startInJob(cmd, windowsJobStartSteps{}, launcher.events)
+	if err != nil {
+		return nil, err
+	}
+	if job == 0 {
+		return &directProcessTree{cmd: cmd}, nil
+	}
+	return &jobProcessTree{cmd: cmd, job: windows.Handle(job)}, nil
+}
+
+// windowsJobStartSteps 使用Windows API实现 jobStartSteps
+type windowsJobStartSteps struct{}
+
+func (windowsJobStartSteps) CreateJob() (uintptr, error) {
+	job, err := createKillOnCloseJob()
+	return uintptr(job), err
+}
+
+func (windowsJobStartSteps) StartSuspended(cmd *exec.Cmd) error {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+	return cmd.Start()
+}
+
+func (windowsJobStartSteps) AssignToJob(job uintptr, cmd *exec.Cmd) error {
+	return assignProcessToJob(windows.Handle(job), cmd.Process.Pid)
+}
+
+func (windowsJobStartSteps) Resume(cmd *exec.Cmd) error {
+	return resumeProcessThreads(cmd.Process.Pid)
+}
+
+func (windowsJobStartSteps) Terminate(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+	cmd.Wait()
+}
+
+func (windowsJobStartSteps) CloseJob(job uintptr) {
+	windows.CloseHandle(windows.Handle(job))
+}
+
+// jobProcessTree 由作业对象跟踪的进程树
+type jobProcessTree struct {
+	cmd *exec.Cmd
+	job windows.Handle
+}
+
+func (tree *jobProcessTree) Pid() int { return tree.cmd.Process.Pid }
+
+// Terminate 终止作业对象中的所有进程
+func (tree *jobProcessTree) Terminate() error {
+	if err := windows.TerminateJobObject(tree.job, 1); err != nil {
+		return fmt.Errorf("终止作业对象失败: %v", err)
+	}
+	return nil
+}
+
+// Close 关闭作业对象句柄，残留的子进程随之结束
+func (tree *jobProcessTree) Close() error {
+	if tree.job == 0 {
+		return nil
+	}
+	err := windows.CloseHandle(tree.job)
+	tree.job = 0
+	return err
+}
+
+// createKillOnCloseJob 创建关闭句柄时结束所有进程的作业对象
+func createKillOnCloseJob() (windows.Handle, error) {
+	job, err := windows.CreateJobObject(nil, nil)
+	if err != nil {
+		return 0, fmt.Errorf("创建作业对象失败: %v", err)
+	}
+
+	info := windows.JOBOBJECT_EXTENDED_LIMIT_INFORMATION{
+		BasicLimitInformation: windows.JOBOBJECT_BASIC_LIMIT_INFORMATION{
+			LimitFlags: windows.JOB_OBJECT_LIMIT_KILL_ON_JOB_CLOSE,
+		},
+	}
+	_, err = windows.SetInformationJobObject(
+		job,
+		windows.JobObjectExtendedLimitInformation,
+		uintptr(unsafe.Pointer(&info)),
+		uint32(unsafe.Sizeof(info)),
+	)
+	if err != nil {
+		windows.CloseHandle(job)
+		return 0, fmt.Errorf("设置作业对象限制失败: %v", err)
+	}
+
+	return job, nil
+}
+
+// assignProcessToJob 将指定进程加入作业对象
+func assignProcessToJob(job windows.Handle, pid int) error {
+	process, err := windows.OpenProcess(windows.PROCESS_SET_QUOTA|windows.PROCESS_TERMINATE, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("打开目标进程失败: %v", err)
+	}
+	defer windows.CloseHandle(process)
+
+	return windows.AssignProcessToJobObject(job, process)
+}
+
+// resumeProcessThreads 恢复以 CREATE_SUSPENDED 启动的进程。exec.Cmd 不提供主线程句柄，
+// 通过线程快照找到属于该进程的线程并逐一恢复。
+func resumeProcessThreads(pid int) error {
+	snapshot, err := windows.CreateToolhelp32Snapshot(windows.TH32CS_SNAPTHREAD, 0)
+	if err != nil {
+		return fmt.Errorf("创建线程快照失败: %v", err)
+	}
+	defer windows.CloseHandle(snapshot)
+
+	entry := windows.ThreadEntry32{Size: uint32(unsafe.Sizeof(windows.ThreadEntry32{}))}
+	resumed := 0
+	for err = windows.Thread32First(snapshot, &entry); err == nil; err = windows.Thread32Next(snapshot, &entry) {
+		if entry.OwnerProcessID != uint32(pid) {
+			continue
+		}
+
+		thread, err := windows.OpenThread(windows.THREAD_SUSPEND_RESUME, false, entry.ThreadID)
+		if err != nil {
+			return fmt.Errorf("打开线程 %d 失败: %v", entry.ThreadID, err)
+		}
+		_, err = windows.ResumeThread(thread)
+		windows.CloseHandle(thread)
+		if err != nil {
+			return fmt.Errorf("恢复线程 %d 失败: %v", entry.ThreadID, err)
+		}
+		resumed++
+	}
+	if err != windows.ERROR_NO_MORE_FILES {
+		return fmt.Errorf("枚举线程失败: %v", err)
+	}
+	if resumed == 0 {
+		return fmt.Errorf("未找到进程 %d 的线程", pid)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// RecoveryActionType SCM故障恢复动作类型
+type RecoveryActionType string
+
+const (
+	RecoveryNone       RecoveryActionType = "none"        // 不执行操作
+	RecoveryRestart    RecoveryActionType = "restart"     // 重新启动服务
+	RecoveryRunCommand RecoveryActionType = "run-command" // 运行程序
+	RecoveryReboot     RecoveryActionType = "reboot"      // 重新启动计算机
+)
+
+// maxRecoveryActions 恢复动作数量上限：第一次失败、第二次失败、后续失败
+const maxRecoveryActions = 3
+
+// RecoveryAction 一次失败后执行的恢复动作
+type RecoveryAction struct {
+	Type    RecoveryActionType `json:"type"`
+	DelayMs int                `json:"delayMs"` // 执行动作前的等待时间（毫秒）
+}
+
+// RecoveryConfig SCM故障恢复配置。
+// 包装器因目标程序异常退出而以非0退出码停止时，SCM视为"出错停止"，
+// 只有启用 OnNonCrashFailures 才会执行恢复动作。
+type RecoveryConfig struct {
+	Actions            []RecoveryAction `json:"actions"`            // 依次对应第一次、第二次、后续失败，为空时清除恢复动作
+	ResetPeriodSec     int              `json:"resetPeriodSec"`     // 无失败多久后重置失败计数（秒），小于0表示从不重置
+	Command            string           `json:"command"`            // run-command 动作运行的命令行
+	RebootMessage      string           `json:"rebootMessage"`      // reboot 动作重启前广播的消息
+	OnNonCrashFailures bool             `json:"onNonCrashFailures"` // 服务出错停止时也执行恢复动作
+}
+
+// validate 校验恢复配置
+func (recovery RecoveryConfig) validate() error {
+	if len(recovery.Actions) > maxRecoveryActions {
+		return fmt.Errorf("恢复动作最多%d个", maxRecoveryActions)
+	}
+
+	for _, action := range recovery.Actions {
+		switch action.Type {
+		case "", RecoveryNone, RecoveryRestart, RecoveryRunCommand, RecoveryReboot:
+		default:
+			return fmt.Errorf("不支持的恢复动作: %s", action.Type)
+		}
+		if action.DelayMs < 0 {
+			return fmt.Errorf("恢复动作等待时间不能为负数")
+		}
+		if action.Type == RecoveryRunCommand && recovery.Command == "" {
+			return fmt.Errorf("运行程序的恢复动作需要指定命令")
+		}
+	}
+
+	return nil
+}
+
+// hasReboot 判断是否包含重启计算机的动作
+func (recovery RecoveryConfig) hasReboot() bool {
+	for _, action := range recovery.Actions {
+		if action.Type == RecoveryReboot {
+			return true
+		}
+	}
+	return false
+}
+
+// applyServiceRecovery 校验恢复配置并写入SCM
+func applyServiceRecovery(handle ServiceHandle, recovery RecoveryConfig) error {
+	if err := recovery.validate(); err != nil {
+		return err
+	}
+	return handle.SetRecoveryConfig(recovery)
+}
+
+// queryServiceRecovery 从SCM读取服务的恢复配置
+func queryServiceRecovery(handle ServiceHandle) (*RecoveryConfig, error) {
+	return handle.RecoveryConfig()
+}
+
+// SetServiceRecovery 设置服务的SCM故障恢复配置
+func (wsm *WindowsServiceManager) SetServiceRecovery(serviceID string, recovery RecoveryConfig) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkConfigurable(); err != nil {
+		return err
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		if err := applyServiceRecovery(windowsService, recovery); err != nil {
+			return err
+		}
+
+		if current, err := queryServiceRecovery(windowsService); err == nil {
+			service.Recovery = current
+		} else {
+			service.Recovery = &recovery
+		}
+		service.UpdatedAt = time.Now()
+		wsm.emitServicesUpdated()
+
+		return nil
+	})
+}
+
+// GetServiceRecovery 从SCM读取服务当前的故障恢复配置
+func (wsm *WindowsServiceManager) GetServiceRecovery(serviceID string) (*RecoveryConfig, error) {
+	wsm.mutex.RLock()
+	defer wsm.mutex.RUnlock()
+
+	if _, exists := wsm.services[serviceID]; !exists {
+		return nil, fmt.Errorf("服务不存在: %s", serviceID)
+	}
+
+	var recovery *RecoveryConfig
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		recovery, err = queryServiceRecovery(windowsService)
+		return err
+	})
+	return recovery, err
+}
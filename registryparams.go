@@ -0,0 +1,197 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// serviceParametersKeyPath 服务参数注册表键路径
+func serviceParametersKeyPath(serviceName string) string {
+	return fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\%s\Parameters`, serviceName)
+}
+
+// RegistryParameterProvider 保存在服务注册表键 Parameters 子键中的参数存储
+type RegistryParameterProvider struct{}
+
+// Open 打开服务的 Parameters 键。writable 为 true 时以读写方式打开，不存在则创建，
+// 但服务本身的注册表键必须已存在
+func (RegistryParameterProvider) Open(serviceName string, writable bool) (ServiceParameterStore, error) {
+	if !writable {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, serviceParametersKeyPath(serviceName), registry.READ)
+		if err == registry.ErrNotExist {
+			return nil, errParametersNotExist
+		}
+		if err != nil {
+			return nil, fmt.Errorf("打开服务配置注册表失败: %v", err)
+		}
+		return &registryParameterStore{key: key}, nil
+	}
+
+	serviceKey, err := registry.OpenKey(registry.LOCAL_MACHINE,
+		fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\%s`, serviceName), registry.CREATE_SUB_KEY)
+	if err != nil {
+		return nil, fmt.Errorf("打开服务注册表键失败: %v", err)
+	}
+	defer serviceKey.Close()
+
+	key, _, err := registry.CreateKey(serviceKey, "Parameters", registry.READ|registry.SET_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("打开服务配置注册表失败: %v", err)
+	}
+	return &registryParameterStore{key: key, writable: true}, nil
+}
+
+// externalServicesKeyPath 保存已纳管外部服务的注册表键，值名为服务名，DWORD 值为1表示只读
+const externalServicesKeyPath = `SOFTWARE\WindowsServiceManager\ExternalServices`
+
+// openExternalServicesStore 打开保存已纳管外部服务的注册表键，writable 为 true 时不存在则创建
+func openExternalServicesStore(writable bool) (ServiceParameterStore, error) {
+	if !writable {
+		key, err := registry.OpenKey(registry.LOCAL_MACHINE, externalServicesKeyPath, registry.READ)
+		if err == registry.ErrNotExist {
+			return nil, errParametersNotExist
+		}
+		if err != nil {
+			return nil, fmt.Errorf("打开外部服务注册表失败: %v", err)
+		}
+		return &registryParameterStore{key: key}, nil
+	}
+
+	key, _, err := registry.CreateKey(registry.LOCAL_MACHINE, externalServicesKeyPath, registry.READ|registry.SET_VALUE)
+	if err != nil {
+		return nil, fmt.Errorf("创建外部服务注册表失败: %v", err)
+	}
+	return &registryParameterStore{key: key, writable: true}, nil
+}
+
+type registryParameterStore struct {
+	key      registry.Key
+	writable bool
+}
+
+func (s *registryParameterStore) Names() ([]string, error) {
+	names, err := s.key.ReadValueNames(0)
+	if err != nil {
+		return nil, fmt.Errorf("读取注册表值列表失败: %v", err)
+	}
+	return names, nil
+}
+
+func (s *registryParameterStore) Get(name string) (ParameterValue, bool, error) {
+	_, valueType, err := s.key.GetValue(name, nil)
+	if err == registry.ErrNotExist {
+		return ParameterValue{}, false, nil
+	}
+	if err != nil {
+		return ParameterValue{}, false, fmt.Errorf("读取注册表值%s失败: %v", name, err)
+	}
+
+	var value ParameterValue
+	switch valueType {
+	case registry.SZ:
+		value.Kind = ParameterString
+		value.String, _, err = s.key.GetStringValue(name)
+	case registry.EXPAND_SZ:
+		value.Kind = ParameterExpandString
+		value.String, _, err = s.key.GetStringValue(name)
+	case registry.MULTI_SZ:
+		value.Kind = ParameterStrings
+		value.Strings, _, err = s.key.GetStringsValue(name)
+	case registry.DWORD:
+		value.Kind = ParameterDWord
+		value.Number, _, err = s.key.GetIntegerValue(name)
+	case registry.QWORD:
+		value.Kind = ParameterQWord
+		value.Number, _, err = s.key.GetIntegerValue(name)
+	case registry.BINARY:
+		value.Kind = ParameterBinary
+		value.Binary, _, err = s.key.GetBinaryValue(name)
+	default:
+		return ParameterValue{}, false, fmt.Errorf("注册表值%s: %w", name, errUnsupportedParameter)
+	}
+	if err != nil {
+		return ParameterValue{}, false, fmt.Errorf("读取注册表值%s失败: %v", name, err)
+	}
+	return value, true, nil
+}
+
+func (s *registryParameterStore) Set(name string, value ParameterValue) error {
+	if !s.writable {
+		return fmt.Errorf("参数存储为只读")
+	}
+	if err := value.validate(name); err != nil {
+		return err
+	}
+
+	var err error
+	switch value.Kind {
+	case ParameterString:
+		err = s.key.SetStringValue(name, value.String)
+	case ParameterExpandString:
+		err = s.key.SetExpandStringValue(name, value.String)
+	case ParameterStrings:
+		err = s.key.SetStringsValue(name, value.Strings)
+	case ParameterDWord:
+		err = s.key.SetDWordValue(name, uint32(value.Number))
+	case ParameterQWord:
+		err = s.key.SetQWordValue(name, value.Number)
+	case ParameterBinary:
+		err = s.key.SetBinaryValue(name, value.Binary)
+	}
+	if err != nil {
+		return fmt.Errorf("设置注册表值%s失败: %v", name, err)
+	}
+	return nil
+}
+
+func (s *registryParameterStore) Delete(name string) error {
+	if !s.writable {
+		return fmt.Errorf("参数存储为只读")
+	}
+	if err := s.key.DeleteValue(name); err != nil && err != registry.ErrNotExist {
+		return fmt.Errorf("删除注册表值%s失败: %v", name, err)
+	}
+	return nil
+}
+
+// SubNames 返回子键名列表
+func (s *registryParameterStore) SubNames() ([]string, error) {
+	names, err := s.key.ReadSubKeyNames(0)
+	if err != nil {
+		return nil, fmt.Errorf("读取注册表子键失败: %v", err)
+	}
+	return names, nil
+}
+
+// OpenSub 以相同的读写方式打开子键
+func (s *registryParameterStore) OpenSub(name string) (ServiceParameterStore, error) {
+	access := uint32(registry.READ)
+	if s.writable {
+		access |= registry.SET_VALUE
+	}
+	key, err := registry.OpenKey(s.key, name, access)
+	if err == registry.ErrNotExist {
+		return nil, errParametersNotExist
+	}
+	if err != nil {
+		return nil, fmt.Errorf("打开注册表子键%s失败: %v", name, err)
+	}
+	return &registryParameterStore{key: key, writable: s.writable}, nil
+}
+
+func (s *registryParameterStore) Close() error {
+	return s.key.Close()
+}
+
+// ModTime 返回 Parameters 键的最后修改时间
+func (s *registryParameterStore) ModTime() (time.Time, error) {
+	info, err := s.key.Stat()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
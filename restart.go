@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// RestartPolicy 目标程序退出后的重启策略
+type RestartPolicy string
+
+const (
+	RestartNever     RestartPolicy = "never"      // 从不重启
+	RestartOnFailure RestartPolicy = "on-failure" // 仅在退出码非0时重启
+	RestartAlways    RestartPolicy = "always"     // 总是重启
+)
+
+const (
+	defaultMaxRestarts     = 5
+	unlimitedRestarts      = -1 // ServiceConfig.MaxRestarts 取此值时不限制重启次数
+	defaultRestartWindow   = 60 * time.Second
+	defaultRestartDelay    = 1 * time.Second
+	defaultRestartMaxDelay = 60 * time.Second
+	defaultRestartJitter   = 0.2
+)
+
+// RestartSettings 重启策略参数
+type RestartSettings struct {
+	Policy      RestartPolicy
+	MaxRestarts int           // 滑动窗口内允许的最大重启次数，0表示不限制
+	Window      time.Duration // 滑动窗口长度
+	BaseDelay   time.Duration // 首次重启的退避时间
+	MaxDelay    time.Duration // 退避时间上限
+	Jitter      float64       // 抖动比例，0.2 表示 ±20%
+}
+
+// RestartDecision 重启决策结果
+type RestartDecision struct {
+	Restart bool
+	Delay   time.Duration
+	Reason  string
+}
+
+// RestartTracker 根据重启策略、滑动窗口和指数退避计算是否重启，不依赖SCM
+type RestartTracker struct {
+	settings RestartSettings
+	history  []time.Time
+	random   func() float64
+}
+
+// NewRestartTracker 创建重启决策器，random 返回 [0,1) 之间的随机数，用于计算抖动
+func NewRestartTracker(settings RestartSettings, random func() float64) *RestartTracker {
+	if settings.Policy == "" {
+		settings.Policy = RestartNever
+	}
+	if settings.Window <= 0 {
+		settings.Window = defaultRestartWindow
+	}
+	if settings.BaseDelay <= 0 {
+		settings.BaseDelay = defaultRestartDelay
+	}
+	if settings.MaxDelay < settings.BaseDelay {
+		settings.MaxDelay = settings.BaseDelay
+	}
+	if settings.Jitter < 0 {
+		settings.Jitter = 0
+	}
+	if random == nil {
+		random = func() float64 { return 0.5 }
+	}
+
+	return &RestartTracker{
+		settings: settings,
+		random:   random,
+	}
+}
+
+// Decide 根据退出码决定是否重启目标程序，以及重启前需要等待的时间
+func (rt *RestartTracker) Decide(now time.Time, exitCode int) RestartDecision {
+	switch rt.settings.Policy {
+	case RestartAlways:
+	case RestartOnFailure:
+		if exitCode == 0 {
+			return RestartDecision{Reason: "目标程序正常退出"}
+		}
+	default:
+		return RestartDecision{Reason: "重启策略为不重启"}
+	}
+
+	return rt.Next(now)
+}
+
+// Next 不考虑退出码，按滑动窗口和退避规则申请一次重启
+func (rt *RestartTracker) Next(now time.Time) RestartDecision {
+	rt.prune(now)
+
+	if rt.settings.MaxRestarts > 0 && len(rt.history) >= rt.settings.MaxRestarts {
+		return RestartDecision{Reason: "已达到窗口期内最大重启次数"}
+	}
+
+	delay := rt.backoff(len(rt.history))
+	rt.history = append(rt.history, now)
+
+	return RestartDecision{Restart: true, Delay: delay, Reason: "按重启策略重启"}
+}
+
+// RecentRestarts 返回滑动窗口内的重启次数
+func (rt *RestartTracker) RecentRestarts(now time.Time) int {
+	rt.prune(now)
+	return len(rt.history)
+}
+
+// prune 移除滑动窗口之外的重启记录
+func (rt *RestartTracker) prune(now time.Time) {
+	cutoff := now.Add(-rt.settings.Window)
+	kept := rt.history[:0]
+	for _, t := range rt.history {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	rt.history = kept
+}
+
+// backoff 计算第 attempt 次重启的退避时间（指数增长 + 抖动）
+func (rt *RestartTracker) backoff(attempt int) time.Duration {
+	delay := float64(rt.settings.BaseDelay) * math.Pow(2, float64(attempt))
+	if delay > float64(rt.settings.MaxDelay) {
+		delay = float64(rt.settings.MaxDelay)
+	}
+
+	if rt.settings.Jitter > 0 {
+		delay += delay * rt.settings.Jitter * (2*rt.random() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+
+	return time.Duration(delay)
+}
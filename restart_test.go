@@ -0,0 +1,150 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestartTrackerPolicy(t *testing.T) {
+	now := newFakeClock().Now()
+
+	tests := []struct {
+		policy   RestartPolicy
+		exitCode int
+		restart  bool
+	}{
+		{"", 1, false},
+		{RestartNever, 1, false},
+		{RestartOnFailure, 0, false},
+		{RestartOnFailure, 3, true},
+		{RestartAlways, 0, true},
+		{RestartAlways, -1, true},
+	}
+
+	for _, tt := range tests {
+		tracker := NewRestartTracker(RestartSettings{Policy: tt.policy}, nil)
+		decision := tracker.Decide(now, tt.exitCode)
+		if decision.Restart != tt.restart || decision.Reason == "" {
+			t.Errorf("Decide(%q, %d) = %+v, 期望 Restart=%v", tt.policy, tt.exitCode, decision, tt.restart)
+		}
+	}
+}
+
+func TestRestartTrackerBackoff(t *testing.T) {
+	clock := newFakeClock()
+	settings := RestartSettings{Policy: RestartAlways, Window: time.Hour, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tracker := NewRestartTracker(settings, nil)
+
+	// 指数增长，达到上限后保持不变
+	for i, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		decision := tracker.Decide(clock.Now(), 1)
+		if !decision.Restart || decision.Delay != want*time.Second {
+			t.Fatalf("第 %d 次重启 = %+v, 期望延迟 %s", i+1, decision, want*time.Second)
+		}
+		clock.Advance(decision.Delay)
+	}
+}
+
+func TestRestartTrackerJitter(t *testing.T) {
+	settings := RestartSettings{Policy: RestartAlways, BaseDelay: 10 * time.Second, Jitter: 0.2}
+	now := newFakeClock().Now()
+
+	tests := []struct {
+		random float64
+		delay  time.Duration
+	}{
+		{0, 8 * time.Second},
+		{0.5, 10 * time.Second},
+		{0.75, 11 * time.Second},
+	}
+	for _, tt := range tests {
+		tracker := NewRestartTracker(settings, func() float64 { return tt.random })
+		if decision := tracker.Next(now); decision.Delay != tt.delay {
+			t.Errorf("random = %v 时延迟 = %s, 期望 %s", tt.random, decision.Delay, tt.delay)
+		}
+	}
+
+	// 抖动比例为负数时视为不抖动
+	settings.Jitter = -1
+	tracker := NewRestartTracker(settings, func() float64 { return 0 })
+	if decision := tracker.Next(now); decision.Delay != 10*time.Second {
+		t.Fatalf("延迟 = %s", decision.Delay)
+	}
+}
+
+func TestRestartTrackerWindow(t *testing.T) {
+	clock := newFakeClock()
+	settings := RestartSettings{Policy: RestartOnFailure, MaxRestarts: 3, Window: time.Minute, BaseDelay: time.Second, MaxDelay: time.Minute}
+	tracker := NewRestartTracker(settings, nil)
+
+	for i := 0; i < 3; i++ {
+		if decision := tracker.Decide(clock.Now(), 1); !decision.Restart {
+			t.Fatalf("第 %d 次重启被拒绝: %+v", i+1, decision)
+		}
+		clock.Advance(10 * time.Second)
+	}
+
+	// 窗口内已重启3次，拒绝的申请不计入历史
+	if decision := tracker.Decide(clock.Now(), 1); decision.Restart {
+		t.Fatalf("超过最大重启次数时应拒绝: %+v", decision)
+	}
+	if got := tracker.RecentRestarts(clock.Now()); got != 3 {
+		t.Fatalf("RecentRestarts = %d", got)
+	}
+
+	// 第一次重启移出窗口后可以再次重启，退避按窗口内的次数计算
+	clock.Advance(31 * time.Second)
+	if got := tracker.RecentRestarts(clock.Now()); got != 2 {
+		t.Fatalf("RecentRestarts = %d", got)
+	}
+	decision := tracker.Decide(clock.Now(), 1)
+	if !decision.Restart || decision.Delay != 4*time.Second {
+		t.Fatalf("窗口滑动后 = %+v", decision)
+	}
+
+	// 长时间稳定运行后历史清空，退避重新从基础延迟开始
+	clock.Advance(time.Hour)
+	if decision := tracker.Decide(clock.Now(), 1); !decision.Restart || decision.Delay != time.Second {
+		t.Fatalf("历史清空后 = %+v", decision)
+	}
+}
+
+func TestRestartTrackerUnlimited(t *testing.T) {
+	clock := newFakeClock()
+	tracker := NewRestartTracker(RestartSettings{Policy: RestartAlways, MaxDelay: time.Second}, nil)
+
+	for i := 0; i < 100; i++ {
+		if decision := tracker.Next(clock.Now()); !decision.Restart || decision.Delay != defaultRestartDelay {
+			t.Fatalf("第 %d 次重启 = %+v", i+1, decision)
+		}
+	}
+	if got := tracker.RecentRestarts(clock.Now()); got != 100 {
+		t.Fatalf("RecentRestarts = %d", got)
+	}
+	clock.Advance(defaultRestartWindow)
+	if got := tracker.RecentRestarts(clock.Now()); got != 0 {
+		t.Fatalf("默认窗口过后 RecentRestarts = %d", got)
+	}
+}
+
+func TestServiceConfigRestartSettings(t *testing.T) {
+	settings := ServiceConfig{RestartPolicy: "bogus"}.restartSettings()
+	if settings.Policy != RestartNever || settings.MaxRestarts != defaultMaxRestarts || settings.Window != defaultRestartWindow {
+		t.Fatalf("默认重启参数 = %+v", settings)
+	}
+
+	config := ServiceConfig{RestartPolicy: string(RestartAlways), MaxRestarts: 2, RestartWindowSec: 30, RestartDelayMs: 250, RestartMaxDelayMs: 1000}
+	settings = config.restartSettings()
+	if settings.Policy != RestartAlways || settings.MaxRestarts != 2 || settings.Window != 30*time.Second ||
+		settings.BaseDelay != 250*time.Millisecond || settings.MaxDelay != time.Second {
+		t.Fatalf("重启参数 = %+v", settings)
+	}
+
+	settings = ServiceConfig{RestartPolicy: string(RestartAlways), MaxRestarts: unlimitedRestarts}.restartSettings()
+	if settings.MaxRestarts != 0 {
+		t.Fatalf("MaxRestarts 为 -1 时应不限制重启次数: %+v", settings)
+	}
+	if settings = (ServiceConfig{MaxRestarts: -2}).restartSettings(); settings.MaxRestarts != defaultMaxRestarts {
+		t.Fatalf("其他负数应使用默认值: %+v", settings)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logTimestampLayout 日志文件名中的时间戳格式
+const logTimestampLayout = "20060102_150405"
+
+// serviceLogDir 返回服务日志目录
+func serviceLogDir() string {
+	return filepath.Join(os.Getenv("ProgramData"), "windows_service_logs")
+}
+
+// logFile 日志轮转使用的文件句柄
+type logFile interface {
+	io.ReadWriteCloser
+	Sync() error
+}
+
+// logFileSystem 日志轮转使用的文件系统操作，测试时可替换为内存实现
+type logFileSystem interface {
+	OpenFile(name string, flag int, perm os.FileMode) (logFile, error)
+	Rename(oldpath, newpath string) error
+	Remove(name string) error
+	Stat(name string) (os.FileInfo, error)
+	Glob(pattern string) ([]string, error)
+	MkdirAll(path string, perm os.FileMode) error
+}
+
+// osLogFileSystem 基于 os 包的文件系统实现
+type osLogFileSystem struct{}
+
+func (osLogFileSystem) OpenFile(name string, flag int, perm os.FileMode) (logFile, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osLogFileSystem) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+func (osLogFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (osLogFileSystem) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (osLogFileSystem) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+
+func (osLogFileSystem) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+// RotateOptions 日志轮转参数
+type RotateOptions struct {
+	MaxBytes int64  // 单个文件的最大字节数，0 表示不按大小轮转
+	Daily    bool   // 跨天时轮转
+	Compress bool   // 使用 gzip 压缩已轮转的文件
+	OnRotate func() // 轮转完成后调用，用于清理历史文件；启用压缩时在压缩结束后调用，调用时不持有写入器的锁
+
+	// OnError 报告不影响写入的错误，如关闭或压缩旧文件失败；可能在后台协程中调用
+	OnError func(err error)
+}
+
+// RotatingWriter 按大小和日期轮转的日志写入器。
+// 文件名格式为 <prefix>_<时间戳><suffix>，与未轮转时每次启动生成的日志文件名保持一致。
+type RotatingWriter struct {
+	mutex    sync.Mutex
+	dir      string
+	prefix   string
+	suffix   string
+	options  RotateOptions
+	fs       logFileSystem
+	now      func() time.Time
+	file     logFile
+	path     string
+	size     int64
+	openedAt time.Time
+
+	compressing sync.WaitGroup // 后台压缩任务
+}
+
+// NewRotatingWriter 创建日志轮转写入器，fs 和 now 为 nil 时使用操作系统文件系统和系统时钟
+func NewRotatingWriter(dir, prefix, suffix string, options RotateOptions, fs logFileSystem, now func() time.Time) *RotatingWriter {
+	if fs == nil {
+		fs = osLogFileSystem{}
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	return &RotatingWriter{
+		dir:     dir,
+		prefix:  prefix,
+		suffix:  suffix,
+		options: options,
+		fs:      fs,
+		now:     now,
+	}
+}
+
+// Open 立即创建当前日志文件
+func (rw *RotatingWriter) Open() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.file != nil {
+		return nil
+	}
+	return rw.openNew()
+}
+
+// Path 返回当前日志文件路径
+func (rw *RotatingWriter) Path() string {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	return rw.path
+}
+
+// Write 写入日志，必要时先轮转文件
+func (rw *RotatingWriter) Write(p []byte) (int, error) {
+	n, rotated, err := rw.write(p)
+	// 启用压缩时由后台压缩任务在压缩结束后调用，避免清理时删除正在压缩的文件
+	if rotated && !rw.options.Compress {
+		rw.notifyRotate()
+	}
+	return n, err
+}
+
+func (rw *RotatingWriter) write(p []byte) (int, bool, error) {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	rotated := false
+	if rw.file == nil {
+		if err := rw.openNew(); err != nil {
+			return 0, false, err
+		}
+	} else if rw.shouldRotate(int64(len(p))) {
+		if err := rw.rotate(); err != nil {
+			return 0, false, err
+		}
+		rotated = true
+	}
+
+	n, err := rw.file.Write(p)
+	rw.size += int64(n)
+	return n, rotated, err
+}
+
+// Sync 将当前文件刷新到磁盘
+func (rw *RotatingWriter) Sync() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.file == nil {
+		return nil
+	}
+	return rw.file.Sync()
+}
+
+// Close 关闭当前日志文件，并等待后台压缩完成
+func (rw *RotatingWriter) Close() error {
+	err := rw.close()
+	rw.compressing.Wait()
+	return err
+}
+
+func (rw *RotatingWriter) close() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	if rw.file == nil {
+		return nil
+	}
+	err := rw.file.Close()
+	rw.file = nil
+	return err
+}
+
+// shouldRotate 判断写入 n 字节前是否需要轮转
+func (rw *RotatingWriter) shouldRotate(n int64) bool {
+	if rw.options.MaxBytes > 0 && rw.size > 0 && rw.size+n > rw.options.MaxBytes {
+		return true
+	}
+	if rw.options.Daily {
+		now := rw.now()
+		y1, m1, d1 := rw.openedAt.Date()
+		y2, m2, d2 := now.Date()
+		return y1 != y2 || m1 != m2 || d1 != d2
+	}
+	return false
+}
+
+// rotate 关闭当前文件并打开新文件，需要压缩时在后台压缩旧文件，不阻塞写入
+func (rw *RotatingWriter) rotate() error {
+	previous := rw.path
+	if err := rw.file.Close(); err != nil {
+		rw.reportError(fmt.Errorf("关闭日志文件失败: %v", err))
+	}
+	rw.file = nil
+
+	if rw.options.Compress {
+		rw.compressing.Add(1)
+		go func() {
+			defer rw.compressing.Done()
+			if err := compressLogFile(rw.fs, previous); err != nil {
+				rw.reportError(fmt.Errorf("压缩日志文件失败: %v", err))
+			}
+			rw.notifyRotate()
+		}()
+	}
+
+	return rw.openNew()
+}
+
+// notifyRotate 调用 OnRotate，未设置时忽略
+func (rw *RotatingWriter) notifyRotate() {
+	if rw.options.OnRotate != nil {
+		rw.options.OnRotate()
+	}
+}
+
+// reportError 通过 OnError 报告错误，未设置时忽略
+func (rw *RotatingWriter) reportError(err error) {
+	if rw.options.OnError != nil {
+		rw.options.OnError(err)
+	}
+}
+
+// openNew 以当前时间创建新的日志文件，同一秒内多次轮转时追加序号
+func (rw *RotatingWriter) openNew() error {
+	if err := rw.fs.MkdirAll(rw.dir, 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %v", err)
+	}
+
+	now := rw.now()
+	base := fmt.Sprintf("%s_%s", rw.prefix, now.Format(logTimestampLayout))
+	path := filepath.Join(rw.dir, base+rw.suffix)
+	for i := 1; rw.exists(path) || rw.exists(path+".gz"); i++ {
+		path = filepath.Join(rw.dir, fmt.Sprintf("%s_%d%s", base, i, rw.suffix))
+	}
+
+	file, err := rw.fs.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志文件失败: %v", err)
+	}
+
+	rw.file = file
+	rw.path = path
+	rw.size = 0
+	rw.openedAt = now
+	return nil
+}
+
+// exists 判断文件是否存在
+func (rw *RotatingWriter) exists(path string) bool {
+	_, err := rw.fs.Stat(path)
+	return err == nil
+}
+
+// parseLogFileName 检查文件名是否为 <prefix>_<时间戳>[_序号]<suffix>[.gz]，
+// 返回文件名中的时间戳和同一秒内轮转的序号，没有序号时为0
+func parseLogFileName(name, prefix, suffix string) (time.Time, int, bool) {
+	name = strings.TrimSuffix(name, ".gz")
+	if !strings.HasPrefix(name, prefix+"_") || !strings.HasSuffix(name, suffix) {
+		return time.Time{}, 0, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix+"_"), suffix)
+	if len(stamp) < len(logTimestampLayout) {
+		return time.Time{}, 0, false
+	}
+	seq := 0
+	if seqText := stamp[len(logTimestampLayout):]; seqText != "" {
+		if len(seqText) < 2 || seqText[0] != '_' || strings.Trim(seqText[1:], "0123456789") != "" {
+			return time.Time{}, 0, false
+		}
+		n, err := strconv.Atoi(seqText[1:])
+		if err != nil {
+			return time.Time{}, 0, false
+		}
+		seq = n
+	}
+
+	started, err := time.ParseInLocation(logTimestampLayout, stamp[:len(logTimestampLayout)], time.Local)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	return started, seq, true
+}
+
+// compressLogFile 将日志文件压缩为 .gz 并删除原文件。先写入临时文件再重命名，
+// 压缩过程中列出的日志文件不会包含不完整的压缩文件。
+func compressLogFile(fs logFileSystem, path string) error {
+	src, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".gz.tmp"
+	dst, err := fs.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		src.Close()
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	gz.Name = filepath.Base(path)
+	_, err = io.Copy(gz, src)
+	src.Close()
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return err
+	}
+
+	return fs.Remove(path)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memLogFileSystem 内存中的日志文件系统，用于测试日志轮转
+type memLogFileSystem struct {
+	mutex    sync.Mutex
+	files    map[string]*bytes.Buffer
+	now      func() time.Time
+	openErrs map[string]error // 打开指定文件时返回的错误
+}
+
+func newMemLogFileSystem(now func() time.Time) *memLogFileSystem {
+	return &memLogFileSystem{files: make(map[string]*bytes.Buffer), now: now, openErrs: make(map[string]error)}
+}
+
+// memLogFile 内存文件的句柄，读取从头开始，写入追加到末尾
+type memLogFile struct {
+	fs     *memLogFileSystem
+	data   *bytes.Buffer
+	offset int
+}
+
+func (f *memLogFile) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	if f.offset >= f.data.Len() {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data.Bytes()[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *memLogFile) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+
+	return f.data.Write(p)
+}
+
+func (f *memLogFile) Sync() error  { return nil }
+func (f *memLogFile) Close() error { return nil }
+
+func (fs *memLogFileSystem) OpenFile(name string, flag int, perm os.FileMode) (logFile, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := fs.openErrs[name]; err != nil {
+		return nil, err
+	}
+	data, exists := fs.files[name]
+	if !exists {
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+		}
+		data = &bytes.Buffer{}
+		fs.files[name] = data
+	} else if flag&os.O_TRUNC != 0 {
+		data.Reset()
+	}
+	return &memLogFile{fs: fs, data: data}, nil
+}
+
+func (fs *memLogFileSystem) Rename(oldpath, newpath string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, exists := fs.files[oldpath]
+	if !exists {
+		return &os.PathError{Op: "rename", Path: oldpath, Err: os.ErrNotExist}
+	}
+	delete(fs.files, oldpath)
+	fs.files[newpath] = data
+	return nil
+}
+
+func (fs *memLogFileSystem) Remove(name string) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if _, exists := fs.files[name]; !exists {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs.files, name)
+	return nil
+}
+
+func (fs *memLogFileSystem) Stat(name string) (os.FileInfo, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	data, exists := fs.files[name]
+	if !exists {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return memFileInfo{name: filepath.Base(name), size: int64(data.Len()), modTime: fs.now()}, nil
+}
+
+func (fs *memLogFileSystem) Glob(pattern string) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var matches []string
+	for name := range fs.files {
+		if matched, err := filepath.Match(pattern, name); err != nil {
+			return nil, err
+		} else if matched {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func (fs *memLogFileSystem) MkdirAll(path string, perm os.FileMode) error { return nil }
+
+// names 返回所有文件的文件名，按名称排序
+func (fs *memLogFileSystem) names() []string {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	names := make([]string, 0, len(fs.files))
+	for name := range fs.files {
+		names = append(names, filepath.Base(name))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// content 返回文件内容，.gz 文件返回解压后的内容
+func (fs *memLogFileSystem) content(t *testing.T, name string) string {
+	t.Helper()
+
+	fs.mutex.Lock()
+	data, exists := fs.files[name]
+	fs.mutex.Unlock()
+	if !exists {
+		t.Fatalf("文件不存在: %s", name)
+	}
+	if filepath.Ext(name) != ".gz" {
+		return data.String()
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data.Bytes()))
+	if err != nil {
+		t.Fatalf("解压 %s: %v", name, err)
+	}
+	plain, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("解压 %s: %v", name, err)
+	}
+	return string(plain)
+}
+
+// memFileInfo 内存文件的信息
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+func (fi memFileInfo) Name() string       { return fi.name }
+func (fi memFileInfo) Size() int64        { return fi.size }
+func (fi memFileInfo) Mode() os.FileMode  { return 0644 }
+func (fi memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi memFileInfo) IsDir() bool        { return false }
+func (fi memFileInfo) Sys() interface{}   { return nil }
+
+// writeString 写入日志并在出错时终止测试
+func writeString(t *testing.T, writer *RotatingWriter, data string) {
+	t.Helper()
+
+	if _, err := writer.Write([]byte(data)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+}
+
+func TestRotatingWriterSize(t *testing.T) {
+	clock := newFakeClock()
+	fs := newMemLogFileSystem(clock.Now)
+	rotations := 0
+	options := RotateOptions{MaxBytes: 10, OnRotate: func() { rotations++ }}
+	writer := NewRotatingWriter("logs", "svc", ".log", options, fs, clock.Now)
+
+	writeString(t, writer, "0123456789")
+	writeString(t, writer, "abc")
+	writeString(t, writer, "defg")
+	// 同一秒内再次轮转时追加序号，超过上限的单次写入不会被拆分
+	writeString(t, writer, "0123456789ABCDEF")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"svc_20240101_000000.log", "svc_20240101_000000_1.log", "svc_20240101_000000_2.log"}
+	if names := fs.names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("日志文件 = %q, 期望 %q", names, want)
+	}
+	for i, content := range []string{"0123456789", "abcdefg", "0123456789ABCDEF"} {
+		if got := fs.content(t, filepath.Join("logs", want[i])); got != content {
+			t.Errorf("%s = %q, 期望 %q", want[i], got, content)
+		}
+	}
+	if rotations != 2 {
+		t.Fatalf("OnRotate 调用了 %d 次", rotations)
+	}
+}
+
+func TestRotatingWriterDaily(t *testing.T) {
+	clock := newFakeClock()
+	clock.Advance(23 * time.Hour)
+	fs := newMemLogFileSystem(clock.Now)
+	writer := NewRotatingWriter("logs", "svc", ".err.log", RotateOptions{Daily: true}, fs, clock.Now)
+	if err := writer.Open(); err != nil {
+		t.Fatal(err)
+	}
+
+	writeString(t, writer, "day1\n")
+	clock.Advance(59 * time.Minute)
+	writeString(t, writer, "still day1\n")
+	clock.Advance(2 * time.Minute)
+	writeString(t, writer, "day2\n")
+	writer.Close()
+
+	want := []string{"svc_20240101_230000.err.log", "svc_20240102_000100.err.log"}
+	if names := fs.names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("日志文件 = %q, 期望 %q", names, want)
+	}
+	if got := fs.content(t, filepath.Join("logs", want[0])); got != "day1\nstill day1\n" {
+		t.Fatalf("第一天的日志 = %q", got)
+	}
+	if writer.Path() != filepath.Join("logs", want[1]) {
+		t.Fatalf("Path = %s", writer.Path())
+	}
+}
+
+func TestRotatingWriterCompress(t *testing.T) {
+	clock := newFakeClock()
+	fs := newMemLogFileSystem(clock.Now)
+	options := RotateOptions{MaxBytes: 8, Compress: true}
+	writer := NewRotatingWriter("logs", "svc", ".log", options, fs, clock.Now)
+
+	writeString(t, writer, "first\n")
+	clock.Advance(time.Second)
+	writeString(t, writer, "second\n")
+	clock.Advance(time.Second)
+	writeString(t, writer, "third\n")
+	// Close 等待后台压缩完成
+	writer.Close()
+
+	want := []string{"svc_20240101_000000.log.gz", "svc_20240101_000001.log.gz", "svc_20240101_000002.log"}
+	if names := fs.names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("日志文件 = %q, 期望 %q", names, want)
+	}
+	for i, content := range []string{"first\n", "second\n", "third\n"} {
+		if got := fs.content(t, filepath.Join("logs", want[i])); got != content {
+			t.Errorf("%s = %q, 期望 %q", want[i], got, content)
+		}
+	}
+
+	// 压缩后的文件名仍被识别为服务日志，且不会与新文件重名
+	files, err := listServiceLogFiles(fs, "logs", "svc")
+	if err != nil || len(files) != 3 || !files[2].Compressed || files[0].Compressed {
+		t.Fatalf("listServiceLogFiles = %+v, %v", files, err)
+	}
+}
+
+func TestRotatingWriterRotateAfterCompress(t *testing.T) {
+	clock := newFakeClock()
+	fs := newMemLogFileSystem(clock.Now)
+
+	var mutex sync.Mutex
+	var seen [][]string
+	options := RotateOptions{MaxBytes: 4, Compress: true, OnRotate: func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		seen = append(seen, fs.names())
+	}}
+	writer := NewRotatingWriter("logs", "svc", ".log", options, fs, clock.Now)
+
+	writeString(t, writer, "old\n")
+	clock.Advance(time.Second)
+	writeString(t, writer, "new\n")
+	writer.Close()
+
+	// 清理历史文件时旧文件已压缩完成，不会与压缩任务同时操作同一个文件
+	want := []string{"svc_20240101_000000.log.gz", "svc_20240101_000001.log"}
+	if len(seen) != 1 || !reflect.DeepEqual(seen[0], want) {
+		t.Fatalf("OnRotate 时的日志文件 = %q, 期望 %q", seen, want)
+	}
+}
+
+func TestRotatingWriterReportsCompressError(t *testing.T) {
+	clock := newFakeClock()
+	fs := newMemLogFileSystem(clock.Now)
+	fs.openErrs[filepath.Join("logs", "svc_20240101_000000.log.gz.tmp")] = errors.New("disk full")
+
+	var errs []error
+	var mutex sync.Mutex
+	options := RotateOptions{MaxBytes: 4, Compress: true, OnError: func(err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		errs = append(errs, err)
+	}}
+	writer := NewRotatingWriter("logs", "svc", ".log", options, fs, clock.Now)
+
+	writeString(t, writer, "old\n")
+	clock.Advance(time.Second)
+	writeString(t, writer, "new\n")
+	writer.Close()
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "disk full") {
+		t.Fatalf("OnError = %v", errs)
+	}
+	// 压缩失败时保留原文件
+	want := []string{"svc_20240101_000000.log", "svc_20240101_000001.log"}
+	if names := fs.names(); !reflect.DeepEqual(names, want) {
+		t.Fatalf("日志文件 = %q, 期望 %q", names, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import "fmt"
+
+// ServiceState 服务运行状态，取值与 Windows 的 SERVICE_* 状态相同
+type ServiceState uint32
+
+const (
+	StateStopped         ServiceState = 1
+	StateStartPending    ServiceState = 2
+	StateStopPending     ServiceState = 3
+	StateRunning         ServiceState = 4
+	StateContinuePending ServiceState = 5
+	StatePausePending    ServiceState = 6
+	StatePaused          ServiceState = 7
+)
+
+// ServiceControl 发送给服务的控制命令
+type ServiceControl uint32
+
+const (
+	ControlStop        ServiceControl = 1
+	ControlInterrogate ServiceControl = 4
+)
+
+// ServiceStatus 服务状态查询结果
+type ServiceStatus struct {
+	State                   ServiceState
+	CheckPoint              uint32
+	WaitHint                uint32
+	ProcessId               uint32
+	Win32ExitCode           uint32
+	ServiceSpecificExitCode uint32
+}
+
+// SCM启动类型，取值与 Windows 的 SERVICE_*_START 相同
+const (
+	scmStartAutomatic uint32 = 2
+	scmStartManual    uint32 = 3
+	scmStartDisabled  uint32 = 4
+)
+
+// errorServiceSpecificError 服务以特定错误码退出时 Win32ExitCode 的取值
+const errorServiceSpecificError uint32 = 1066
+
+// SCMServiceConfig 服务在SCM中的配置
+type SCMServiceConfig struct {
+	StartType        uint32   // scmStartAutomatic、scmStartManual 或 scmStartDisabled
+	DelayedAutoStart bool     // 自动启动时延迟启动
+	BinaryPathName   string   // 完整的命令行（ImagePath）
+	Dependencies     []string // 依赖的服务，服务组以 + 开头
+	ServiceStartName string   // 运行账户
+	Password         string   // 账户密码，只写
+	DisplayName      string
+	Description      string
+}
+
+// SCMError 服务控制管理器返回的错误，取值与 Windows 错误码相同
+type SCMError uint32
+
+const (
+	ErrInvalidHandle           SCMError = 6
+	ErrInvalidServiceControl   SCMError = 1052
+	ErrServiceAlreadyRunning   SCMError = 1056
+	ErrServiceDisabled         SCMError = 1058
+	ErrServiceDoesNotExist     SCMError = 1060
+	ErrServiceCannotAcceptCtrl SCMError = 1061
+	ErrServiceNotActive        SCMError = 1062
+	ErrServiceMarkedForDelete  SCMError = 1072
+	ErrServiceExists           SCMError = 1073
+)
+
+// Error 返回错误描述
+func (e SCMError) Error() string {
+	switch e {
+	case ErrInvalidHandle:
+		return "句柄无效"
+	case ErrInvalidServiceControl:
+		return "服务不支持该控制命令"
+	case ErrServiceAlreadyRunning:
+		return "服务已经在运行"
+	case ErrServiceDisabled:
+		return "服务已禁用"
+	case ErrServiceDoesNotExist:
+		return "服务不存在"
+	case ErrServiceCannotAcceptCtrl:
+		return "服务当前无法接受控制命令"
+	case ErrServiceNotActive:
+		return "服务未运行"
+	case ErrServiceMarkedForDelete:
+		return "服务已标记为删除"
+	case ErrServiceExists:
+		return "服务已存在"
+	default:
+		return fmt.Sprintf("服务控制管理器错误 %d", uint32(e))
+	}
+}
+
+// ServiceControlBackend 服务控制后端，封装与服务控制管理器的一次连接以及
+// 服务安装时需要修改的主机配置（登录权限、事件源）。
+// Windows 上由SCM实现，FakeSCM 提供内存中的模拟实现。
+type ServiceControlBackend interface {
+	// CreateService 创建服务，config.BinaryPathName 为完整的命令行
+	CreateService(name string, config SCMServiceConfig) (ServiceHandle, error)
+	// OpenService 打开已有的服务
+	OpenService(name string) (ServiceHandle, error)
+	// ListServices 列出所有服务名
+	ListServices() ([]string, error)
+	// GrantServiceLogonRight 为账户授予"作为服务登录"权限，已有该权限时不报错
+	GrantServiceLogonRight(account string) error
+	// RegisterEventSource 注册服务的事件源，已注册时不报错
+	RegisterEventSource(name string) error
+	// RemoveEventSource 删除服务的事件源
+	RemoveEventSource(name string) error
+	// Disconnect 断开连接
+	Disconnect() error
+}
+
+// ServiceHandle 已打开的服务
+type ServiceHandle interface {
+	Query() (ServiceStatus, error)
+	Start(args ...string) error
+	Control(cmd ServiceControl) (ServiceStatus, error)
+	Config() (SCMServiceConfig, error)
+	// UpdateConfig 修改服务配置：StartType 为0、BinaryPathName 或 DisplayName 为空时不修改对应字段；
+	// Dependencies 为 nil 时不修改，为空列表时清除依赖；ServiceStartName 为空时不修改账户，
+	// 账户变化或 Password 不为空时同时写入密码（内置账户使用空密码）。描述和延迟启动总会写入。
+	UpdateConfig(config SCMServiceConfig) error
+	// RecoveryConfig 读取故障恢复配置
+	RecoveryConfig() (*RecoveryConfig, error)
+	// SetRecoveryConfig 写入故障恢复配置，Actions 为空时清除恢复动作
+	SetRecoveryConfig(recovery RecoveryConfig) error
+	// ListDependentServices 列出直接或间接依赖此服务的服务
+	ListDependentServices() ([]string, error)
+	Delete() error
+	Close() error
+}
@@ -0,0 +1,399 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// windowsSCMBackend 基于 Windows 服务控制管理器的后端
+type windowsSCMBackend struct {
+	m *mgr.Mgr
+}
+
+// connectWindowsSCM 连接本机的服务控制管理器
+func connectWindowsSCM() (ServiceControlBackend, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, err
+	}
+	return &windowsSCMBackend{m: m}, nil
+}
+
+// scmError 将SCM返回的 Windows 错误码转换为 SCMError，便于与模拟后端统一判断
+func scmError(err error) error {
+	if errno, ok := err.(windows.Errno); ok {
+		switch SCMError(errno) {
+		case ErrInvalidHandle, ErrInvalidServiceControl, ErrServiceAlreadyRunning, ErrServiceDisabled,
+			ErrServiceDoesNotExist, ErrServiceCannotAcceptCtrl, ErrServiceNotActive,
+			ErrServiceMarkedForDelete, ErrServiceExists:
+			return SCMError(errno)
+		}
+	}
+	return err
+}
+
+// utf16PtrOrNil 空字符串返回 nil，表示不修改对应的配置
+func utf16PtrOrNil(value string) *uint16 {
+	if value == "" {
+		return nil
+	}
+	ptr, _ := windows.UTF16PtrFromString(value)
+	return ptr
+}
+
+// dependencyBlock 将依赖列表转换为以两个 NUL 结尾的字符串块，空列表表示清除依赖
+func dependencyBlock(dependencies []string) *uint16 {
+	block := ""
+	for _, dep := range dependencies {
+		block += dep + "\x00"
+	}
+	block += "\x00"
+	return &windows.StringToUTF16(block)[0]
+}
+
+func (backend *windowsSCMBackend) CreateService(name string, config SCMServiceConfig) (ServiceHandle, error) {
+	namePtr, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	displayName := config.DisplayName
+	if displayName == "" {
+		displayName = name
+	}
+	startType := config.StartType
+	if startType == 0 {
+		startType = scmStartManual
+	}
+
+	var dependencies *uint16
+	if len(config.Dependencies) > 0 {
+		dependencies = dependencyBlock(config.Dependencies)
+	}
+
+	h, err := windows.CreateService(backend.m.Handle, namePtr, utf16PtrOrNil(displayName),
+		windows.SERVICE_ALL_ACCESS, windows.SERVICE_WIN32_OWN_PROCESS, startType, windows.SERVICE_ERROR_NORMAL,
+		utf16PtrOrNil(config.BinaryPathName), nil, nil, dependencies,
+		utf16PtrOrNil(config.ServiceStartName), utf16PtrOrNil(config.Password))
+	if err != nil {
+		return nil, scmError(err)
+	}
+
+	handle := &windowsServiceHandle{service: &mgr.Service{Name: name, Handle: h}}
+	if err := handle.updateDescriptionAndStartup(config); err != nil {
+		handle.service.Delete()
+		handle.Close()
+		return nil, err
+	}
+	return handle, nil
+}
+
+func (backend *windowsSCMBackend) OpenService(name string) (ServiceHandle, error) {
+	service, err := backend.m.OpenService(name)
+	if err != nil {
+		return nil, scmError(err)
+	}
+	return &windowsServiceHandle{service: service}, nil
+}
+
+func (backend *windowsSCMBackend) ListServices() ([]string, error) {
+	return backend.m.ListServices()
+}
+
+func (backend *windowsSCMBackend) GrantServiceLogonRight(account string) error {
+	return grantServiceLogonRight(account)
+}
+
+func (backend *windowsSCMBackend) RegisterEventSource(name string) error {
+	return registerWrapperEventSource(name)
+}
+
+func (backend *windowsSCMBackend) RemoveEventSource(name string) error {
+	return removeWrapperEventSource(name)
+}
+
+func (backend *windowsSCMBackend) Disconnect() error {
+	return backend.m.Disconnect()
+}
+
+// windowsServiceHandle 已打开的 Windows 服务
+type windowsServiceHandle struct {
+	service *mgr.Service
+}
+
+// serviceStatusFromSCM 转换SCM返回的服务状态
+func serviceStatusFromSCM(status svc.Status) ServiceStatus {
+	return ServiceStatus{
+		State:                   ServiceState(status.State),
+		CheckPoint:              status.CheckPoint,
+		WaitHint:                status.WaitHint,
+		ProcessId:               status.ProcessId,
+		Win32ExitCode:           status.Win32ExitCode,
+		ServiceSpecificExitCode: status.ServiceSpecificExitCode,
+	}
+}
+
+func (h *windowsServiceHandle) Query() (ServiceStatus, error) {
+	status, err := h.service.Query()
+	if err != nil {
+		return ServiceStatus{}, scmError(err)
+	}
+	return serviceStatusFromSCM(status), nil
+}
+
+func (h *windowsServiceHandle) Start(args ...string) error {
+	return scmError(h.service.Start(args...))
+}
+
+func (h *windowsServiceHandle) Control(cmd ServiceControl) (ServiceStatus, error) {
+	status, err := h.service.Control(svc.Cmd(cmd))
+	return serviceStatusFromSCM(status), scmError(err)
+}
+
+func (h *windowsServiceHandle) Config() (SCMServiceConfig, error) {
+	config, err := h.service.Config()
+	if err != nil {
+		return SCMServiceConfig{}, scmError(err)
+	}
+	return SCMServiceConfig{
+		StartType:        config.StartType,
+		DelayedAutoStart: config.DelayedAutoStart,
+		BinaryPathName:   config.BinaryPathName,
+		Dependencies:     config.Dependencies,
+		ServiceStartName: config.ServiceStartName,
+		DisplayName:      config.DisplayName,
+		Description:      config.Description,
+	}, nil
+}
+
+// UpdateConfig 直接调用 ChangeServiceConfig：mgr.Service.UpdateConfig 无法清除依赖，
+// 也无法为内置账户写入空密码
+func (h *windowsServiceHandle) UpdateConfig(config SCMServiceConfig) error {
+	current, err := h.service.Config()
+	if err != nil {
+		return scmError(err)
+	}
+
+	startType := uint32(windows.SERVICE_NO_CHANGE)
+	if config.StartType != 0 {
+		startType = config.StartType
+	}
+
+	var dependencies *uint16
+	if config.Dependencies != nil {
+		dependencies = dependencyBlock(config.Dependencies)
+	}
+
+	var startName, password *uint16
+	if config.ServiceStartName != "" &&
+		(!sameServiceAccount(config.ServiceStartName, current.ServiceStartName) || config.Password != "") {
+		startName, _ = windows.UTF16PtrFromString(config.ServiceStartName)
+		password, _ = windows.UTF16PtrFromString(config.Password)
+	}
+
+	err = windows.ChangeServiceConfig(h.service.Handle,
+		windows.SERVICE_NO_CHANGE, startType, windows.SERVICE_NO_CHANGE,
+		utf16PtrOrNil(config.BinaryPathName), nil, nil, dependencies,
+		startName, password, utf16PtrOrNil(config.DisplayName))
+	if err != nil {
+		return scmError(err)
+	}
+
+	return h.updateDescriptionAndStartup(config)
+}
+
+// updateDescriptionAndStartup 写入服务描述和延迟启动设置
+func (h *windowsServiceHandle) updateDescriptionAndStartup(config SCMServiceConfig) error {
+	description := windows.SERVICE_DESCRIPTION{Description: utf16PtrOrNil(config.Description)}
+	if description.Description == nil {
+		description.Description, _ = windows.UTF16PtrFromString("")
+	}
+	err := windows.ChangeServiceConfig2(h.service.Handle, windows.SERVICE_CONFIG_DESCRIPTION,
+		(*byte)(unsafe.Pointer(&description)))
+	if err != nil {
+		return fmt.Errorf("设置服务描述失败: %v", err)
+	}
+
+	var delayed windows.SERVICE_DELAYED_AUTO_START_INFO
+	if config.DelayedAutoStart {
+		delayed.IsDelayedAutoStartUp = 1
+	}
+	err = windows.ChangeServiceConfig2(h.service.Handle, windows.SERVICE_CONFIG_DELAYED_AUTO_START_INFO,
+		(*byte)(unsafe.Pointer(&delayed)))
+	if err != nil {
+		return fmt.Errorf("设置延迟启动失败: %v", err)
+	}
+	return nil
+}
+
+func (h *windowsServiceHandle) ListDependentServices() ([]string, error) {
+	dependents, err := h.service.ListDependentServices(svc.AnyActivity)
+	return dependents, scmError(err)
+}
+
+func (h *windowsServiceHandle) Delete() error {
+	return scmError(h.service.Delete())
+}
+
+func (h *windowsServiceHandle) Close() error {
+	return h.service.Close()
+}
+
+// scmType 将恢复动作类型转换为SCM的动作类型
+func (actionType RecoveryActionType) scmType() int {
+	switch actionType {
+	case RecoveryRestart:
+		return mgr.ServiceRestart
+	case RecoveryRunCommand:
+		return mgr.RunCommand
+	case RecoveryReboot:
+		return mgr.ComputerReboot
+	default:
+		return mgr.NoAction
+	}
+}
+
+// recoveryActionTypeFromSCM 将SCM的动作类型转换为恢复动作类型
+func recoveryActionTypeFromSCM(actionType int) RecoveryActionType {
+	switch actionType {
+	case mgr.ServiceRestart:
+		return RecoveryRestart
+	case mgr.RunCommand:
+		return RecoveryRunCommand
+	case mgr.ComputerReboot:
+		return RecoveryReboot
+	default:
+		return RecoveryNone
+	}
+}
+
+// SetRecoveryConfig 将恢复配置写入SCM
+func (h *windowsServiceHandle) SetRecoveryConfig(recovery RecoveryConfig) error {
+	windowsService := h.service
+
+	// 设置重启计算机动作需要关机权限
+	if recovery.hasReboot() {
+		if err := enableShutdownPrivilege(); err != nil {
+			return err
+		}
+	}
+
+	if len(recovery.Actions) == 0 {
+		if err := windowsService.ResetRecoveryActions(); err != nil {
+			return fmt.Errorf("清除恢复动作失败: %v", err)
+		}
+	} else {
+		actions := make([]mgr.RecoveryAction, 0, len(recovery.Actions))
+		for _, action := range recovery.Actions {
+			actions = append(actions, mgr.RecoveryAction{
+				Type:  action.Type.scmType(),
+				Delay: time.Duration(action.DelayMs) * time.Millisecond,
+			})
+		}
+
+		resetPeriod := uint32(windows.INFINITE)
+		if recovery.ResetPeriodSec >= 0 {
+			resetPeriod = uint32(recovery.ResetPeriodSec)
+		}
+
+		if err := windowsService.SetRecoveryActions(actions, resetPeriod); err != nil {
+			return fmt.Errorf("设置恢复动作失败: %v", err)
+		}
+	}
+
+	if err := windowsService.SetRecoveryCommand(recovery.Command); err != nil {
+		return fmt.Errorf("设置恢复命令失败: %v", err)
+	}
+	if err := windowsService.SetRebootMessage(recovery.RebootMessage); err != nil {
+		return fmt.Errorf("设置重启消息失败: %v", err)
+	}
+	if err := windowsService.SetRecoveryActionsOnNonCrashFailures(recovery.OnNonCrashFailures); err != nil {
+		return fmt.Errorf("设置出错停止时的恢复动作失败: %v", err)
+	}
+
+	return nil
+}
+
+// RecoveryConfig 从SCM读取服务的恢复配置
+func (h *windowsServiceHandle) RecoveryConfig() (*RecoveryConfig, error) {
+	windowsService := h.service
+
+	actions, err := windowsService.RecoveryActions()
+	if err != nil {
+		return nil, fmt.Errorf("读取恢复动作失败: %v", err)
+	}
+
+	resetPeriod, err := windowsService.ResetPeriod()
+	if err != nil {
+		return nil, fmt.Errorf("读取失败计数重置时间失败: %v", err)
+	}
+
+	command, err := windowsService.RecoveryCommand()
+	if err != nil {
+		return nil, fmt.Errorf("读取恢复命令失败: %v", err)
+	}
+
+	rebootMessage, err := windowsService.RebootMessage()
+	if err != nil {
+		return nil, fmt.Errorf("读取重启消息失败: %v", err)
+	}
+
+	onNonCrashFailures, err := windowsService.RecoveryActionsOnNonCrashFailures()
+	if err != nil {
+		return nil, fmt.Errorf("读取出错停止时的恢复动作失败: %v", err)
+	}
+
+	recovery := &RecoveryConfig{
+		Actions:            make([]RecoveryAction, 0, len(actions)),
+		ResetPeriodSec:     int(resetPeriod),
+		Command:            command,
+		RebootMessage:      rebootMessage,
+		OnNonCrashFailures: onNonCrashFailures,
+	}
+	if resetPeriod == windows.INFINITE {
+		recovery.ResetPeriodSec = -1
+	}
+	for _, action := range actions {
+		recovery.Actions = append(recovery.Actions, RecoveryAction{
+			Type:    recoveryActionTypeFromSCM(action.Type),
+			DelayMs: int(action.Delay.Milliseconds()),
+		})
+	}
+
+	return recovery, nil
+}
+
+// enableShutdownPrivilege 为当前进程启用关机权限，设置重启计算机的恢复动作时需要
+func enableShutdownPrivilege() error {
+	var token windows.Token
+	err := windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_ADJUST_PRIVILEGES|windows.TOKEN_QUERY, &token)
+	if err != nil {
+		return fmt.Errorf("打开进程令牌失败: %v", err)
+	}
+	defer token.Close()
+
+	var luid windows.LUID
+	name, _ := windows.UTF16PtrFromString("SeShutdownPrivilege")
+	if err := windows.LookupPrivilegeValue(nil, name, &luid); err != nil {
+		return fmt.Errorf("查找关机权限失败: %v", err)
+	}
+
+	privileges := windows.Tokenprivileges{
+		PrivilegeCount: 1,
+		Privileges: [1]windows.LUIDAndAttributes{
+			{Luid: luid, Attributes: windows.SE_PRIVILEGE_ENABLED},
+		},
+	}
+	err = windows.AdjustTokenPrivileges(token, false, &privileges, uint32(unsafe.Sizeof(privileges)), nil, nil)
+	if err != nil {
+		return fmt.Errorf("启用关机权限失败: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import "time"
+
+// Service 表示一个后台服务
+type Service struct {
+	ID                   string           `json:"id"`
+	Name                 string           `json:"name"`
+	Kind                 ServiceKind      `json:"kind"`     // "wrapper" 或 "external"
+	ReadOnly             bool             `json:"readOnly"` // 外部服务只监控状态，不允许启动和停止
+	Description          string           `json:"description"`
+	StartType            ServiceStartType `json:"startType"`
+	Account              string           `json:"account"` // 服务运行账户，如 LocalSystem、NT SERVICE\<name>
+	ExePath              string           `json:"exePath"`
+	Args                 string           `json:"args"`
+	ArgList              []string         `json:"argList"`
+	WorkingDir           string           `json:"workingDir"`
+	RestartPolicy        string           `json:"restartPolicy"`
+	MaxRestarts          int              `json:"maxRestarts"`
+	RestartWindowSec     int              `json:"restartWindowSec"`
+	RestartDelayMs       int              `json:"restartDelayMs"`
+	RestartMaxDelayMs    int              `json:"restartMaxDelayMs"`
+	ExitActions          []string         `json:"exitActions"`
+	StopMethodSkip       int              `json:"stopMethodSkip"`
+	StopConsoleTimeoutMs int              `json:"stopConsoleTimeoutMs"`
+	StopWindowTimeoutMs  int              `json:"stopWindowTimeoutMs"`
+	DetachChildren       bool             `json:"detachChildren"`
+	Environment          []string         `json:"environment"`
+	EnvFiles             []string         `json:"envFiles"`
+	LogRotateBytes       int64            `json:"logRotateBytes"`
+	LogRotateDaily       bool             `json:"logRotateDaily"`
+	LogCompress          bool             `json:"logCompress"`
+	LogMaxFiles          int              `json:"logMaxFiles"`
+	LogMaxTotalBytes     int64            `json:"logMaxTotalBytes"`
+	LogMaxAgeDays        int              `json:"logMaxAgeDays"`
+	SeparateStderr       bool             `json:"separateStderr"`
+	TimestampLogs        bool             `json:"timestampLogs"`
+	Recovery             *RecoveryConfig  `json:"recovery"`     // SCM故障恢复配置，从SCM读取
+	Dependencies         []string         `json:"dependencies"` // 依赖的服务，服务组以 + 开头
+	Status               string           `json:"status"`       // "running", "stopped", "error"
+	PID                  int              `json:"pid"`
+	Orphaned             bool             `json:"orphaned"` // SCM中已不存在该服务，或不再以包装器模式运行
+	CreatedAt            time.Time        `json:"createdAt"`
+	UpdatedAt            time.Time        `json:"updatedAt"`
+}
+
+// 停止方式跳过标志，与 NSSM 的 AppStopMethodSkip 保持一致
+const (
+	StopMethodSkipConsole = 1 << 0 // 跳过发送控制台 Ctrl+C 事件
+	StopMethodSkipWindow  = 1 << 1 // 跳过向窗口发送 WM_CLOSE
+)
+
+// ServiceConfig 用于创建新服务的配置
+type ServiceConfig struct {
+	Name                 string           `json:"name"`
+	Description          string           `json:"description"` // 服务描述，为空时使用默认描述
+	StartType            ServiceStartType `json:"startType"`   // "automatic"（默认）、"delayed"、"manual"、"disabled"
+	Account              string           `json:"account"`     // 运行账户：LocalSystem（默认）、LocalService、NetworkService、virtual 或 DOMAIN\user
+	Password             string           `json:"password"`    // 用户账户的密码，只写入SCM，不会保存
+	ExePath              string           `json:"exePath"`
+	Args                 string           `json:"args"`
+	ArgList              []string         `json:"argList"` // 参数列表，设置后优先于 Args，以 REG_MULTI_SZ 存储
+	WorkingDir           string           `json:"workingDir"`
+	RestartPolicy        string           `json:"restartPolicy"`        // "never", "on-failure", "always"
+	MaxRestarts          int              `json:"maxRestarts"`          // 窗口期内最大重启次数，0 使用默认值，-1 表示不限制
+	RestartWindowSec     int              `json:"restartWindowSec"`     // 重启计数的滑动窗口（秒）
+	RestartDelayMs       int              `json:"restartDelayMs"`       // 首次重启退避时间（毫秒）
+	RestartMaxDelayMs    int              `json:"restartMaxDelayMs"`    // 退避时间上限（毫秒）
+	ExitActions          []string         `json:"exitActions"`          // 退出码动作表，如 "0=exit"、"default=restart"
+	StopMethodSkip       int              `json:"stopMethodSkip"`       // 跳过的停止方式：1=控制台Ctrl+C，2=WM_CLOSE
+	StopConsoleTimeoutMs int              `json:"stopConsoleTimeoutMs"` // 发送Ctrl+C后的等待时间（毫秒）
+	StopWindowTimeoutMs  int              `json:"stopWindowTimeoutMs"`  // 发送WM_CLOSE后的等待时间（毫秒）
+	DetachChildren       bool             `json:"detachChildren"`       // 不使用作业对象跟踪子进程，停止服务时保留子进程
+	Environment          []string         `json:"environment"`          // 环境变量配置："NAME=value"、"NAME+=value"、"-NAME"
+	EnvFiles             []string         `json:"envFiles"`             // .env 文件列表，相对路径基于工作目录，优先级低于 Environment
+	LogRotateBytes       int64            `json:"logRotateBytes"`       // 日志文件超过该大小时轮转，0 表示不按大小轮转
+	LogRotateDaily       bool             `json:"logRotateDaily"`       // 跨天时轮转日志文件
+	LogCompress          bool             `json:"logCompress"`          // 使用 gzip 压缩已轮转的日志文件
+	LogMaxFiles          int              `json:"logMaxFiles"`          // 保留的日志文件数量（包括历次启动），0 表示不限制
+	LogMaxTotalBytes     int64            `json:"logMaxTotalBytes"`     // 所有日志文件的总大小上限，0 表示不限制
+	LogMaxAgeDays        int              `json:"logMaxAgeDays"`        // 日志文件保留天数，0 表示不限制
+	SeparateStderr       bool             `json:"separateStderr"`       // 标准错误写入单独的 .err.log 文件
+	TimestampLogs        bool             `json:"timestampLogs"`        // 每行日志加上时间戳和 [out]/[err] 标记
+	Recovery             *RecoveryConfig  `json:"recovery"`             // SCM故障恢复配置，为空时不修改
+	Dependencies         []string         `json:"dependencies"`         // 依赖的服务或服务组（以 + 开头），为 null 时不修改
+}
+
+// rotateOptions 将服务配置转换为日志轮转参数
+func (config ServiceConfig) rotateOptions() RotateOptions {
+	return RotateOptions{
+		MaxBytes: config.LogRotateBytes,
+		Daily:    config.LogRotateDaily,
+		Compress: config.LogCompress,
+	}
+}
+
+// logRetention 将服务配置转换为日志保留策略
+func (config ServiceConfig) logRetention() LogRetention {
+	return LogRetention{
+		MaxFiles:      config.LogMaxFiles,
+		MaxTotalBytes: config.LogMaxTotalBytes,
+		MaxAge:        time.Duration(config.LogMaxAgeDays) * 24 * time.Hour,
+	}
+}
+
+// restartSettings 将服务配置转换为重启决策参数，未设置的字段使用默认值
+func (config ServiceConfig) restartSettings() RestartSettings {
+	settings := RestartSettings{
+		Policy:      RestartPolicy(config.RestartPolicy),
+		MaxRestarts: config.MaxRestarts,
+		Window:      time.Duration(config.RestartWindowSec) * time.Second,
+		BaseDelay:   time.Duration(config.RestartDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(config.RestartMaxDelayMs) * time.Millisecond,
+		Jitter:      defaultRestartJitter,
+	}
+
+	switch settings.Policy {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		settings.Policy = RestartNever
+	}
+	switch {
+	case config.MaxRestarts == unlimitedRestarts:
+		settings.MaxRestarts = 0
+	case settings.MaxRestarts <= 0:
+		settings.MaxRestarts = defaultMaxRestarts
+	}
+	if settings.Window <= 0 {
+		settings.Window = defaultRestartWindow
+	}
+	if settings.BaseDelay <= 0 {
+		settings.BaseDelay = defaultRestartDelay
+	}
+	if settings.MaxDelay <= 0 {
+		settings.MaxDelay = defaultRestartMaxDelay
+	}
+
+	return settings
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 服务运行账户
+const (
+	ServiceAccountLocalSystem    = "LocalSystem"
+	ServiceAccountLocalService   = `NT AUTHORITY\LocalService`
+	ServiceAccountNetworkService = `NT AUTHORITY\NetworkService`
+	// ServiceAccountVirtual 虚拟账户，实际账户名为 NT SERVICE\<服务名>
+	ServiceAccountVirtual = "virtual"
+)
+
+// serviceAccount 解析后的服务运行账户
+type serviceAccount struct {
+	StartName       string // 写入SCM的账户名
+	NeedsPassword   bool   // 普通用户账户需要密码，组托管服务账户（以 $ 结尾）除外
+	NeedsLogonRight bool   // 需要授予"作为服务登录"权限
+}
+
+// resolveServiceAccount 将配置中的账户转换为SCM使用的账户名。
+// 空值和 LocalSystem 使用本地系统账户；LocalService、NetworkService 可省略 NT AUTHORITY 前缀；
+// virtual 使用以服务名命名的虚拟账户；其他值视为本地或域用户（.\user、DOMAIN\user、user@domain）。
+func resolveServiceAccount(serviceName, account string) serviceAccount {
+	account = strings.TrimSpace(account)
+
+	switch strings.ToLower(account) {
+	case "", strings.ToLower(ServiceAccountLocalSystem), `.\localsystem`, `nt authority\system`:
+		return serviceAccount{StartName: ServiceAccountLocalSystem}
+	case "localservice", strings.ToLower(ServiceAccountLocalService):
+		return serviceAccount{StartName: ServiceAccountLocalService}
+	case "networkservice", strings.ToLower(ServiceAccountNetworkService):
+		return serviceAccount{StartName: ServiceAccountNetworkService}
+	case ServiceAccountVirtual:
+		return serviceAccount{StartName: `NT SERVICE\` + serviceName}
+	}
+
+	if strings.HasPrefix(strings.ToLower(account), `nt service\`) {
+		return serviceAccount{StartName: account}
+	}
+
+	// 未指定域的账户按本地账户处理
+	if !strings.Contains(account, `\`) && !strings.Contains(account, "@") {
+		account = `.\` + account
+	}
+
+	return serviceAccount{
+		StartName:       account,
+		NeedsPassword:   !strings.HasSuffix(account, "$"),
+		NeedsLogonRight: true,
+	}
+}
+
+// sameServiceAccount 判断两个SCM账户名是否指向同一账户
+func sameServiceAccount(a, b string) bool {
+	normalize := func(name string) string {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == `nt authority\system` || name == `.\localsystem` {
+			return strings.ToLower(ServiceAccountLocalSystem)
+		}
+		return name
+	}
+	return normalize(a) == normalize(b)
+}
+
+// prepareServiceAccount 校验账户配置，需要时授予"作为服务登录"权限
+func prepareServiceAccount(scm ServiceControlBackend, account serviceAccount, password string) error {
+	if account.NeedsPassword && password == "" {
+		return fmt.Errorf("账户 %s 需要密码", account.StartName)
+	}
+
+	if account.NeedsLogonRight {
+		if err := scm.GrantServiceLogonRight(account.StartName); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// changeServiceAccount 修改服务的运行账户。内置账户和虚拟账户使用空密码，
+// 账户变化时即使密码为空也会写入，而不是保留原账户的密码。
+func changeServiceAccount(handle ServiceHandle, startName, password string) error {
+	config, err := handle.Config()
+	if err != nil {
+		return fmt.Errorf("获取服务配置失败: %v", err)
+	}
+
+	config.ServiceStartName = startName
+	config.Password = password
+	config.Dependencies = nil
+	if err := handle.UpdateConfig(config); err != nil {
+		return fmt.Errorf("修改服务运行账户失败: %v", err)
+	}
+	return nil
+}
+
+// SetServiceAccount 修改已有服务的运行账户，新账户在服务下次启动时生效
+func (wsm *WindowsServiceManager) SetServiceAccount(serviceID, account, password string) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkConfigurable(); err != nil {
+		return err
+	}
+
+	resolved := resolveServiceAccount(serviceID, account)
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		if err := prepareServiceAccount(scm, resolved, password); err != nil {
+			return err
+		}
+
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		if err := changeServiceAccount(windowsService, resolved.StartName, password); err != nil {
+			return err
+		}
+
+		service.Account = resolved.StartName
+		service.UpdatedAt = time.Now()
+		wsm.emitServicesUpdated()
+
+		return nil
+	})
+}
@@ -0,0 +1,88 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// seServiceLogonRight "作为服务登录"权限
+const seServiceLogonRight = "SeServiceLogonRight"
+
+// LSA 策略访问权限
+const (
+	policyCreateAccount = 0x00000010
+	policyLookupNames   = 0x00000800
+)
+
+var (
+	procLsaOpenPolicy         = modadvapi32.NewProc("LsaOpenPolicy")
+	procLsaAddAccountRights   = modadvapi32.NewProc("LsaAddAccountRights")
+	procLsaClose              = modadvapi32.NewProc("LsaClose")
+	procLsaNtStatusToWinError = modadvapi32.NewProc("LsaNtStatusToWinError")
+)
+
+// lsaObjectAttributes 对应 LSA_OBJECT_ATTRIBUTES
+type lsaObjectAttributes struct {
+	Length                   uint32
+	RootDirectory            windows.Handle
+	ObjectName               *windows.NTUnicodeString
+	Attributes               uint32
+	SecurityDescriptor       uintptr
+	SecurityQualityOfService uintptr
+}
+
+// grantServiceLogonRight 为账户授予"作为服务登录"权限，已有该权限时不报错
+func grantServiceLogonRight(account string) error {
+	lookupName := account
+	if strings.HasPrefix(lookupName, `.\`) {
+		lookupName = lookupName[2:]
+	}
+
+	sid, _, _, err := windows.LookupSID("", lookupName)
+	if err != nil {
+		return fmt.Errorf("查找账户失败: %s: %v", account, err)
+	}
+
+	var attributes lsaObjectAttributes
+	attributes.Length = uint32(unsafe.Sizeof(attributes))
+
+	var policy windows.Handle
+	status, _, _ := procLsaOpenPolicy.Call(
+		0,
+		uintptr(unsafe.Pointer(&attributes)),
+		policyCreateAccount|policyLookupNames,
+		uintptr(unsafe.Pointer(&policy)),
+	)
+	if status != 0 {
+		return fmt.Errorf("打开本地安全策略失败: %v", lsaError(status))
+	}
+	defer procLsaClose.Call(uintptr(policy))
+
+	right, err := windows.NewNTUnicodeString(seServiceLogonRight)
+	if err != nil {
+		return err
+	}
+
+	status, _, _ = procLsaAddAccountRights.Call(
+		uintptr(policy),
+		uintptr(unsafe.Pointer(sid)),
+		uintptr(unsafe.Pointer(right)),
+		1,
+	)
+	if status != 0 {
+		return fmt.Errorf("授予作为服务登录权限失败: %v", lsaError(status))
+	}
+
+	return nil
+}
+
+// lsaError 将 LSA 函数返回的 NTSTATUS 转换为Windows错误
+func lsaError(status uintptr) error {
+	code, _, _ := procLsaNtStatusToWinError.Call(status)
+	return windows.Errno(code)
+}
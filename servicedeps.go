@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// normalizeDependencies 去除依赖列表中的空白项和重复项（不区分大小写），nil 保持为 nil
+func normalizeDependencies(dependencies []string) []string {
+	if dependencies == nil {
+		return nil
+	}
+
+	normalized := make([]string, 0, len(dependencies))
+	seen := make(map[string]bool, len(dependencies))
+	for _, dep := range dependencies {
+		dep = strings.TrimSpace(dep)
+		key := strings.ToLower(dep)
+		if dep == "" || dep == serviceGroupPrefix || seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, dep)
+	}
+	return normalized
+}
+
+// loadDependencyGraph 从SCM读取 roots 及其直接和间接依赖的服务，构建依赖关系图
+func (wsm *WindowsServiceManager) loadDependencyGraph(scm ServiceControlBackend, roots []string) (*DependencyGraph, error) {
+	graph := NewDependencyGraph()
+	queue := append([]string{}, roots...)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if isServiceGroupDependency(name) || graph.Contains(name) {
+			continue
+		}
+
+		windowsService, err := scm.OpenService(name)
+		if err != nil {
+			return nil, fmt.Errorf("依赖的服务不存在: %s", name)
+		}
+		config, err := windowsService.Config()
+		windowsService.Close()
+		if err != nil {
+			return nil, fmt.Errorf("获取服务配置失败: %s: %v", name, err)
+		}
+
+		graph.SetDependencies(name, config.Dependencies)
+		queue = append(queue, config.Dependencies...)
+	}
+
+	return graph, nil
+}
+
+// validateServiceDependencies 检查依赖的服务是否存在，以及设置后是否形成依赖环
+func (wsm *WindowsServiceManager) validateServiceDependencies(scm ServiceControlBackend, serviceName string, dependencies []string) error {
+	for _, dep := range dependencies {
+		if strings.EqualFold(dep, serviceName) {
+			return fmt.Errorf("服务不能依赖自身")
+		}
+	}
+
+	graph, err := wsm.loadDependencyGraph(scm, dependencies)
+	if err != nil {
+		return err
+	}
+
+	graph.SetDependencies(serviceName, dependencies)
+	if cycle := graph.FindCycle(); cycle != nil {
+		return cycleError(cycle)
+	}
+	return nil
+}
+
+// setServiceDependencies 设置服务的依赖列表，dependencies 为空时清除所有依赖
+func setServiceDependencies(handle ServiceHandle, dependencies []string) error {
+	config, err := handle.Config()
+	if err != nil {
+		return fmt.Errorf("获取服务配置失败: %v", err)
+	}
+
+	config.Dependencies = append([]string{}, dependencies...)
+	config.ServiceStartName = ""
+	if err := handle.UpdateConfig(config); err != nil {
+		return fmt.Errorf("设置服务依赖失败: %v", err)
+	}
+	return nil
+}
+
+// loadDependentsGraph 从SCM读取依赖 serviceName 的所有服务（包括间接依赖），构建依赖关系图
+func (wsm *WindowsServiceManager) loadDependentsGraph(scm ServiceControlBackend, windowsService ServiceHandle, serviceName string) (*DependencyGraph, error) {
+	dependents, err := windowsService.ListDependentServices()
+	if err != nil {
+		return nil, fmt.Errorf("查询依赖此服务的服务失败: %v", err)
+	}
+
+	graph := NewDependencyGraph()
+	graph.SetDependencies(serviceName, nil)
+	for _, name := range dependents {
+		dependent, err := scm.OpenService(name)
+		if err != nil {
+			return nil, fmt.Errorf("打开服务失败: %s: %v", name, err)
+		}
+		config, err := dependent.Config()
+		dependent.Close()
+		if err != nil {
+			return nil, fmt.Errorf("获取服务配置失败: %s: %v", name, err)
+		}
+		graph.SetDependencies(name, config.Dependencies)
+	}
+
+	return graph, nil
+}
+
+// StartServiceWithDependencies 按依赖顺序启动服务：先启动其依赖的服务，最后启动该服务。
+// 已在运行的服务会被跳过。服务组依赖由SCM处理。
+func (wsm *WindowsServiceManager) StartServiceWithDependencies(serviceID string) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.lookupService(serviceID)
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkControllable(); err != nil {
+		return err
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		graph, err := wsm.loadDependencyGraph(scm, []string{serviceID})
+		if err != nil {
+			return err
+		}
+
+		order, err := graph.StartOrder(serviceID)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range order {
+			if err := wsm.startOrderedService(scm, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// StopServiceWithDependents 按依赖顺序停止服务：先停止依赖它的服务，最后停止该服务
+func (wsm *WindowsServiceManager) StopServiceWithDependents(serviceID string) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.lookupService(serviceID)
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkControllable(); err != nil {
+		return err
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		graph, err := wsm.loadDependentsGraph(scm, windowsService, serviceID)
+		windowsService.Close()
+		if err != nil {
+			return err
+		}
+
+		order, err := graph.StopOrder(serviceID)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range order {
+			if err := wsm.stopOrderedService(scm, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// lookupService 按名称查找已管理的服务，不区分大小写，与SCM一致，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) lookupService(name string) (*Service, bool) {
+	if service, exists := wsm.services[name]; exists {
+		return service, true
+	}
+	for id, service := range wsm.services {
+		if strings.EqualFold(id, name) {
+			return service, true
+		}
+	}
+	return nil, false
+}
+
+// startOrderedService 启动依赖链中的一个服务，已在运行时跳过，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) startOrderedService(scm ServiceControlBackend, name string) error {
+	windowsService, err := scm.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("打开服务失败: %s: %v", name, err)
+	}
+	defer windowsService.Close()
+
+	status, err := windowsService.Query()
+	if err != nil {
+		return fmt.Errorf("查询服务状态失败: %s: %v", name, err)
+	}
+
+	switch status.State {
+	case StateRunning:
+		return nil
+	case StateStartPending:
+		if err := wsm.waitForServiceState(windowsService, StateRunning, 30*time.Second); err != nil {
+			return fmt.Errorf("等待服务启动失败: %s: %v", name, err)
+		}
+		return nil
+	}
+
+	if service, managed := wsm.lookupService(name); managed {
+		if err := service.checkControllable(); err != nil {
+			return err
+		}
+		wsm.emitServiceStatusChanged(service.ID, "starting", 0)
+		if err := wsm.startServiceLocked(windowsService, service); err != nil {
+			return fmt.Errorf("启动服务 %s 失败: %v", name, err)
+		}
+		return nil
+	}
+
+	if err := windowsService.Start(); err != nil {
+		return fmt.Errorf("启动服务 %s 失败: %v", name, err)
+	}
+	if err := wsm.waitForServiceState(windowsService, StateRunning, 30*time.Second); err != nil {
+		return fmt.Errorf("启动服务 %s 失败: %v", name, err)
+	}
+	return nil
+}
+
+// stopOrderedService 停止依赖链中的一个服务，已停止时跳过，调用方需持有 wsm.mutex
+func (wsm *WindowsServiceManager) stopOrderedService(scm ServiceControlBackend, name string) error {
+	windowsService, err := scm.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("打开服务失败: %s: %v", name, err)
+	}
+	defer windowsService.Close()
+
+	status, err := windowsService.Query()
+	if err != nil {
+		return fmt.Errorf("查询服务状态失败: %s: %v", name, err)
+	}
+
+	switch status.State {
+	case StateStopped:
+		return nil
+	case StateStopPending:
+		if err := wsm.waitForServiceState(windowsService, StateStopped, 30*time.Second); err != nil {
+			return fmt.Errorf("等待服务停止失败: %s: %v", name, err)
+		}
+		return nil
+	}
+
+	if service, managed := wsm.lookupService(name); managed {
+		if err := service.checkControllable(); err != nil {
+			return err
+		}
+		wsm.emitServiceStatusChanged(service.ID, "stopping", int(status.ProcessId))
+		if err := wsm.stopServiceLocked(windowsService, service); err != nil {
+			return fmt.Errorf("停止服务 %s 失败: %v", name, err)
+		}
+		return nil
+	}
+
+	if _, err := windowsService.Control(ControlStop); err != nil {
+		return fmt.Errorf("停止服务 %s 失败: %v", name, err)
+	}
+	if err := wsm.waitForServiceState(windowsService, StateStopped, 30*time.Second); err != nil {
+		return fmt.Errorf("停止服务 %s 失败: %v", name, err)
+	}
+	return nil
+}
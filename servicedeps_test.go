@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStartServiceWithDependenciesReadOnlyExternal(t *testing.T) {
+	wsm, scm, _ := newTestManager(t)
+
+	if _, err := scm.CreateService("Database", SCMServiceConfig{BinaryPathName: `C:\db.exe`}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wsm.AdoptExternalServices("Database", true); err != nil {
+		t.Fatalf("AdoptExternalServices: %v", err)
+	}
+
+	config := testServiceConfig(t, "web")
+	config.Dependencies = []string{"database"}
+	web, err := wsm.CreateService(config)
+	if err != nil {
+		t.Fatalf("CreateService: %v", err)
+	}
+
+	if err := wsm.StartServiceWithDependencies(strings.ToLower(web.ID)); err == nil {
+		t.Fatalf("依赖只读的外部服务时应返回错误")
+	}
+	if state := queryState(t, scm, "Database"); state != StateStopped {
+		t.Fatalf("只读的外部服务被启动: %v", state)
+	}
+	if state := queryState(t, scm, web.ID); state != StateStopped {
+		t.Fatalf("依赖未启动时不应启动服务: %v", state)
+	}
+
+	if err := wsm.SetExternalServiceReadOnly("Database", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := wsm.StartServiceWithDependencies(web.ID); err != nil {
+		t.Fatalf("StartServiceWithDependencies: %v", err)
+	}
+	if wsm.services["Database"].Status != "running" || web.Status != "running" {
+		t.Fatalf("服务状态未更新: %s, %s", wsm.services["Database"].Status, web.Status)
+	}
+
+	if err := wsm.SetExternalServiceReadOnly("Database", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := wsm.StopServiceWithDependents("Database"); err == nil {
+		t.Fatalf("只读的外部服务不应被停止")
+	}
+	if state := queryState(t, scm, web.ID); state != StateRunning {
+		t.Fatalf("拒绝停止时不应停止依赖它的服务: %v", state)
+	}
+}
+
+// queryState 查询模拟服务的当前状态
+func queryState(t *testing.T, scm *FakeSCM, name string) ServiceState {
+	t.Helper()
+
+	handle, err := scm.OpenService(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle.Close()
+
+	status, err := handle.Query()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return status.State
+}
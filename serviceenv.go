@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// EnvOp 环境变量操作类型
+type EnvOp string
+
+const (
+	EnvSet    EnvOp = "set"    // NAME=value，设置变量
+	EnvAppend EnvOp = "append" // NAME+=value，以分号追加到已有值之后
+	EnvUnset  EnvOp = "unset"  // -NAME，删除变量
+)
+
+// EnvEntry 单条服务环境变量配置
+type EnvEntry struct {
+	Op    EnvOp
+	Name  string
+	Value string
+}
+
+// String 返回环境变量配置的存储格式
+func (entry EnvEntry) String() string {
+	switch entry.Op {
+	case EnvAppend:
+		return entry.Name + "+=" + entry.Value
+	case EnvUnset:
+		return "-" + entry.Name
+	default:
+		return entry.Name + "=" + entry.Value
+	}
+}
+
+// ParseEnvEntry 解析单条环境变量配置："NAME=value"、"NAME+=value" 或 "-NAME"
+func ParseEnvEntry(text string) (EnvEntry, error) {
+	if strings.HasPrefix(text, "-") {
+		name := strings.TrimSpace(text[1:])
+		if name == "" || strings.Contains(name, "=") {
+			return EnvEntry{}, fmt.Errorf("无效的环境变量删除配置: %s", text)
+		}
+		return EnvEntry{Op: EnvUnset, Name: name}, nil
+	}
+
+	name, value, ok := strings.Cut(text, "=")
+	if !ok {
+		return EnvEntry{}, fmt.Errorf("无效的环境变量配置（缺少=）: %s", text)
+	}
+
+	op := EnvSet
+	if strings.HasSuffix(name, "+") {
+		op = EnvAppend
+		name = strings.TrimSuffix(name, "+")
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return EnvEntry{}, fmt.Errorf("环境变量名不能为空: %s", text)
+	}
+
+	return EnvEntry{Op: op, Name: name, Value: value}, nil
+}
+
+// ParseEnvEntries 解析环境变量配置列表，忽略空行
+func ParseEnvEntries(texts []string) ([]EnvEntry, error) {
+	entries := make([]EnvEntry, 0, len(texts))
+	for _, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		entry, err := ParseEnvEntry(text)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// envBlock 保持顺序且变量名不区分大小写的环境变量集合
+type envBlock struct {
+	names  []string
+	values map[string]string // 键为大写变量名
+	cased  map[string]string // 大写变量名到原始变量名的映射
+}
+
+// newEnvBlock 从 "NAME=value" 形式的列表创建环境变量集合
+func newEnvBlock(environ []string) *envBlock {
+	block := &envBlock{
+		values: make(map[string]string),
+		cased:  make(map[string]string),
+	}
+	for _, kv := range environ {
+		// Windows 中存在 "=C:=C:\" 这类以等号开头的变量，从第二个字符开始查找分隔符
+		idx := strings.Index(kv[min(1, len(kv)):], "=")
+		if idx < 0 {
+			continue
+		}
+		idx += min(1, len(kv))
+		block.Set(kv[:idx], kv[idx+1:])
+	}
+	return block
+}
+
+// Get 获取变量值
+func (block *envBlock) Get(name string) (string, bool) {
+	value, ok := block.values[strings.ToUpper(name)]
+	return value, ok
+}
+
+// Set 设置变量值，已存在的变量保持原有位置和大小写
+func (block *envBlock) Set(name, value string) {
+	key := strings.ToUpper(name)
+	if _, exists := block.values[key]; !exists {
+		block.names = append(block.names, key)
+		block.cased[key] = name
+	}
+	block.values[key] = value
+}
+
+// Unset 删除变量
+func (block *envBlock) Unset(name string) {
+	key := strings.ToUpper(name)
+	if _, exists := block.values[key]; !exists {
+		return
+	}
+	delete(block.values, key)
+	delete(block.cased, key)
+	for i, n := range block.names {
+		if n == key {
+			block.names = append(block.names[:i], block.names[i+1:]...)
+			break
+		}
+	}
+}
+
+// Environ 返回 "NAME=value" 形式的列表
+func (block *envBlock) Environ() []string {
+	environ := make([]string, 0, len(block.names))
+	for _, key := range block.names {
+		environ = append(environ, block.cased[key]+"="+block.values[key])
+	}
+	return environ
+}
+
+// Apply 依次应用环境变量配置，值中的 %VAR% 引用按当前集合展开
+func (block *envBlock) Apply(entries []EnvEntry) {
+	for _, entry := range entries {
+		switch entry.Op {
+		case EnvUnset:
+			block.Unset(entry.Name)
+		case EnvAppend:
+			value := expandEnvReferences(entry.Value, block.Get)
+			if existing, ok := block.Get(entry.Name); ok && existing != "" {
+				value = strings.TrimSuffix(existing, ";") + ";" + value
+			}
+			block.Set(entry.Name, value)
+		default:
+			block.Set(entry.Name, expandEnvReferences(entry.Value, block.Get))
+		}
+	}
+}
+
+// ApplyEnvEntries 在基础环境上应用服务环境变量配置
+func ApplyEnvEntries(environ []string, entries []EnvEntry) []string {
+	block := newEnvBlock(environ)
+	block.Apply(entries)
+	return block.Environ()
+}
+
+// expandEnvReferences 展开 %VAR% 形式的引用，未定义的变量保持原样（与 cmd.exe 一致）
+func expandEnvReferences(value string, lookup func(string) (string, bool)) string {
+	var b strings.Builder
+
+	for {
+		start := strings.IndexByte(value, '%')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(value[start+1:], '%')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+
+		b.WriteString(value[:start])
+		name := value[start+1 : end]
+		if name == "" {
+			// "%%" 输出一个百分号
+			b.WriteByte('%')
+			value = value[end+1:]
+			continue
+		}
+		if resolved, ok := lookup(name); ok {
+			b.WriteString(resolved)
+			value = value[end+1:]
+			continue
+		}
+		// 未定义的变量原样输出开头的百分号，结尾的百分号可能是下一个引用的开始
+		b.WriteString(value[start:end])
+		value = value[end:]
+	}
+
+	b.WriteString(value)
+	return b.String()
+}
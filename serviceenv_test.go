@@ -0,0 +1,141 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvEntry(t *testing.T) {
+	tests := []struct {
+		input string
+		want  EnvEntry
+	}{
+		{"PATH=C:\\bin", EnvEntry{Op: EnvSet, Name: "PATH", Value: "C:\\bin"}},
+		{"A=", EnvEntry{Op: EnvSet, Name: "A", Value: ""}},
+		{"A=x=y", EnvEntry{Op: EnvSet, Name: "A", Value: "x=y"}},
+		{" A =1", EnvEntry{Op: EnvSet, Name: "A", Value: "1"}},
+		{"PATH+=C:\\tools", EnvEntry{Op: EnvAppend, Name: "PATH", Value: "C:\\tools"}},
+		{"-TEMP", EnvEntry{Op: EnvUnset, Name: "TEMP"}},
+		{"- TEMP ", EnvEntry{Op: EnvUnset, Name: "TEMP"}},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseEnvEntry(tt.input)
+		if err != nil {
+			t.Errorf("ParseEnvEntry(%q): %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseEnvEntry(%q) = %+v, 期望 %+v", tt.input, got, tt.want)
+		}
+		if reparsed, err := ParseEnvEntry(got.String()); err != nil || reparsed != got {
+			t.Errorf("ParseEnvEntry(%q.String()) = %+v, %v", tt.input, reparsed, err)
+		}
+	}
+
+	for _, input := range []string{"NOEQUALS", "=value", "+=value", "-", "-A=1"} {
+		if _, err := ParseEnvEntry(input); err == nil {
+			t.Errorf("ParseEnvEntry(%q) 应返回错误", input)
+		}
+	}
+}
+
+func TestParseEnvEntries(t *testing.T) {
+	entries, err := ParseEnvEntries([]string{"A=1", "", "  ", "-B"})
+	if err != nil {
+		t.Fatalf("ParseEnvEntries: %v", err)
+	}
+	want := []EnvEntry{{Op: EnvSet, Name: "A", Value: "1"}, {Op: EnvUnset, Name: "B"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("ParseEnvEntries = %+v", entries)
+	}
+
+	if _, err := ParseEnvEntries([]string{"A=1", "BAD"}); err == nil {
+		t.Fatalf("包含无效配置时应返回错误")
+	}
+}
+
+func TestExpandEnvReferences(t *testing.T) {
+	vars := map[string]string{"HOME": `C:\Users\svc`, "A": "1", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := vars[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{`%HOME%\bin`, `C:\Users\svc\bin`},
+		{"%A%%A%", "11"},
+		{"x%EMPTY%y", "xy"},
+		{"100%%", "100%"},
+		{"%%A%%", "%A%"},
+		{"50%", "50%"},
+		{"%MISSING%", "%MISSING%"},
+		// 未定义的 %A 保留结尾的百分号，作为后续 %B% 引用的开始
+		{"%A%B%", "1B%"},
+		{"%X%A%", "%X1"},
+		{"%X%A%B", "%X1B"},
+	}
+
+	for _, tt := range tests {
+		if got := expandEnvReferences(tt.input, lookup); got != tt.want {
+			t.Errorf("expandEnvReferences(%q) = %q, 期望 %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestNewEnvBlock(t *testing.T) {
+	block := newEnvBlock([]string{`=C:=C:\work`, "=", "Path=C:\\Windows", "NOEQUALS", "", "A=1=2", "PATH=C:\\override"})
+
+	if value, ok := block.Get("=C:"); !ok || value != `C:\work` {
+		t.Fatalf("Get(=C:) = %q, %v", value, ok)
+	}
+	if value, ok := block.Get("path"); !ok || value != `C:\override` {
+		t.Fatalf("Get(path) = %q, %v", value, ok)
+	}
+	if value, _ := block.Get("A"); value != "1=2" {
+		t.Fatalf("Get(A) = %q", value)
+	}
+
+	want := []string{`=C:=C:\work`, `Path=C:\override`, "A=1=2"}
+	if got := block.Environ(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Environ = %q\n期望 %q", got, want)
+	}
+}
+
+func TestEnvBlockApply(t *testing.T) {
+	base := []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, "Home=C:\\Users\\svc"}
+
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+	}{
+		{"set new", []string{"APP=1"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`, "APP=1"}},
+		{"set keeps position and case", []string{"HOME=D:\\svc"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=D:\svc`}},
+		{"unset case-insensitive", []string{"-temp"}, []string{`Path=C:\Windows;`, `Home=C:\Users\svc`}},
+		{"unset missing", []string{"-NONE"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`}},
+		{"append after trailing semicolon", []string{`PATH+=C:\tools`}, []string{`Path=C:\Windows;C:\tools`, `TEMP=C:\Temp`, `Home=C:\Users\svc`}},
+		{"append new", []string{"EXTRA+=x"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`, "EXTRA=x"}},
+		{"append to empty", []string{"E=", "E+=x"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`, "E=x"}},
+		{"expand case-insensitive", []string{`DATA=%home%\data`}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`, `DATA=C:\Users\svc\data`}},
+		{"expand earlier entries", []string{"A=1", "B=%A%2", "-A", "C=%A%"}, []string{`Path=C:\Windows;`, `TEMP=C:\Temp`, `Home=C:\Users\svc`, "B=12", "C=%A%"}},
+		{"append expands", []string{`PATH+=%HOME%\bin`}, []string{`Path=C:\Windows;C:\Users\svc\bin`, `TEMP=C:\Temp`, `Home=C:\Users\svc`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseEnvEntries(tt.entries)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ApplyEnvEntries(base, entries); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("ApplyEnvEntries = %q\n期望 %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// snapshotServiceParameters 读取服务参数中的所有值，用于更新失败时回滚
+func (wsm *WindowsServiceManager) snapshotServiceParameters(serviceName string) (map[string]ParameterValue, error) {
+	var snapshot map[string]ParameterValue
+	err := wsm.withServiceParameters(serviceName, func(store ServiceParameterStore) error {
+		var err error
+		snapshot, err = snapshotParameters(store)
+		return err
+	})
+	return snapshot, err
+}
+
+// restoreServiceParameters 将服务参数恢复为快照中的内容，快照之后新增的值被删除
+func (wsm *WindowsServiceManager) restoreServiceParameters(serviceName string, snapshot map[string]ParameterValue) error {
+	return wsm.withServiceParameters(serviceName, func(store ServiceParameterStore) error {
+		return restoreParameters(store, snapshot)
+	})
+}
+
+// UpdateService 修改已有服务的配置。SCM配置（显示名称、描述、启动类型、依赖、故障恢复、运行账户）和 Parameters 注册表
+// 一起更新，任一步骤失败时回滚到修改前的状态。服务正在运行时，新配置在下次启动时生效；
+// restartIfRunning 为 true 时立即重启服务使配置生效，重启在保存配置并释放 wsm.mutex 之后进行。
+func (wsm *WindowsServiceManager) UpdateService(serviceID string, config ServiceConfig, restartIfRunning bool) (*Service, error) {
+	service, restart, err := wsm.updateServiceConfig(serviceID, config, restartIfRunning)
+	if err != nil {
+		return nil, err
+	}
+	if !restart {
+		return service, nil
+	}
+
+	err = wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		return wsm.restartService(windowsService, service, 0)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("配置已保存，但%v", err)
+	}
+	return service, nil
+}
+
+// updateServiceConfig 保存服务配置，返回服务正在运行且需要重启时 restart 为 true
+func (wsm *WindowsServiceManager) updateServiceConfig(serviceID string, config ServiceConfig, restartIfRunning bool) (service *Service, restart bool, err error) {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return nil, false, fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkConfigurable(); err != nil {
+		return nil, false, err
+	}
+
+	// 名称同时作为显示名称，SCM不接受空的显示名称
+	if strings.TrimSpace(config.Name) == "" {
+		return nil, false, fmt.Errorf("服务名称不能为空")
+	}
+	if err := normalizeServiceConfig(&config); err != nil {
+		return nil, false, err
+	}
+
+	err = wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		previousConfig, err := windowsService.Config()
+		if err != nil {
+			return fmt.Errorf("获取服务配置失败: %v", err)
+		}
+
+		// 账户未变化且未提供新密码时保留原有账户和密码
+		account := resolveServiceAccount(serviceID, config.Account)
+		changeAccount := !sameServiceAccount(account.StartName, previousConfig.ServiceStartName) || config.Password != ""
+		if changeAccount {
+			if err := prepareServiceAccount(scm, account, config.Password); err != nil {
+				return err
+			}
+			config.Account = account.StartName
+		} else {
+			config.Account = previousConfig.ServiceStartName
+		}
+
+		if config.Dependencies != nil {
+			if err := wsm.validateServiceDependencies(scm, serviceID, config.Dependencies); err != nil {
+				return err
+			}
+		}
+
+		snapshot, err := wsm.snapshotServiceParameters(serviceID)
+		if err != nil {
+			return err
+		}
+
+		var previousRecovery *RecoveryConfig
+		if config.Recovery != nil {
+			previousRecovery, err = queryServiceRecovery(windowsService)
+			if err != nil {
+				return err
+			}
+		}
+
+		// 运行账户单独修改，UpdateConfig 不改变账户和密码；
+		// 回滚时依赖列表按原样写回，原来没有依赖时清除新设置的依赖
+		previousConfig.ServiceStartName = ""
+		previousConfig.Dependencies = append([]string{}, previousConfig.Dependencies...)
+		serviceConfig := previousConfig
+		serviceConfig.DisplayName = config.Name
+		serviceConfig.Description = serviceDescription(config)
+		serviceConfig.Dependencies = nil
+		if config.Dependencies != nil {
+			serviceConfig.Dependencies = append([]string{}, config.Dependencies...)
+		}
+		if err := config.StartType.applyTo(&serviceConfig); err != nil {
+			return err
+		}
+
+		if err := windowsService.UpdateConfig(serviceConfig); err != nil {
+			return fmt.Errorf("更新服务配置失败: %v", err)
+		}
+
+		// 运行账户最后修改，失败时只需回滚前面的步骤
+		err = wsm.storeServiceConfigInRegistry(serviceID, config)
+		if err == nil {
+			err = wsm.setServiceWorkingDirectory(serviceID, config.WorkingDir)
+		}
+		recoveryApplied := false
+		if err == nil && config.Recovery != nil {
+			err = applyServiceRecovery(windowsService, *config.Recovery)
+			recoveryApplied = err == nil
+		}
+		if err == nil && changeAccount {
+			err = changeServiceAccount(windowsService, account.StartName, config.Password)
+		}
+		if err != nil {
+			if recoveryApplied {
+				if rollbackErr := applyServiceRecovery(windowsService, *previousRecovery); rollbackErr != nil {
+					err = fmt.Errorf("%v；回滚故障恢复配置失败: %v", err, rollbackErr)
+				}
+			}
+			if rollbackErr := windowsService.UpdateConfig(previousConfig); rollbackErr != nil {
+				err = fmt.Errorf("%v；回滚服务配置失败: %v", err, rollbackErr)
+			}
+			if rollbackErr := wsm.restoreServiceParameters(serviceID, snapshot); rollbackErr != nil {
+				err = fmt.Errorf("%v；回滚注册表配置失败: %v", err, rollbackErr)
+			}
+			return fmt.Errorf("存储服务配置失败: %v", err)
+		}
+
+		service.applyConfig(config)
+
+		if restartIfRunning {
+			status, err := windowsService.Query()
+			restart = err == nil && status.State == StateRunning
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	wsm.emitServicesUpdated()
+	return service, restart, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ServiceStartType 服务启动类型
+type ServiceStartType string
+
+const (
+	ServiceStartAutomatic ServiceStartType = "automatic" // 自动
+	ServiceStartDelayed   ServiceStartType = "delayed"   // 自动（延迟启动）
+	ServiceStartManual    ServiceStartType = "manual"    // 手动
+	ServiceStartDisabled  ServiceStartType = "disabled"  // 禁用
+)
+
+// applyTo 将启动类型写入SCM配置，空值视为自动启动
+func (startType ServiceStartType) applyTo(config *SCMServiceConfig) error {
+	switch startType {
+	case "", ServiceStartAutomatic:
+		config.StartType = scmStartAutomatic
+		config.DelayedAutoStart = false
+	case ServiceStartDelayed:
+		config.StartType = scmStartAutomatic
+		config.DelayedAutoStart = true
+	case ServiceStartManual:
+		config.StartType = scmStartManual
+		config.DelayedAutoStart = false
+	case ServiceStartDisabled:
+		config.StartType = scmStartDisabled
+		config.DelayedAutoStart = false
+	default:
+		return fmt.Errorf("不支持的启动类型: %s", startType)
+	}
+	return nil
+}
+
+// serviceStartTypeFromConfig 从SCM配置中读取启动类型
+func serviceStartTypeFromConfig(config SCMServiceConfig) ServiceStartType {
+	switch config.StartType {
+	case scmStartAutomatic:
+		if config.DelayedAutoStart {
+			return ServiceStartDelayed
+		}
+		return ServiceStartAutomatic
+	case scmStartDisabled:
+		return ServiceStartDisabled
+	default:
+		return ServiceStartManual
+	}
+}
+
+// SetServiceStartType 设置服务启动类型
+func (wsm *WindowsServiceManager) SetServiceStartType(serviceID string, startType ServiceStartType) error {
+	wsm.mutex.Lock()
+	defer wsm.mutex.Unlock()
+
+	service, exists := wsm.services[serviceID]
+	if !exists {
+		return fmt.Errorf("服务不存在: %s", serviceID)
+	}
+	if err := service.checkConfigurable(); err != nil {
+		return err
+	}
+
+	return wsm.withSCM(func(scm ServiceControlBackend) error {
+		windowsService, err := scm.OpenService(serviceID)
+		if err != nil {
+			return fmt.Errorf("打开服务失败: %v", err)
+		}
+		defer windowsService.Close()
+
+		config, err := windowsService.Config()
+		if err != nil {
+			return fmt.Errorf("获取服务配置失败: %v", err)
+		}
+
+		if err := startType.applyTo(&config); err != nil {
+			return err
+		}
+
+		// 只修改启动类型，不改变运行账户和依赖
+		config.ServiceStartName = ""
+		config.Dependencies = nil
+		err = windowsService.UpdateConfig(config)
+		if err != nil {
+			return fmt.Errorf("更新服务配置失败: %v", err)
+		}
+
+		service.StartType = serviceStartTypeFromConfig(config)
+		service.UpdatedAt = time.Now()
+		wsm.emitServicesUpdated()
+
+		return nil
+	})
+}
+
+// GetServiceStartType 从SCM读取服务当前的启动类型
+func (wsm *WindowsServiceManager) GetServiceStartType(serviceID string) (ServiceStartType, error) {
+	wsm.mutex.RLock()
+	defer wsm.mutex.RUnlock()
+
+	if _, exists := wsm.services[serviceID]; !exists {
+		return "", fmt.Errorf("服务不存在: %s", serviceID)
+	}
+
+	var startType ServiceStartType
+	err := wsm.withSCM(func(scm ServiceControlBackend) error {
+		var err error
+		startType, err = wsm.queryServiceStartType(scm, serviceID)
+		return err
+	})
+	return startType, err
+}
+
+// queryServiceStartType 查询SCM中服务的启动类型
+func (wsm *WindowsServiceManager) queryServiceStartType(scm ServiceControlBackend, serviceID string) (ServiceStartType, error) {
+	windowsService, err := scm.OpenService(serviceID)
+	if err != nil {
+		return "", fmt.Errorf("打开服务失败: %v", err)
+	}
+	defer windowsService.Close()
+
+	config, err := windowsService.Config()
+	if err != nil {
+		return "", fmt.Errorf("获取服务配置失败: %v", err)
+	}
+	return serviceStartTypeFromConfig(config), nil
+}
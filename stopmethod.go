@@ -0,0 +1,100 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	defaultStopConsoleTimeout   = 1500 * time.Millisecond
+	defaultStopWindowTimeout    = 1500 * time.Millisecond
+	defaultStopTerminateTimeout = 5 * time.Second
+	stopWaitHintMargin          = 2 * time.Second
+)
+
+var (
+	moduser32 = windows.NewLazySystemDLL("user32.dll")
+
+	procAttachConsole         = modkernel32.NewProc("AttachConsole")
+	procFreeConsole           = modkernel32.NewProc("FreeConsole")
+	procSetConsoleCtrlHandler = modkernel32.NewProc("SetConsoleCtrlHandler")
+	procPostMessageW          = moduser32.NewProc("PostMessageW")
+)
+
+// stopStage 停止目标程序的一个阶段
+type stopStage struct {
+	name    string
+	timeout time.Duration
+	signal  func(pid int) (bool, error) // 返回是否成功发出停止请求
+}
+
+// stopStages 根据服务配置生成停止升级序列（不包含最终的强制终止）
+func (config ServiceConfig) stopStages() []stopStage {
+	var stages []stopStage
+
+	if config.StopMethodSkip&StopMethodSkipConsole == 0 {
+		timeout := time.Duration(config.StopConsoleTimeoutMs) * time.Millisecond
+		if timeout <= 0 {
+			timeout = defaultStopConsoleTimeout
+		}
+		stages = append(stages, stopStage{name: "控制台Ctrl+C", timeout: timeout, signal: sendConsoleCtrlEvent})
+	}
+
+	if config.StopMethodSkip&StopMethodSkipWindow == 0 {
+		timeout := time.Duration(config.StopWindowTimeoutMs) * time.Millisecond
+		if timeout <= 0 {
+			timeout = defaultStopWindowTimeout
+		}
+		stages = append(stages, stopStage{name: "WM_CLOSE", timeout: timeout, signal: closeProcessWindows})
+	}
+
+	return stages
+}
+
+// sendConsoleCtrlEvent 附加到目标程序的控制台并发送 Ctrl+C 事件
+func sendConsoleCtrlEvent(pid int) (bool, error) {
+	procFreeConsole.Call()
+
+	r0, _, e1 := syscall.SyscallN(procAttachConsole.Addr(), uintptr(pid))
+	if r0 == 0 {
+		return false, fmt.Errorf("附加到目标程序控制台失败: %v", e1)
+	}
+	defer procFreeConsole.Call()
+
+	// 忽略本进程的 Ctrl+C，避免包装器自身被中断；包装器即将停止，因此无需恢复
+	procSetConsoleCtrlHandler.Call(0, 1)
+
+	if err := windows.GenerateConsoleCtrlEvent(windows.CTRL_C_EVENT, 0); err != nil {
+		return false, fmt.Errorf("发送控制台事件失败: %v", err)
+	}
+
+	return true, nil
+}
+
+// closeProcessWindows 向目标程序的所有顶层窗口发送 WM_CLOSE
+func closeProcessWindows(pid int) (bool, error) {
+	const WM_CLOSE = 0x0010
+
+	posted := 0
+	callback := windows.NewCallback(func(hwnd windows.HWND, lparam uintptr) uintptr {
+		var windowPid uint32
+		if _, err := windows.GetWindowThreadProcessId(hwnd, &windowPid); err == nil && int(windowPid) == pid {
+			if r0, _, _ := procPostMessageW.Call(uintptr(hwnd), WM_CLOSE, 0, 0); r0 != 0 {
+				posted++
+			}
+		}
+		return 1
+	})
+
+	if err := windows.EnumWindows(callback, unsafe.Pointer(nil)); err != nil {
+		return false, fmt.Errorf("枚举窗口失败: %v", err)
+	}
+
+	return posted > 0, nil
+}
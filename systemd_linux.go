@@ -0,0 +1,396 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// defaultSystemdUnitDir 系统级 unit 文件的默认安装目录
+const defaultSystemdUnitDir = "/etc/systemd/system"
+
+// CommandRunner 执行外部命令，返回标准输出
+type CommandRunner interface {
+	Run(name string, args ...string) ([]byte, error)
+}
+
+// execCommandRunner 直接执行命令
+type execCommandRunner struct{}
+
+func (execCommandRunner) Run(name string, args ...string) ([]byte, error) {
+	output, err := exec.Command(name, args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		return output, fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+// SystemdBackend 以 systemd unit 管理服务：将 ServiceConfig 渲染为 unit 文件，
+// 安装到 unit 目录并通过 systemctl 控制
+type SystemdBackend struct {
+	unitDir string
+	runner  CommandRunner
+}
+
+// NewSystemdBackend 创建 systemd 后端。unitDir 为空时使用 /etc/systemd/system，
+// runner 为空时直接执行 systemctl
+func NewSystemdBackend(unitDir string, runner CommandRunner) *SystemdBackend {
+	if unitDir == "" {
+		unitDir = defaultSystemdUnitDir
+	}
+	if runner == nil {
+		runner = execCommandRunner{}
+	}
+	return &SystemdBackend{unitDir: unitDir, runner: runner}
+}
+
+// systemdUnitName 返回服务的 unit 名称，服务名只允许 systemd 接受的字符
+func systemdUnitName(serviceName string) (string, error) {
+	if serviceName == "" {
+		return "", fmt.Errorf("服务名不能为空")
+	}
+	for _, c := range serviceName {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune(":-_.\\", c)) {
+			return "", fmt.Errorf("服务名包含 systemd 不支持的字符: %s", serviceName)
+		}
+	}
+	return serviceName + ".service", nil
+}
+
+// unitPath 返回服务 unit 文件的路径
+func (backend *SystemdBackend) unitPath(unitName string) string {
+	return filepath.Join(backend.unitDir, unitName)
+}
+
+// systemctl 执行 systemctl 命令
+func (backend *SystemdBackend) systemctl(args ...string) ([]byte, error) {
+	output, err := backend.runner.Run("systemctl", args...)
+	if err != nil {
+		return output, fmt.Errorf("systemctl %s 失败: %v", strings.Join(args, " "), err)
+	}
+	return output, nil
+}
+
+// Install 生成并安装服务的 unit 文件，重新加载 systemd 配置后按启动类型启用或禁用服务。
+// 已安装的服务被覆盖，新配置在下次启动时生效
+func (backend *SystemdBackend) Install(serviceName string, config ServiceConfig) error {
+	unitName, err := systemdUnitName(serviceName)
+	if err != nil {
+		return err
+	}
+
+	unit, err := RenderSystemdUnit(config)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(backend.unitDir, 0755); err != nil {
+		return fmt.Errorf("创建unit目录失败: %v", err)
+	}
+	path := backend.unitPath(unitName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("写入unit文件失败: %v", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("写入unit文件失败: %v", err)
+	}
+
+	if _, err := backend.systemctl("daemon-reload"); err != nil {
+		return err
+	}
+
+	switch config.StartType {
+	case ServiceStartManual, ServiceStartDisabled:
+		_, err = backend.systemctl("disable", unitName)
+	default:
+		_, err = backend.systemctl("enable", unitName)
+	}
+	return err
+}
+
+// Uninstall 停止并禁用服务，删除 unit 文件后重新加载 systemd 配置
+func (backend *SystemdBackend) Uninstall(serviceName string) error {
+	unitName, err := systemdUnitName(serviceName)
+	if err != nil {
+		return err
+	}
+
+	if _, err := backend.systemctl("stop", unitName); err != nil {
+		return err
+	}
+	if _, err := backend.systemctl("disable", unitName); err != nil {
+		return err
+	}
+
+	if err := os.Remove(backend.unitPath(unitName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除unit文件失败: %v", err)
+	}
+
+	_, err = backend.systemctl("daemon-reload")
+	return err
+}
+
+// Start 启动服务
+func (backend *SystemdBackend) Start(serviceName string) error {
+	return backend.control("start", serviceName)
+}
+
+// Stop 停止服务
+func (backend *SystemdBackend) Stop(serviceName string) error {
+	return backend.control("stop", serviceName)
+}
+
+// Restart 重启服务，服务未运行时启动服务
+func (backend *SystemdBackend) Restart(serviceName string) error {
+	return backend.control("restart", serviceName)
+}
+
+func (backend *SystemdBackend) control(action, serviceName string) error {
+	unitName, err := systemdUnitName(serviceName)
+	if err != nil {
+		return err
+	}
+	_, err = backend.systemctl(action, unitName)
+	return err
+}
+
+// Status 查询服务状态，返回与 Service.Status 相同的 "running"、"stopped"、"error" 和主进程PID
+func (backend *SystemdBackend) Status(serviceName string) (string, int, error) {
+	unitName, err := systemdUnitName(serviceName)
+	if err != nil {
+		return "", 0, err
+	}
+
+	output, err := backend.systemctl("show", unitName, "--property=ActiveState", "--property=MainPID")
+	if err != nil {
+		return "error", 0, err
+	}
+
+	var activeState string
+	var pid int
+	for _, line := range strings.Split(string(output), "\n") {
+		name, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch name {
+		case "ActiveState":
+			activeState = value
+		case "MainPID":
+			pid, _ = strconv.Atoi(value)
+		}
+	}
+
+	switch activeState {
+	case "active", "activating", "reloading", "deactivating":
+		return "running", pid, nil
+	case "failed":
+		return "error", 0, nil
+	default:
+		return "stopped", 0, nil
+	}
+}
+
+// RenderSystemdUnit 将服务配置转换为 systemd unit 文件内容。
+// 环境变量追加（NAME+=value）和 Windows 账户没有对应的 systemd 配置，返回错误
+func RenderSystemdUnit(config ServiceConfig) (string, error) {
+	if config.ExePath == "" {
+		return "", fmt.Errorf("可执行文件路径不能为空")
+	}
+
+	var unit strings.Builder
+	var err error
+	line := func(name, value string) {
+		if err != nil {
+			return
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			err = fmt.Errorf("%s 不能包含换行符", name)
+			return
+		}
+		fmt.Fprintf(&unit, "%s=%s\n", name, value)
+	}
+
+	settings := config.restartSettings()
+
+	unit.WriteString("[Unit]\n")
+	line("Description", escapeSystemdSpecifiers(serviceDescription(config)))
+	for _, dependency := range config.Dependencies {
+		// 服务组没有对应的 unit
+		if dependency == "" || strings.HasPrefix(dependency, "+") {
+			continue
+		}
+		dependencyUnit, unitErr := systemdUnitName(dependency)
+		if unitErr != nil {
+			return "", unitErr
+		}
+		line("Requires", dependencyUnit)
+		line("After", dependencyUnit)
+	}
+	if settings.Policy != RestartNever {
+		if settings.MaxRestarts > 0 {
+			line("StartLimitIntervalSec", strconv.Itoa(int(settings.Window.Seconds())))
+			line("StartLimitBurst", strconv.Itoa(settings.MaxRestarts))
+		} else {
+			// 间隔为0时 systemd 不限制启动频率
+			line("StartLimitIntervalSec", "0")
+		}
+	}
+
+	unit.WriteString("\n[Service]\n")
+	line("Type", "simple")
+
+	command := append([]string{config.ExePath}, config.argv()...)
+	line("ExecStart", joinSystemdCommandLine(command))
+	if config.WorkingDir != "" {
+		line("WorkingDirectory", escapeSystemdSpecifiers(config.WorkingDir))
+	}
+
+	entries, envErr := ParseEnvEntries(config.Environment)
+	if envErr != nil {
+		return "", fmt.Errorf("环境变量配置无效: %v", envErr)
+	}
+	for _, entry := range entries {
+		switch entry.Op {
+		case EnvSet:
+			line("Environment", quoteSystemdWord(entry.Name+"="+entry.Value, false))
+		case EnvUnset:
+			line("UnsetEnvironment", quoteSystemdWord(entry.Name, false))
+		default:
+			return "", fmt.Errorf("systemd 不支持追加环境变量: %s", entry)
+		}
+	}
+	for _, envFile := range config.EnvFiles {
+		path, pathErr := systemdEnvironmentFile(config, envFile)
+		if pathErr != nil {
+			return "", pathErr
+		}
+		line("EnvironmentFile", escapeSystemdSpecifiers(path))
+	}
+
+	user, dynamicUser, accountErr := systemdUser(config.Account)
+	if accountErr != nil {
+		return "", accountErr
+	}
+	if user != "" {
+		line("User", escapeSystemdSpecifiers(user))
+	}
+	if dynamicUser {
+		line("DynamicUser", "yes")
+	}
+
+	switch settings.Policy {
+	case RestartAlways:
+		line("Restart", "always")
+	case RestartOnFailure:
+		line("Restart", "on-failure")
+	default:
+		line("Restart", "no")
+	}
+	if settings.Policy != RestartNever {
+		line("RestartSec", formatSystemdMillis(settings.BaseDelay.Milliseconds()))
+	}
+
+	if config.StopConsoleTimeoutMs > 0 {
+		line("TimeoutStopSec", formatSystemdMillis(int64(config.StopConsoleTimeoutMs)))
+	}
+	if config.DetachChildren {
+		// 停止服务时只结束主进程，保留子进程
+		line("KillMode", "process")
+	}
+
+	unit.WriteString("\n[Install]\n")
+	line("WantedBy", "multi-user.target")
+
+	if err != nil {
+		return "", err
+	}
+	return unit.String(), nil
+}
+
+// systemdEnvironmentFile 返回 .env 文件的绝对路径。systemd 只接受绝对路径，
+// 相对路径与包装器一样基于工作目录（未设置时为程序所在目录）解析
+func systemdEnvironmentFile(config ServiceConfig, envFile string) (string, error) {
+	if filepath.IsAbs(envFile) {
+		return filepath.Clean(envFile), nil
+	}
+
+	workingDir := config.WorkingDir
+	if workingDir == "" {
+		workingDir = filepath.Dir(config.ExePath)
+	}
+	if !filepath.IsAbs(workingDir) {
+		return "", fmt.Errorf("无法解析相对路径的环境变量文件 %s：工作目录 %s 不是绝对路径", envFile, workingDir)
+	}
+	return filepath.Join(workingDir, envFile), nil
+}
+
+// systemdUser 将服务账户转换为 systemd 的 User=。LocalSystem 对应 root，
+// 虚拟账户对应 DynamicUser；其他内置账户和域账户没有对应的 Linux 用户
+func systemdUser(account string) (string, bool, error) {
+	switch {
+	case account == "" || strings.EqualFold(account, ServiceAccountLocalSystem):
+		return "", false, nil
+	case strings.EqualFold(account, ServiceAccountVirtual):
+		return "", true, nil
+	case strings.Contains(account, `\`) || strings.Contains(account, "@"):
+		return "", false, fmt.Errorf("systemd 不支持 Windows 账户: %s", account)
+	default:
+		return account, false, nil
+	}
+}
+
+// formatSystemdMillis 将毫秒数格式化为 systemd 时间值
+func formatSystemdMillis(ms int64) string {
+	if ms%1000 == 0 {
+		return strconv.FormatInt(ms/1000, 10)
+	}
+	return strconv.FormatInt(ms, 10) + "ms"
+}
+
+// escapeSystemdSpecifiers 转义 unit 文件中的 % 说明符
+func escapeSystemdSpecifiers(value string) string {
+	return strings.ReplaceAll(value, "%", "%%")
+}
+
+// joinSystemdCommandLine 将参数列表转义为 ExecStart 命令行
+func joinSystemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteSystemdWord(arg, true)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// quoteSystemdWord 按 systemd 的引号规则转义单个参数：包含空白、引号或反斜杠时加双引号，
+// 并转义 % 说明符；execArg 为 true 时还需转义 $，避免 ExecStart 展开环境变量
+func quoteSystemdWord(word string, execArg bool) string {
+	word = escapeSystemdSpecifiers(word)
+	if execArg {
+		word = strings.ReplaceAll(word, "$", "$$")
+	}
+	if word != "" && !strings.ContainsAny(word, " \t\"'\\;") {
+		return word
+	}
+
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, c := range word {
+		switch c {
+		case '"', '\\':
+			quoted.WriteByte('\\')
+			quoted.WriteRune(c)
+		case '\t':
+			quoted.WriteString(`\t`)
+		default:
+			quoted.WriteRune(c)
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
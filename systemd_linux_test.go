@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "更新 testdata 中的期望输出")
+
+// RecordingCommandRunner 记录执行的命令而不实际执行，用于模拟 systemctl。
+// Outputs 和 Errors 按完整命令行（以空格拼接）返回预设的输出和错误
+type RecordingCommandRunner struct {
+	mutex    sync.Mutex
+	Commands []string
+	Outputs  map[string]string
+	Errors   map[string]error
+}
+
+func NewRecordingCommandRunner() *RecordingCommandRunner {
+	return &RecordingCommandRunner{
+		Outputs: make(map[string]string),
+		Errors:  make(map[string]error),
+	}
+}
+
+func (r *RecordingCommandRunner) Run(name string, args ...string) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	line := strings.Join(append([]string{name}, args...), " ")
+	r.Commands = append(r.Commands, line)
+	return []byte(r.Outputs[line]), r.Errors[line]
+}
+
+func TestRenderSystemdUnitGolden(t *testing.T) {
+	configs := map[string]ServiceConfig{
+		"full": {
+			Name:        "web",
+			Description: "Web 100% uptime",
+			ExePath:     "/opt/web/bin/web server",
+			ArgList:     []string{"--listen", ":8080", "--motd", `say "hi" $USER`, `C:\path`, "50%"},
+			WorkingDir:  "/opt/web",
+			Environment: []string{"MODE=prod", "GREETING=hello world", "-DEBUG"},
+			EnvFiles:    []string{"conf/web.env", "/etc/web/override.env"},
+			Account:     "www-data",
+
+			RestartPolicy:        string(RestartOnFailure),
+			MaxRestarts:          3,
+			RestartWindowSec:     120,
+			RestartDelayMs:       1500,
+			StopConsoleTimeoutMs: 10000,
+			DetachChildren:       true,
+			Dependencies:         []string{"postgresql", "+NetworkProvider"},
+		},
+		"minimal": {
+			Name:          "worker",
+			ExePath:       "/usr/local/bin/worker",
+			Args:          `--queue "jobs one"`,
+			Account:       ServiceAccountVirtual,
+			RestartPolicy: string(RestartNever),
+		},
+		"unlimited": {
+			Name:          "agent",
+			ExePath:       "/usr/local/bin/agent",
+			RestartPolicy: string(RestartAlways),
+			MaxRestarts:   unlimitedRestarts,
+		},
+	}
+
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			unit, err := RenderSystemdUnit(config)
+			if err != nil {
+				t.Fatalf("RenderSystemdUnit: %v", err)
+			}
+
+			golden := filepath.Join("testdata", "systemd", name+".service")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(unit), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if unit != string(want) {
+				t.Fatalf("unit 文件与 %s 不一致:\n%s", golden, unit)
+			}
+		})
+	}
+}
+
+func TestRenderSystemdUnitErrors(t *testing.T) {
+	base := ServiceConfig{Name: "svc", ExePath: "/usr/bin/svc"}
+
+	tests := map[string]func(*ServiceConfig){
+		"empty exe":            func(c *ServiceConfig) { c.ExePath = "" },
+		"append env":           func(c *ServiceConfig) { c.Environment = []string{"PATH+=:/opt/bin"} },
+		"windows account":      func(c *ServiceConfig) { c.Account = `NT AUTHORITY\NetworkService` },
+		"newline":              func(c *ServiceConfig) { c.Description = "line1\nline2" },
+		"bad dependency":       func(c *ServiceConfig) { c.Dependencies = []string{"a b"} },
+		"relative env file":    func(c *ServiceConfig) { c.ExePath, c.EnvFiles = "svc", []string{".env"} },
+		"relative working dir": func(c *ServiceConfig) { c.WorkingDir, c.EnvFiles = "run", []string{".env"} },
+	}
+
+	for name, modify := range tests {
+		t.Run(name, func(t *testing.T) {
+			config := base
+			modify(&config)
+			if unit, err := RenderSystemdUnit(config); err == nil {
+				t.Fatalf("期望返回错误，得到:\n%s", unit)
+			}
+		})
+	}
+}
+
+func TestSystemdEnvironmentFile(t *testing.T) {
+	tests := []struct {
+		workingDir, exePath, envFile, want string
+	}{
+		{"/srv/app", "/srv/app/bin/app", ".env", "/srv/app/.env"},
+		{"", "/srv/app/bin/app", "../conf/app.env", "/srv/app/conf/app.env"},
+		{"/srv/app", "/srv/app/bin/app", "/etc/app//app.env", "/etc/app/app.env"},
+	}
+
+	for _, tt := range tests {
+		config := ServiceConfig{WorkingDir: tt.workingDir, ExePath: tt.exePath}
+		got, err := systemdEnvironmentFile(config, tt.envFile)
+		if err != nil || got != tt.want {
+			t.Errorf("systemdEnvironmentFile(%q, %q) = %q, %v; 期望 %q", tt.workingDir, tt.envFile, got, err, tt.want)
+		}
+	}
+}
+
+func TestSystemdBackendInstallUninstall(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	unitDir := filepath.Join(t.TempDir(), "units")
+	backend := NewSystemdBackend(unitDir, runner)
+
+	config := ServiceConfig{Name: "api", ExePath: "/usr/bin/api", StartType: ServiceStartAutomatic}
+	if err := backend.Install("api", config); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	unit, err := os.ReadFile(filepath.Join(unitDir, "api.service"))
+	if err != nil {
+		t.Fatalf("unit 文件未写入: %v", err)
+	}
+	if want, _ := RenderSystemdUnit(config); string(unit) != want {
+		t.Fatalf("unit 文件内容 = %s", unit)
+	}
+
+	config.StartType = ServiceStartManual
+	if err := backend.Install("api", config); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if err := backend.Uninstall("api"); err != nil {
+		t.Fatalf("Uninstall: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(unitDir, "api.service")); !os.IsNotExist(err) {
+		t.Fatalf("卸载后 unit 文件仍存在: %v", err)
+	}
+
+	want := []string{
+		"systemctl daemon-reload",
+		"systemctl enable api.service",
+		"systemctl daemon-reload",
+		"systemctl disable api.service",
+		"systemctl stop api.service",
+		"systemctl disable api.service",
+		"systemctl daemon-reload",
+	}
+	if !reflect.DeepEqual(runner.Commands, want) {
+		t.Fatalf("执行的命令 = %q\n期望 %q", runner.Commands, want)
+	}
+
+	if err := backend.Install("bad name", config); err == nil {
+		t.Fatalf("服务名包含空格时应返回错误")
+	}
+}
+
+func TestSystemdBackendControl(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	backend := NewSystemdBackend(t.TempDir(), runner)
+
+	backend.Start("api")
+	backend.Stop("api")
+	backend.Restart("api")
+	want := []string{"systemctl start api.service", "systemctl stop api.service", "systemctl restart api.service"}
+	if !reflect.DeepEqual(runner.Commands, want) {
+		t.Fatalf("执行的命令 = %q", runner.Commands)
+	}
+
+	runner.Errors["systemctl start api.service"] = errors.New("exit status 5")
+	if err := backend.Start("api"); err == nil || !strings.Contains(err.Error(), "exit status 5") {
+		t.Fatalf("Start 错误 = %v", err)
+	}
+}
+
+func TestSystemdBackendStatus(t *testing.T) {
+	runner := NewRecordingCommandRunner()
+	backend := NewSystemdBackend(t.TempDir(), runner)
+	show := "systemctl show api.service --property=ActiveState --property=MainPID"
+
+	tests := []struct {
+		output, status string
+		pid            int
+	}{
+		{"ActiveState=active\nMainPID=4242\n", "running", 4242},
+		{"MainPID=77\nActiveState=activating\n", "running", 77},
+		{"ActiveState=inactive\nMainPID=0\n", "stopped", 0},
+		{"ActiveState=failed\nMainPID=0\n", "error", 0},
+	}
+	for _, tt := range tests {
+		runner.Outputs[show] = tt.output
+		status, pid, err := backend.Status("api")
+		if err != nil || status != tt.status || pid != tt.pid {
+			t.Errorf("Status(%q) = %s, %d, %v; 期望 %s, %d", tt.output, status, pid, err, tt.status, tt.pid)
+		}
+	}
+
+	runner.Errors[show] = errors.New("exit status 1")
+	if status, _, err := backend.Status("api"); err == nil || status != "error" {
+		t.Fatalf("systemctl 失败时 Status = %s, %v", status, err)
+	}
+}
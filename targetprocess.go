@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os/exec"
+)
+
+// ProcessLauncher 启动目标程序并返回用于整体终止的进程树
+type ProcessLauncher interface {
+	Launch(cmd *exec.Cmd) (ProcessTree, error)
+}
+
+// ProcessTree 目标程序及其派生的子进程
+type ProcessTree interface {
+	// Pid 返回目标程序主进程的PID
+	Pid() int
+	// Terminate 终止整个进程树
+	Terminate() error
+	// Close 释放进程树占用的资源；对于作业对象，关闭时会结束残留的子进程
+	Close() error
+}
+
+// selectProcessLauncher 根据服务配置选择进程启动方式：DetachChildren 时只跟踪主进程，
+// 否则使用 tracking 跟踪整个进程树
+func selectProcessLauncher(config ServiceConfig, tracking ProcessLauncher) ProcessLauncher {
+	if config.DetachChildren {
+		return directLauncher{}
+	}
+	return tracking
+}
+
+// directLauncher 直接启动目标程序，只跟踪主进程
+type directLauncher struct{}
+
+// Launch 启动目标程序
+func (directLauncher) Launch(cmd *exec.Cmd) (ProcessTree, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &directProcessTree{cmd: cmd}, nil
+}
+
+// directProcessTree 只包含主进程的进程树
+type directProcessTree struct {
+	cmd *exec.Cmd
+}
+
+func (tree *directProcessTree) Pid() int { return tree.cmd.Process.Pid }
+
+func (tree *directProcessTree) Terminate() error { return tree.cmd.Process.Kill() }
+
+func (tree *directProcessTree) Close() error { return nil }
+
+// processExit 目标程序的退出信息
+type processExit struct {
+	exitCode int
+	err      error
+}
+
+// targetProcess 一次启动的目标程序及其进程树
+type targetProcess struct {
+	cmd  *exec.Cmd
+	tree ProcessTree
+}
+
+// launchTargetProcess 通过 launcher 启动目标程序，退出后将退出信息发送到 exitCh
+func launchTargetProcess(launcher ProcessLauncher, cmd *exec.Cmd, exitCh chan<- processExit) (*targetProcess, error) {
+	tree, err := launcher.Launch(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		err := cmd.Wait()
+
+		exitCode := -1
+		if cmd.ProcessState != nil {
+			exitCode = cmd.ProcessState.ExitCode()
+		}
+
+		exitCh <- processExit{exitCode: exitCode, err: err}
+	}()
+
+	return &targetProcess{cmd: cmd, tree: tree}, nil
+}
+
+// Pid 返回目标程序主进程的PID
+func (target *targetProcess) Pid() int {
+	return target.tree.Pid()
+}
+
+// terminate 强制终止目标程序及其子进程，终止进程树失败时改为结束主进程
+func (target *targetProcess) terminate(events wrapperEvents) {
+	if err := target.tree.Terminate(); err != nil {
+		events.warning(EventStopFailed, "终止进程树失败，改为结束主进程: %v", err)
+		target.cmd.Process.Kill()
+	}
+}
+
+// release 目标程序退出后释放进程树，未分离的残留子进程随进程树一并结束
+func (target *targetProcess) release() {
+	target.tree.Close()
+}
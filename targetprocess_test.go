@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// TestTargetProcessHelper 作为测试用的目标程序运行，由 helperCommand 启动
+func TestTargetProcessHelper(t *testing.T) {
+	switch os.Getenv("TARGET_PROCESS_HELPER") {
+	case "sleep":
+		time.Sleep(time.Minute)
+		os.Exit(0)
+	case "exit":
+		os.Exit(3)
+	}
+}
+
+// helperCommand 返回以指定模式运行 TestTargetProcessHelper 的命令
+func helperCommand(mode string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestTargetProcessHelper$")
+	cmd.Env = append(os.Environ(), "TARGET_PROCESS_HELPER="+mode)
+	return cmd
+}
+
+// fakeProcessTree 记录进程树的终止和释放，终止时结束主进程
+type fakeProcessTree struct {
+	directProcessTree
+	terminateErr error
+	terminated   int
+	closed       int
+}
+
+func (tree *fakeProcessTree) Terminate() error {
+	tree.terminated++
+	if tree.terminateErr != nil {
+		return tree.terminateErr
+	}
+	return tree.directProcessTree.Terminate()
+}
+
+func (tree *fakeProcessTree) Close() error {
+	tree.closed++
+	return nil
+}
+
+// fakeProcessLauncher 直接启动目标程序并返回 fakeProcessTree
+type fakeProcessLauncher struct {
+	tree         *fakeProcessTree
+	terminateErr error
+}
+
+func (launcher *fakeProcessLauncher) Launch(cmd *exec.Cmd) (ProcessTree, error) {
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	launcher.tree = &fakeProcessTree{directProcessTree: directProcessTree{cmd: cmd}, terminateErr: launcher.terminateErr}
+	return launcher.tree, nil
+}
+
+// waitExit 等待目标程序退出
+func waitExit(t *testing.T, exitCh <-chan processExit) processExit {
+	t.Helper()
+
+	select {
+	case exit := <-exitCh:
+		return exit
+	case <-time.After(10 * time.Second):
+		t.Fatalf("等待目标程序退出超时")
+		return processExit{}
+	}
+}
+
+func TestSelectProcessLauncher(t *testing.T) {
+	tracking := &fakeProcessLauncher{}
+
+	if launcher := selectProcessLauncher(ServiceConfig{}, tracking); launcher != tracking {
+		t.Fatalf("默认应跟踪整个进程树: %T", launcher)
+	}
+	if launcher := selectProcessLauncher(ServiceConfig{DetachChildren: true}, tracking); launcher != (directLauncher{}) {
+		t.Fatalf("DetachChildren 时应只跟踪主进程: %T", launcher)
+	}
+}
+
+func TestTargetProcessTerminateOnStop(t *testing.T) {
+	launcher := &fakeProcessLauncher{}
+	exitCh := make(chan processExit, 1)
+
+	target, err := launchTargetProcess(launcher, helperCommand("sleep"), exitCh)
+	if err != nil {
+		t.Fatalf("launchTargetProcess: %v", err)
+	}
+	if target.Pid() != target.cmd.Process.Pid {
+		t.Fatalf("Pid = %d, 期望 %d", target.Pid(), target.cmd.Process.Pid)
+	}
+
+	sink := &RecordingEventSink{}
+	target.terminate(wrapperEvents{serviceName: "svc", sink: sink})
+	waitExit(t, exitCh)
+	target.release()
+
+	if launcher.tree.terminated != 1 || launcher.tree.closed != 1 {
+		t.Fatalf("停止时应终止并释放进程树: terminated=%d closed=%d", launcher.tree.terminated, launcher.tree.closed)
+	}
+	if events := sink.Events(); len(events) != 0 {
+		t.Fatalf("终止成功时不应输出事件: %+v", events)
+	}
+}
+
+func TestTargetProcessTerminateFallsBackToKill(t *testing.T) {
+	launcher := &fakeProcessLauncher{terminateErr: errors.New("access denied")}
+	exitCh := make(chan processExit, 1)
+
+	target, err := launchTargetProcess(launcher, helperCommand("sleep"), exitCh)
+	if err != nil {
+		t.Fatalf("launchTargetProcess: %v", err)
+	}
+
+	sink := &RecordingEventSink{}
+	target.terminate(wrapperEvents{serviceName: "svc", sink: sink})
+	waitExit(t, exitCh)
+
+	events := sink.Events()
+	if len(events) != 1 || events[0].ID != EventStopFailed || events[0].Level != WrapperEventWarning {
+		t.Fatalf("终止进程树失败时应输出警告: %+v", events)
+	}
+}
+
+func TestTargetProcessReleaseOnExit(t *testing.T) {
+	launcher := &fakeProcessLauncher{}
+	exitCh := make(chan processExit, 1)
+
+	target, err := launchTargetProcess(launcher, helperCommand("exit"), exitCh)
+	if err != nil {
+		t.Fatalf("launchTargetProcess: %v", err)
+	}
+
+	exit := waitExit(t, exitCh)
+	if exit.exitCode != 3 {
+		t.Fatalf("退出码 = %d, 错误 = %v", exit.exitCode, exit.err)
+	}
+	target.release()
+
+	if launcher.tree.terminated != 0 || launcher.tree.closed != 1 {
+		t.Fatalf("正常退出时只释放进程树: terminated=%d closed=%d", launcher.tree.terminated, launcher.tree.closed)
+	}
+}
+
+func TestLaunchTargetProcessFailure(t *testing.T) {
+	exitCh := make(chan processExit, 1)
+
+	if _, err := launchTargetProcess(&fakeProcessLauncher{}, exec.Command("/nonexistent/app"), exitCh); err == nil {
+		t.Fatalf("启动失败时应返回错误")
+	}
+	select {
+	case exit := <-exitCh:
+		t.Fatalf("启动失败时不应报告退出: %+v", exit)
+	default:
+	}
+}
@@ -1,89 +1,183 @@
+//go:build windows
+
 package main
 
 import (
 	"fmt"
-	"log"
+	"io"
+	"math/rand"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
+	"sync"
 	"syscall"
 	"time"
 
-	"golang.org/x/sys/windows/registry"
 	"golang.org/x/sys/windows/svc"
 	"golang.org/x/sys/windows/svc/debug"
 )
 
 // EmbeddedServiceWrapper 内置服务包装器
 type EmbeddedServiceWrapper struct {
-	serviceName string
-	config      ServiceConfig
-	process     *exec.Cmd
-	isRunning   bool
+	serviceName   string
+	config        ServiceConfig
+	process       *exec.Cmd
+	launcher      ProcessLauncher
+	target        *targetProcess
+	logWriters    []*RotatingWriter
+	logMutex      sync.Mutex // 保护 logWriters，日志轮转回调会在其他协程中读取
+	linePrefixers []*LinePrefixWriter
+	isRunning     bool
+	exitCh        chan processExit
+	restarts      *RestartTracker
+	exitActions   ExitActionTable
+	events        wrapperEvents
 }
 
-// NewEmbeddedServiceWrapper 创建内置服务包装器
-func NewEmbeddedServiceWrapper(serviceName string, config ServiceConfig) *EmbeddedServiceWrapper {
+// processOutputWaitDelay 目标程序退出后等待输出管道关闭的最长时间
+const processOutputWaitDelay = 3 * time.Second
+
+// NewEmbeddedServiceWrapper 创建内置服务包装器，sink 为 nil 时事件只输出到标准日志
+func NewEmbeddedServiceWrapper(serviceName string, config ServiceConfig, sink WrapperEventSink) *EmbeddedServiceWrapper {
+	if sink == nil {
+		sink = StdLogEventSink{}
+	}
+	events := wrapperEvents{serviceName: serviceName, sink: sink}
+
+	exitActions, err := ParseExitActions(config.ExitActions)
+	if err != nil {
+		events.warning(EventConfigError, "退出动作配置无效，将按重启策略处理: %v", err)
+		exitActions = ExitActionTable{}
+	}
+
 	return &EmbeddedServiceWrapper{
 		serviceName: serviceName,
 		config:      config,
+		launcher:    newProcessLauncher(config, events),
 		isRunning:   false,
+		exitCh:      make(chan processExit, 1),
+		restarts:    NewRestartTracker(config.restartSettings(), rand.Float64),
+		exitActions: exitActions,
+		events:      events,
 	}
 }
 
 // Execute 实现Windows服务接口
 func (esw *EmbeddedServiceWrapper) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
-	log.Printf("EmbeddedServiceWrapper 开始执行服务: %s", esw.serviceName)
+	esw.events.info(EventWrapperStarted, "服务包装器开始执行服务: %s", esw.serviceName)
 
 	s <- svc.Status{State: svc.StartPending}
 
+	esw.applyLogRetention()
+
 	err := esw.startTargetProcess()
 	if err != nil {
-		log.Printf("启动目标程序失败: %v", err)
+		esw.events.error(EventStartFailed, "启动目标程序失败: %v", err)
 		s <- svc.Status{State: svc.Stopped}
 		return false, 1
 	}
 
 	s <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
-	log.Printf("服务已启动，目标程序PID: %d", esw.process.Process.Pid)
 
-	go esw.monitorTargetProcess()
+	var restartTimer <-chan time.Time
+
+	retentionTicker := time.NewTicker(logRetentionInterval)
+	defer retentionTicker.Stop()
 
 	for {
 		select {
 		case c := <-r:
 			switch c.Cmd {
 			case svc.Stop, svc.Shutdown:
-				log.Printf("服务接收到停止信号: %s", esw.serviceName)
+				esw.events.info(EventServiceStopping, "服务接收到停止信号: %s", esw.serviceName)
 				s <- svc.Status{State: svc.StopPending}
-				esw.stopTargetProcess()
+				esw.stopTargetProcess(func(checkPoint uint32, waitHint time.Duration) {
+					s <- svc.Status{State: svc.StopPending, CheckPoint: checkPoint, WaitHint: uint32(waitHint.Milliseconds())}
+				})
 				s <- svc.Status{State: svc.Stopped}
 				return false, 0
 			case svc.Interrogate:
 				s <- c.CurrentStatus
 			default:
-				log.Printf("服务接收到未知命令: %v", c.Cmd)
+				esw.events.warning(EventServiceStopping, "服务接收到未知命令: %v", c.Cmd)
 			}
-		default:
-			if !esw.isRunning {
-				log.Printf("目标程序已退出，停止服务: %s", esw.serviceName)
-				s <- svc.Status{State: svc.Stopped}
-				return false, 0
+		case exit := <-esw.exitCh:
+			esw.handleProcessExit(exit)
+
+			action, configured := esw.exitActions.Lookup(exit.exitCode)
+			if !configured {
+				decision := esw.restarts.Decide(time.Now(), exit.exitCode)
+				if !decision.Restart {
+					esw.events.warning(EventServiceExiting, "目标程序已退出（%s），停止服务: %s", decision.Reason, esw.serviceName)
+					return processExitCode(exit.exitCode)
+				}
+				esw.events.info(EventRestartScheduled, "目标程序将在 %v 后重启（%s）: %s", decision.Delay, decision.Reason, esw.serviceName)
+				restartTimer = time.After(decision.Delay)
+				continue
+			}
+
+			esw.events.info(EventProcessExited, "目标程序退出码 %d 对应退出动作: %s", exit.exitCode, action)
+			switch action.Kind {
+			case ExitActionRestart:
+				decision := esw.restarts.Next(time.Now())
+				if !decision.Restart {
+					esw.events.error(EventServiceExiting, "目标程序无法重启（%s），停止服务: %s", decision.Reason, esw.serviceName)
+					return failureExitCode(exit.exitCode)
+				}
+				esw.events.info(EventRestartScheduled, "目标程序将在 %v 后重启（%s）: %s", decision.Delay, decision.Reason, esw.serviceName)
+				restartTimer = time.After(decision.Delay)
+			case ExitActionIgnore:
+				esw.events.warning(EventProcessExited, "忽略目标程序退出，服务保持运行: %s", esw.serviceName)
+			case ExitActionExitCode:
+				esw.events.warning(EventServiceExiting, "按退出动作以退出码 %d 停止服务: %s", action.Code, esw.serviceName)
+				return action.Code != 0, action.Code
+			default:
+				esw.events.warning(EventServiceExiting, "按退出动作停止服务: %s", esw.serviceName)
+				return processExitCode(exit.exitCode)
+			}
+		case <-retentionTicker.C:
+			esw.applyLogRetention()
+		case <-restartTimer:
+			restartTimer = nil
+
+			if err := esw.startTargetProcess(); err != nil {
+				esw.events.error(EventStartFailed, "重启目标程序失败: %v", err)
+
+				decision := esw.restarts.Next(time.Now())
+				if !decision.Restart {
+					esw.events.error(EventServiceExiting, "目标程序无法重启（%s），停止服务: %s", decision.Reason, esw.serviceName)
+					return false, 1
+				}
+				esw.events.info(EventRestartScheduled, "目标程序将在 %v 后重试启动: %s", decision.Delay, esw.serviceName)
+				restartTimer = time.After(decision.Delay)
+				continue
 			}
-			time.Sleep(1 * time.Second)
+
 		}
 	}
 }
 
-// startTargetProcess 启动目标程序
-func (esw *EmbeddedServiceWrapper) startTargetProcess() error {
-	var args []string
-	if esw.config.Args != "" {
-		args = strings.Fields(esw.config.Args)
+// processExitCode 将目标程序退出码转换为服务退出码，非0退出码作为服务特定错误码上报SCM。
+// 服务结束时不再主动发送 Stopped 状态，由 svc 包携带退出码统一上报。
+func processExitCode(exitCode int) (bool, uint32) {
+	if exitCode == 0 {
+		return false, 0
 	}
+	return true, uint32(exitCode)
+}
 
-	esw.process = exec.Command(esw.config.ExePath, args...)
+// failureExitCode 与 processExitCode 相同，但保证即使目标程序退出码为0也以失败结束服务
+func failureExitCode(exitCode int) (bool, uint32) {
+	if exitCode == 0 {
+		return true, 1
+	}
+	return processExitCode(exitCode)
+}
+
+// startTargetProcess 启动目标程序
+func (esw *EmbeddedServiceWrapper) startTargetProcess() error {
+	process := exec.Command(esw.config.ExePath, esw.config.argv()...)
+	esw.process = process
 
 	workingDir := esw.config.WorkingDir
 	if workingDir == "" {
@@ -91,68 +185,233 @@ func (esw *EmbeddedServiceWrapper) startTargetProcess() error {
 	}
 	esw.process.Dir = workingDir
 
+	env, err := esw.buildTargetEnvironment(workingDir)
+	if err != nil {
+		return err
+	}
+	esw.process.Env = env
+
 	esw.process.SysProcAttr = &syscall.SysProcAttr{
 		HideWindow: true,
 	}
 
-	logDir := filepath.Join(os.Getenv("ProgramData"), "windows_service_logs")
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		log.Printf("创建日志目录失败: %v", err)
+	logPath := esw.openTargetLogs()
+
+	target, err := launchTargetProcess(esw.launcher, process, esw.exitCh)
+	if err != nil {
+		esw.closeTargetLogs()
+		return fmt.Errorf("启动目标程序失败: %v", err)
+	}
+	esw.target = target
+
+	esw.isRunning = true
+	esw.events.info(EventProcessStarted, "目标程序已启动: %s，PID: %d，日志文件: %s", esw.config.ExePath, target.Pid(), logPath)
+	return nil
+}
+
+// openTargetLogs 打开目标程序的日志文件并连接到标准输出和标准错误，返回标准输出日志路径。
+// 日志文件打开失败时不影响目标程序启动，只是不记录输出。
+func (esw *EmbeddedServiceWrapper) openTargetLogs() string {
+	options := esw.config.rotateOptions()
+	options.OnRotate = esw.applyLogRetention
+	options.OnError = func(err error) {
+		esw.events.warning(EventLogError, "%v", err)
+	}
+
+	stdoutWriter := NewRotatingWriter(serviceLogDir(), esw.serviceName, logStreamSuffix(LogStreamStdout), options, nil, nil)
+	if err := stdoutWriter.Open(); err != nil {
+		esw.events.warning(EventLogError, "打开日志文件失败: %v", err)
+		return ""
+	}
+	writers := []*RotatingWriter{stdoutWriter}
+
+	var stdout, stderr io.Writer = stdoutWriter, stdoutWriter
+	if esw.config.SeparateStderr {
+		stderrWriter := NewRotatingWriter(serviceLogDir(), esw.serviceName, logStreamSuffix(LogStreamStderr), options, nil, nil)
+		if err := stderrWriter.Open(); err != nil {
+			esw.events.warning(EventLogError, "打开标准错误日志文件失败，改为写入标准输出日志: %v", err)
+		} else {
+			writers = append(writers, stderrWriter)
+			stderr = stderrWriter
+		}
+	}
+
+	formattedTimestamp := time.Now().Format("2006-01-02 15:04:05")
+	header := fmt.Sprintf("=== 服务日志开始 ===\n服务名称: %s\n启动时间: %s\n可执行文件: %s\n工作目录: %s\n参数: %s\n========================\n\n",
+		esw.serviceName, formattedTimestamp, esw.config.ExePath, esw.config.WorkingDir, esw.config.Args)
+	esw.logMutex.Lock()
+	esw.logWriters = writers
+	esw.logMutex.Unlock()
+	for _, writer := range writers {
+		if _, err := writer.Write([]byte(header)); err != nil {
+			esw.events.warning(EventLogError, "写入日志头信息失败: %v", err)
+		}
+		writer.Sync()
+	}
+
+	if esw.config.TimestampLogs {
+		stdoutPrefixer := NewLinePrefixWriter(stdout, LogStreamStdout, nil)
+		stderrPrefixer := NewLinePrefixWriter(stderr, LogStreamStderr, nil)
+		esw.linePrefixers = []*LinePrefixWriter{stdoutPrefixer, stderrPrefixer}
+		stdout, stderr = stdoutPrefixer, stderrPrefixer
+	}
+
+	esw.process.Stdout = stdout
+	esw.process.Stderr = stderr
+	// 子进程可能继承输出管道，目标程序退出后最多再等待一段时间读取剩余输出
+	esw.process.WaitDelay = processOutputWaitDelay
+
+	return stdoutWriter.Path()
+}
+
+// applyLogRetention 对服务的所有历史日志执行保留策略，当前正在写入的文件不会被删除
+func (esw *EmbeddedServiceWrapper) applyLogRetention() {
+	retention := esw.config.logRetention()
+	if retention.IsZero() {
+		return
+	}
+
+	// 持有 logMutex 直到清理完成，避免新打开的日志文件在清理过程中被当作历史文件删除
+	esw.logMutex.Lock()
+	defer esw.logMutex.Unlock()
+
+	active := make([]string, 0, len(esw.logWriters))
+	for _, writer := range esw.logWriters {
+		active = append(active, writer.Path())
 	}
 
-	timestamp := time.Now().Format("20060102_150405")
-	logFile := filepath.Join(logDir, fmt.Sprintf("%s_%s.log", esw.serviceName, timestamp))
-	file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	removed, err := ApplyLogRetention(osLogFileSystem{}, serviceLogDir(), esw.serviceName, retention, time.Now(), active)
+	if len(removed) > 0 {
+		esw.events.info(EventLogPruned, "已按保留策略删除 %d 个历史日志文件", len(removed))
+	}
 	if err != nil {
-		log.Printf("打开日志文件失败: %v", err)
-	} else {
-		esw.process.Stdout = file
-		esw.process.Stderr = file
-
-		formattedTimestamp := time.Now().Format("2006-01-02 15:04:05")
-		header := fmt.Sprintf("=== 服务日志开始 ===\n服务名称: %s\n启动时间: %s\n可执行文件: %s\n工作目录: %s\n参数: %s\n========================\n\n",
-			esw.serviceName, formattedTimestamp, esw.config.ExePath, esw.config.WorkingDir, esw.config.Args)
-		if _, err = file.WriteString(header); err != nil {
-			log.Printf("写入日志头信息失败: %v", err)
+		esw.events.warning(EventLogError, "执行日志保留策略失败: %v", err)
+	}
+}
+
+// buildTargetEnvironment 构建目标程序的环境变量：继承的环境 → .env 文件 → 服务环境变量配置，
+// 后者优先。未配置任何环境变量时返回 nil，目标程序直接继承包装器的环境。
+func (esw *EmbeddedServiceWrapper) buildTargetEnvironment(workingDir string) ([]string, error) {
+	if len(esw.config.EnvFiles) == 0 && len(esw.config.Environment) == 0 {
+		return nil, nil
+	}
+
+	block := newEnvBlock(os.Environ())
+
+	for _, envFile := range esw.config.EnvFiles {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(workingDir, envFile)
+		}
+		vars, err := LoadDotenvFile(envFile, block.Get)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vars {
+			block.Set(v.Name, v.Value)
 		}
-		file.Sync()
 	}
 
-	err = esw.process.Start()
+	entries, err := ParseEnvEntries(esw.config.Environment)
 	if err != nil {
-		return fmt.Errorf("启动目标程序失败: %v", err)
+		return nil, fmt.Errorf("环境变量配置无效: %v", err)
 	}
+	block.Apply(entries)
 
-	esw.isRunning = true
-	log.Printf("目标程序已启动: %s，PID: %d，日志文件: %s", esw.config.ExePath, esw.process.Process.Pid, logFile)
-	return nil
+	return block.Environ(), nil
 }
 
-// stopTargetProcess 停止目标程序
-func (esw *EmbeddedServiceWrapper) stopTargetProcess() {
-	if esw.process != nil && esw.isRunning {
-		log.Printf("正在停止目标程序，PID: %d", esw.process.Process.Pid)
+// stopTargetProcess 按配置的停止方式逐级停止目标程序：Ctrl+C、WM_CLOSE，最后强制终止。
+// report 用于向SCM上报递增的检查点和等待提示，避免停止过程超时。
+func (esw *EmbeddedServiceWrapper) stopTargetProcess(report func(checkPoint uint32, waitHint time.Duration)) {
+	if esw.process == nil || !esw.isRunning {
+		return
+	}
+
+	pid := esw.process.Process.Pid
+	esw.events.info(EventServiceStopping, "正在停止目标程序，PID: %d", pid)
+
+	var checkPoint uint32
+	for _, stage := range esw.config.stopStages() {
+		checkPoint++
+		report(checkPoint, stage.timeout+stopWaitHintMargin)
+
+		sent, err := stage.signal(pid)
+		if err != nil {
+			esw.events.warning(EventStopFailed, "停止方式 %s 失败: %v", stage.name, err)
+			continue
+		}
+		if !sent {
+			continue
+		}
+
+		esw.events.info(EventStopEscalated, "已通过 %s 请求目标程序退出，等待 %v", stage.name, stage.timeout)
+		if esw.waitForProcessExit(stage.timeout) {
+			esw.events.info(EventProcessStopped, "目标程序已响应 %s 退出", stage.name)
+			return
+		}
+	}
+
+	checkPoint++
+	report(checkPoint, defaultStopTerminateTimeout+stopWaitHintMargin)
+	esw.events.warning(EventStopEscalated, "正在强制终止目标程序及其子进程，PID: %d", pid)
+	esw.target.terminate(esw.events)
 
-		esw.process.Process.Kill()
+	if !esw.waitForProcessExit(defaultStopTerminateTimeout) {
+		esw.events.error(EventStopFailed, "等待目标程序终止超时，PID: %d", pid)
+		return
+	}
+	esw.events.info(EventProcessStopped, "目标程序已停止")
+}
 
-		esw.process.Wait()
-		esw.isRunning = false
-		log.Printf("目标程序已停止")
+// waitForProcessExit 在超时时间内等待目标程序退出
+func (esw *EmbeddedServiceWrapper) waitForProcessExit(timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case exit := <-esw.exitCh:
+		esw.handleProcessExit(exit)
+		return true
+	case <-timer.C:
+		return false
 	}
 }
 
-// monitorTargetProcess 监控目标程序
-func (esw *EmbeddedServiceWrapper) monitorTargetProcess() {
-	if esw.process != nil {
-		esw.process.Wait()
-		esw.isRunning = false
-		log.Printf("目标程序已退出: %s", esw.config.ExePath)
+// handleProcessExit 记录目标程序退出并释放相关资源，未分离的残留子进程随进程树一并结束
+func (esw *EmbeddedServiceWrapper) handleProcessExit(exit processExit) {
+	esw.isRunning = false
+	if esw.target != nil {
+		esw.target.release()
+		esw.target = nil
+	}
+	esw.closeTargetLogs()
+	if exit.exitCode == 0 {
+		esw.events.info(EventProcessExited, "目标程序已退出: %s，退出码: %d", esw.config.ExePath, exit.exitCode)
+	} else {
+		esw.events.warning(EventProcessExited, "目标程序已退出: %s，退出码: %d", esw.config.ExePath, exit.exitCode)
 	}
 }
 
-// RunAsWindowsService 将程序作为Windows服务运行（内置包装器模式）
-func RunAsWindowsService(serviceName string, config ServiceConfig) error {
-	wrapper := NewEmbeddedServiceWrapper(serviceName, config)
+// closeTargetLogs 输出缓存的不完整行并关闭当前目标程序的日志文件
+func (esw *EmbeddedServiceWrapper) closeTargetLogs() {
+	for _, prefixer := range esw.linePrefixers {
+		prefixer.Flush()
+	}
+	esw.linePrefixers = nil
+
+	esw.logMutex.Lock()
+	writers := esw.logWriters
+	esw.logWriters = nil
+	esw.logMutex.Unlock()
+
+	for _, writer := range writers {
+		writer.Close()
+	}
+}
+
+// RunAsWindowsService 将程序作为Windows服务运行（内置包装器模式），事件输出到 sink
+func RunAsWindowsService(serviceName string, config ServiceConfig, sink WrapperEventSink) error {
+	wrapper := NewEmbeddedServiceWrapper(serviceName, config, sink)
 
 	isService, err := svc.IsWindowsService()
 	if err != nil {
@@ -160,13 +419,13 @@ func RunAsWindowsService(serviceName string, config ServiceConfig) error {
 	}
 
 	if isService {
-		log.Printf("作为Windows服务运行: %s", serviceName)
+		wrapper.events.info(EventWrapperStarted, "作为Windows服务运行: %s", serviceName)
 		err = svc.Run(serviceName, wrapper)
 		if err != nil {
 			return fmt.Errorf("服务运行失败: %v", err)
 		}
 	} else {
-		log.Printf("调试模式运行: %s", serviceName)
+		wrapper.events.info(EventWrapperStarted, "调试模式运行: %s", serviceName)
 		err = debug.Run(serviceName, wrapper)
 		if err != nil {
 			return fmt.Errorf("调试运行失败: %v", err)
@@ -179,46 +438,13 @@ func RunAsWindowsService(serviceName string, config ServiceConfig) error {
 // IsServiceWrapperMode 检查是否以服务包装器模式运行
 func IsServiceWrapperMode() (bool, string) {
 	args := os.Args
-	if len(args) >= 3 && args[1] == "--service-wrapper" {
+	if len(args) >= 3 && args[1] == serviceWrapperFlag {
 		return true, args[2] // 返回服务名
 	}
 	return false, ""
 }
 
-// LoadServiceConfigFromRegistry 从注册表加载服务配置
-func LoadServiceConfigFromRegistry(serviceName string) (*ServiceConfig, error) {
-	keyPath := fmt.Sprintf(`SYSTEM\CurrentControlSet\Services\%s\Parameters`, serviceName)
-
-	key, err := registry.OpenKey(registry.LOCAL_MACHINE, keyPath, registry.READ)
-	if err != nil {
-		return nil, fmt.Errorf("打开服务配置注册表失败: %v", err)
-	}
-	defer key.Close()
-
-	exePath, _, err := key.GetStringValue("ExePath")
-	if err != nil {
-		return nil, fmt.Errorf("读取ExePath失败: %v", err)
-	}
-
-	args, _, err := key.GetStringValue("Args")
-	if err != nil {
-		args = ""
-	}
-
-	workingDir, _, err := key.GetStringValue("WorkingDir")
-	if err != nil {
-		workingDir = ""
-	}
-
-	displayName, _, err := key.GetStringValue("DisplayName")
-	if err != nil {
-		displayName = serviceName
-	}
-
-	return &ServiceConfig{
-		Name:       displayName,
-		ExePath:    exePath,
-		Args:       args,
-		WorkingDir: workingDir,
-	}, nil
+// LoadServiceConfigFromRegistry 从注册表加载服务配置，失败时输出配置错误事件
+func LoadServiceConfigFromRegistry(serviceName string, events wrapperEvents) (*ServiceConfig, error) {
+	return loadWrapperConfig(RegistryParameterProvider{}, serviceName, events)
 }
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WrapperEventLevel 包装器事件级别
+type WrapperEventLevel int
+
+const (
+	WrapperEventInfo WrapperEventLevel = iota
+	WrapperEventWarning
+	WrapperEventError
+)
+
+// String 返回事件级别的文本表示
+func (level WrapperEventLevel) String() string {
+	switch level {
+	case WrapperEventWarning:
+		return "WARN"
+	case WrapperEventError:
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
+// 包装器事件ID，写入事件日志时作为 EventID 使用，便于在事件查看器中筛选。
+// 事件源使用 EventCreate.exe 作为消息文件，ID 必须在 1-1000 之间。
+const (
+	EventWrapperStarted   uint32 = 100 // 包装器开始执行
+	EventProcessStarted   uint32 = 101 // 目标程序已启动
+	EventProcessExited    uint32 = 102 // 目标程序已退出
+	EventRestartScheduled uint32 = 103 // 计划重启目标程序
+	EventServiceStopping  uint32 = 104 // 服务收到停止请求
+	EventStopEscalated    uint32 = 105 // 停止方式升级
+	EventProcessStopped   uint32 = 106 // 目标程序已停止
+	EventServiceExiting   uint32 = 107 // 服务因目标程序退出而结束
+	EventLogPruned        uint32 = 108 // 已清理历史日志
+	EventStartFailed      uint32 = 200 // 目标程序启动失败
+	EventConfigError      uint32 = 201 // 服务配置错误
+	EventLogError         uint32 = 202 // 日志文件操作失败
+	EventStopFailed       uint32 = 203 // 停止目标程序失败
+	EventJobObjectError   uint32 = 204 // 作业对象操作失败
+)
+
+// wrapperLogMaxBytes 包装器日志文件的最大字节数，超过后转存为 .old 文件
+const wrapperLogMaxBytes = 10 * 1024 * 1024
+
+// WrapperEvent 包装器生命周期事件
+type WrapperEvent struct {
+	Time    time.Time
+	Level   WrapperEventLevel
+	ID      uint32
+	Service string
+	Message string
+}
+
+// WrapperEventSink 包装器事件的输出目标
+type WrapperEventSink interface {
+	Emit(event WrapperEvent)
+	Close() error
+}
+
+// wrapperEvents 为包装器提供格式化的事件输出
+type wrapperEvents struct {
+	serviceName string
+	sink        WrapperEventSink
+}
+
+func (we wrapperEvents) emit(level WrapperEventLevel, id uint32, format string, args ...interface{}) {
+	we.sink.Emit(WrapperEvent{
+		Time:    time.Now(),
+		Level:   level,
+		ID:      id,
+		Service: we.serviceName,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (we wrapperEvents) info(id uint32, format string, args ...interface{}) {
+	we.emit(WrapperEventInfo, id, format, args...)
+}
+
+func (we wrapperEvents) warning(id uint32, format string, args ...interface{}) {
+	we.emit(WrapperEventWarning, id, format, args...)
+}
+
+func (we wrapperEvents) error(id uint32, format string, args ...interface{}) {
+	we.emit(WrapperEventError, id, format, args...)
+}
+
+// loadWrapperConfig 从参数存储加载包装器运行所需的服务配置，失败时输出配置错误事件
+func loadWrapperConfig(provider ServiceParameterProvider, serviceName string, events wrapperEvents) (*ServiceConfig, error) {
+	store, err := provider.Open(serviceName, false)
+	if err != nil {
+		events.error(EventConfigError, "加载服务配置失败: %v", err)
+		return nil, err
+	}
+	defer store.Close()
+
+	config, err := decodeServiceParameters(store, serviceName)
+	if err != nil {
+		events.error(EventConfigError, "加载服务配置失败: %v", err)
+		return nil, err
+	}
+	return config, nil
+}
+
+// MultiEventSink 将事件同时输出到多个目标
+type MultiEventSink []WrapperEventSink
+
+// Emit 输出事件到所有目标
+func (sinks MultiEventSink) Emit(event WrapperEvent) {
+	for _, sink := range sinks {
+		sink.Emit(event)
+	}
+}
+
+// Close 关闭所有目标，返回第一个错误
+func (sinks MultiEventSink) Close() error {
+	var firstErr error
+	for _, sink := range sinks {
+		if err := sink.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// StdLogEventSink 将事件输出到标准日志，调试模式下可直接在控制台查看
+type StdLogEventSink struct{}
+
+// Emit 输出事件到标准日志
+func (StdLogEventSink) Emit(event WrapperEvent) {
+	log.Printf("[%s] %s", event.Level, event.Message)
+}
+
+// Close 无需释放资源
+func (StdLogEventSink) Close() error { return nil }
+
+// wrapperLogPath 返回服务包装器日志文件路径。
+// 文件名不使用 <id>_ 前缀，不会被当作目标程序日志参与轮转和保留策略。
+func wrapperLogPath(serviceID string) string {
+	return filepath.Join(serviceLogDir(), serviceID+".wrapper.log")
+}
+
+// FileEventSink 将事件追加写入包装器日志文件
+type FileEventSink struct {
+	mutex sync.Mutex
+	path  string
+	file  *os.File
+	size  int64
+}
+
+// NewFileEventSink 打开包装器日志文件，文件过大时先转存
+func NewFileEventSink(path string) (*FileEventSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
+	}
+
+	sink := &FileEventSink{path: path}
+	if err := sink.open(); err != nil {
+		return nil, err
+	}
+	return sink, nil
+}
+
+func (sink *FileEventSink) open() error {
+	if info, err := os.Stat(sink.path); err == nil && info.Size() >= wrapperLogMaxBytes {
+		os.Rename(sink.path, sink.path+".old")
+	}
+
+	file, err := os.OpenFile(sink.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开包装器日志文件失败: %v", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("打开包装器日志文件失败: %v", err)
+	}
+
+	sink.file = file
+	sink.size = info.Size()
+	return nil
+}
+
+// Emit 追加一行事件记录，写入失败时忽略
+func (sink *FileEventSink) Emit(event WrapperEvent) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.file == nil {
+		return
+	}
+	if sink.size >= wrapperLogMaxBytes {
+		sink.file.Close()
+		sink.file = nil
+		if err := sink.open(); err != nil {
+			return
+		}
+	}
+
+	line := fmt.Sprintf("%s [%s] (%d) %s\n", event.Time.Format(logLineTimestampLayout), event.Level, event.ID, event.Message)
+	n, _ := sink.file.WriteString(line)
+	sink.size += int64(n)
+}
+
+// Close 关闭包装器日志文件
+func (sink *FileEventSink) Close() error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	if sink.file == nil {
+		return nil
+	}
+	err := sink.file.Close()
+	sink.file = nil
+	return err
+}
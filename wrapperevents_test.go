@@ -0,0 +1,173 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RecordingEventSink 在内存中记录事件，用于测试断言
+type RecordingEventSink struct {
+	mutex  sync.Mutex
+	events []WrapperEvent
+}
+
+// Emit 记录事件
+func (sink *RecordingEventSink) Emit(event WrapperEvent) {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	sink.events = append(sink.events, event)
+}
+
+// Close 无需释放资源
+func (sink *RecordingEventSink) Close() error { return nil }
+
+// Events 返回已记录事件的副本
+func (sink *RecordingEventSink) Events() []WrapperEvent {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+
+	return append([]WrapperEvent(nil), sink.events...)
+}
+
+func TestFileEventSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "svc.wrapper.log")
+	sink, err := NewFileEventSink(path)
+	if err != nil {
+		t.Fatalf("NewFileEventSink: %v", err)
+	}
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6000000, time.Local)
+	sink.Emit(WrapperEvent{Time: at, Level: WrapperEventInfo, ID: EventWrapperStarted, Message: "启动"})
+	sink.Emit(WrapperEvent{Time: at, Level: WrapperEventError, ID: EventConfigError, Message: "配置错误"})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-01-02 03:04:05.006 [INFO] (100) 启动\n2024-01-02 03:04:05.006 [ERROR] (201) 配置错误\n"
+	if string(content) != want {
+		t.Fatalf("日志内容 = %q\n期望 %q", content, want)
+	}
+
+	// 达到大小上限后，下一条事件写入前转存为 .old
+	large := strings.Repeat("x", wrapperLogMaxBytes)
+	sink.Emit(WrapperEvent{Time: at, Level: WrapperEventInfo, ID: EventProcessExited, Message: large})
+	sink.Emit(WrapperEvent{Time: at, Level: WrapperEventWarning, ID: EventStopEscalated, Message: "升级"})
+	old, err := os.ReadFile(path + ".old")
+	if err != nil || !strings.HasPrefix(string(old), want) || !strings.HasSuffix(string(old), large+"\n") {
+		t.Fatalf(".old 应包含转存前的全部事件: %v", err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "2024-01-02 03:04:05.006 [WARN] (105) 升级\n" {
+		t.Fatalf("转存后日志内容 = %q", content)
+	}
+
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("重复 Close: %v", err)
+	}
+	sink.Emit(WrapperEvent{Time: at, Message: "关闭后"})
+	if content, _ := os.ReadFile(path); strings.Contains(string(content), "关闭后") {
+		t.Fatalf("关闭后不应再写入: %q", content)
+	}
+}
+
+func TestFileEventSinkRollsOversizedFileOnOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "svc.wrapper.log")
+	if err := os.WriteFile(path, make([]byte, wrapperLogMaxBytes), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewFileEventSink(path)
+	if err != nil {
+		t.Fatalf("NewFileEventSink: %v", err)
+	}
+	defer sink.Close()
+
+	if info, err := os.Stat(path + ".old"); err != nil || info.Size() != wrapperLogMaxBytes {
+		t.Fatalf("过大的日志应转存为 .old: %v", err)
+	}
+	if sink.size != 0 {
+		t.Fatalf("转存后 size = %d", sink.size)
+	}
+}
+
+// closeErrorSink 关闭时返回指定错误
+type closeErrorSink struct {
+	RecordingEventSink
+	err    error
+	closed bool
+}
+
+func (sink *closeErrorSink) Close() error {
+	sink.closed = true
+	return sink.err
+}
+
+func TestMultiEventSink(t *testing.T) {
+	first := &closeErrorSink{err: errors.New("first")}
+	second := &closeErrorSink{err: errors.New("second")}
+	third := &RecordingEventSink{}
+	sinks := MultiEventSink{first, second, third}
+
+	events := wrapperEvents{serviceName: "svc", sink: sinks}
+	events.warning(EventRestartScheduled, "%d 秒后重启", 5)
+
+	for i, sink := range []*RecordingEventSink{&first.RecordingEventSink, &second.RecordingEventSink, third} {
+		got := sink.Events()
+		if len(got) != 1 || got[0].Level != WrapperEventWarning || got[0].ID != EventRestartScheduled ||
+			got[0].Service != "svc" || got[0].Message != "5 秒后重启" {
+			t.Fatalf("目标 %d 收到事件 %+v", i, got)
+		}
+	}
+
+	if err := sinks.Close(); err == nil || err.Error() != "first" {
+		t.Fatalf("Close 应返回第一个错误: %v", err)
+	}
+	if !first.closed || !second.closed {
+		t.Fatalf("出错后仍应关闭其余目标")
+	}
+}
+
+func TestLoadWrapperConfig(t *testing.T) {
+	provider := NewMemoryParameterProvider()
+	setParameters(t, provider, "good", map[string]ParameterValue{
+		"ExePath": StringParameter(`C:\app\app.exe`),
+	})
+	setParameters(t, provider, "newer", map[string]ParameterValue{
+		"ExePath":              StringParameter(`C:\app\app.exe`),
+		parameterSchemaVersion: DWordParameter(serviceParametersVersion + 1),
+	})
+	setParameters(t, provider, "noexe", map[string]ParameterValue{
+		"Arguments": StringParameter("-v"),
+	})
+
+	sink := &RecordingEventSink{}
+	config, err := loadWrapperConfig(provider, "good", wrapperEvents{serviceName: "good", sink: sink})
+	if err != nil || config.ExePath != `C:\app\app.exe` {
+		t.Fatalf("loadWrapperConfig(good) = %+v, %v", config, err)
+	}
+	if events := sink.Events(); len(events) != 0 {
+		t.Fatalf("加载成功时不应输出事件: %+v", events)
+	}
+
+	for _, name := range []string{"missing", "newer", "noexe"} {
+		sink := &RecordingEventSink{}
+		if _, err := loadWrapperConfig(provider, name, wrapperEvents{serviceName: name, sink: sink}); err == nil {
+			t.Errorf("loadWrapperConfig(%s) 应返回错误", name)
+			continue
+		}
+		events := sink.Events()
+		if len(events) != 1 || events[0].Level != WrapperEventError || events[0].ID != EventConfigError ||
+			events[0].Service != name || !strings.HasPrefix(events[0].Message, "加载服务配置失败: ") {
+			t.Errorf("loadWrapperConfig(%s) 事件 = %+v", name, events)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// EventLogSink 将事件写入Windows事件日志（应用程序日志）
+type EventLogSink struct {
+	log *eventlog.Log
+}
+
+// NewEventLogSink 打开以服务名为事件源的事件日志，事件源未注册时先注册
+func NewEventLogSink(source string) (*EventLogSink, error) {
+	registerWrapperEventSource(source)
+
+	l, err := eventlog.Open(source)
+	if err != nil {
+		return nil, fmt.Errorf("打开事件日志失败: %v", err)
+	}
+	return &EventLogSink{log: l}, nil
+}
+
+// Emit 写入事件日志，写入失败时忽略
+func (sink *EventLogSink) Emit(event WrapperEvent) {
+	switch event.Level {
+	case WrapperEventError:
+		sink.log.Error(event.ID, event.Message)
+	case WrapperEventWarning:
+		sink.log.Warning(event.ID, event.Message)
+	default:
+		sink.log.Info(event.ID, event.Message)
+	}
+}
+
+// Close 关闭事件日志句柄
+func (sink *EventLogSink) Close() error {
+	return sink.log.Close()
+}
+
+// registerWrapperEventSource 注册事件源，已注册时不做任何操作
+func registerWrapperEventSource(source string) error {
+	err := eventlog.InstallAsEventCreate(source, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil && !isEventSourceExistsError(err) {
+		return fmt.Errorf("注册事件源失败: %v", err)
+	}
+	return nil
+}
+
+// removeWrapperEventSource 删除事件源注册信息
+func removeWrapperEventSource(source string) error {
+	if err := eventlog.Remove(source); err != nil {
+		return fmt.Errorf("删除事件源失败: %v", err)
+	}
+	return nil
+}
+
+// isEventSourceExistsError 判断注册事件源失败是否因为事件源已存在
+func isEventSourceExistsError(err error) bool {
+	return err != nil && strings.HasSuffix(err.Error(), "registry key already exists")
+}
+
+// newWrapperEventSink 创建包装器默认的事件输出：标准日志、事件日志和包装器日志文件。
+// 事件日志或日志文件不可用时跳过该目标，不影响服务运行。
+func newWrapperEventSink(serviceName string) WrapperEventSink {
+	sinks := MultiEventSink{StdLogEventSink{}}
+
+	if sink, err := NewEventLogSink(serviceName); err != nil {
+		log.Printf("事件日志不可用: %v", err)
+	} else {
+		sinks = append(sinks, sink)
+	}
+
+	if sink, err := NewFileEventSink(wrapperLogPath(serviceName)); err != nil {
+		log.Printf("包装器日志不可用: %v", err)
+	} else {
+		sinks = append(sinks, sink)
+	}
+
+	return sinks
+}